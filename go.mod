@@ -3,6 +3,7 @@ module github.com/baderkha/library
 go 1.18
 
 require (
+	github.com/baderkha/typesense v0.0.0-20220825042705-da50f99a8f02
 	github.com/badoux/checkmail v1.2.1
 	github.com/davecgh/go-spew v1.1.1
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
@@ -14,14 +15,20 @@ require (
 	github.com/stretchr/testify v1.8.0
 	github.com/tkrajina/go-reflector v0.5.6
 	github.com/wagslane/go-password-validator v0.3.0
+	github.com/wlredeye/jsonlines v0.0.0-20160904163743-36b5e1bd13d0
+	go.etcd.io/bbolt v1.3.6
+	go.opentelemetry.io/otel v1.10.0
+	go.opentelemetry.io/otel/metric v0.32.1
+	go.opentelemetry.io/otel/trace v1.10.0
 	golang.org/x/crypto v0.0.0-20211108221036-ceb1ce70b4fa
 	gorm.io/gorm v1.23.6
 )
 
 require (
 	github.com/Deiz/interfacegen v1.2.2 // indirect
-	github.com/baderkha/typesense v0.0.0-20220825042705-da50f99a8f02 // indirect
 	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/go-playground/locales v0.14.0 // indirect
 	github.com/go-playground/universal-translator v0.18.0 // indirect
 	github.com/go-playground/validator/v10 v10.10.0 // indirect
@@ -41,7 +48,6 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/stretchr/objx v0.4.0 // indirect
 	github.com/ugorji/go/codec v1.2.7 // indirect
-	github.com/wlredeye/jsonlines v0.0.0-20160904163743-36b5e1bd13d0 // indirect
 	golang.org/x/mod v0.6.0-dev.0.20220419223038-86c51ed26bb4 // indirect
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
 	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
@@ -0,0 +1,17 @@
+// Package cache : byte caches with a ttl (in process lru , redis) and stampede protection for the loads of a key
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// ICache : cache of byte values , values are copies (mutating a value does not change the cached one)
+type ICache interface {
+	// Get : the value of the key , found is false if the key is missing or expired
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	// Set : set the value of the key , a ttl <= 0 never expires
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete : delete the keys , missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"sync"
+)
+
+// Group : runs 1 load per key at a time , concurrent callers of the same key wait for it and share its result
+// (stampede protection) , the zero value is ready to use
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*groupCall
+}
+
+type groupCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Do : runs load for the key unless it is already running , shared is true if the result came from another caller's load
+func (g *Group) Do(key string, load func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*groupCall)
+	}
+	call, ok := g.calls[key]
+	if ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err, true
+	}
+	call = &groupCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+	call.value, call.err = load()
+	return call.value, call.err, false
+}
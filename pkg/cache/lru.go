@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+var _ ICache = &LRU{}
+
+// LRU : in process cache evicting the least recently used keys once full , the zero value is ready to use
+//
+// Example :
+//			c := &cache.LRU{MaxEntries: 10000}
+type LRU struct {
+	// MaxEntries : number of keys kept before evicting (0 for no limit)
+	MaxEntries int
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e *lruEntry) isExpired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+func (c *LRU) init() {
+	if c.items == nil {
+		c.items = make(map[string]*list.Element)
+		c.order = list.New()
+	}
+}
+
+// Get : the value of the key , found is false if the key is missing or expired
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*lruEntry)
+	if entry.isExpired(time.Now()) {
+		c.remove(el)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return copyBytes(entry.value), true, nil
+}
+
+// Set : set the value of the key , a ttl <= 0 never expires
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	entry := &lruEntry{key: key, value: copyBytes(value)}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	el, ok := c.items[key]
+	if ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.items[key] = c.order.PushFront(entry)
+	for c.MaxEntries > 0 && c.order.Len() > c.MaxEntries {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// Delete : delete the keys , missing keys are ignored
+func (c *LRU) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	for _, key := range keys {
+		el, ok := c.items[key]
+		if ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// Len : number of keys (expired keys are counted until they are read or evicted)
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	cp := make([]byte, len(b))
+	copy(cp, b)
+	return cp
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ ICache = &Redis{}
+var _ IRedisClient = &MemoryRedis{}
+
+// IRedisClient : the redis commands the cache needs , adapt your redis client to it
+//
+// Example (go-redis) :
+//			type goRedis struct{ *redis.Client }
+//
+//			func (r goRedis) Get(ctx context.Context, key string) (string, bool, error) {
+//				v, err := r.Client.Get(ctx, key).Result()
+//				if err == redis.Nil {
+//					return "", false, nil
+//				}
+//				return v, err == nil, err
+//			}
+//
+//			func (r goRedis) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+//				return r.Client.Set(ctx, key, value, ttl).Err()
+//			}
+//
+//			func (r goRedis) Del(ctx context.Context, keys ...string) error {
+//				return r.Client.Del(ctx, keys...).Err()
+//			}
+type IRedisClient interface {
+	// Get : GET key , found is false if the key does not exist
+	Get(ctx context.Context, key string) (value string, found bool, err error)
+	// Set : SET key value (PX ttl if ttl > 0)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	// Del : DEL keys
+	Del(ctx context.Context, keys ...string) error
+}
+
+// Redis : cache stored in redis , shared between the processes using it
+type Redis struct {
+	Client IRedisClient
+	// Prefix : prepended to every key (ie `myapp:`)
+	Prefix string
+}
+
+// Get : the value of the key , found is false if the key is missing or expired
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, found, err := r.Client.Get(ctx, r.Prefix+key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return []byte(value), true, nil
+}
+
+// Set : set the value of the key , a ttl <= 0 never expires
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return r.Client.Set(ctx, r.Prefix+key, string(value), ttl)
+}
+
+// Delete : delete the keys , missing keys are ignored
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixed = append(prefixed, r.Prefix+key)
+	}
+	return r.Client.Del(ctx, prefixed...)
+}
+
+// MemoryRedis : in memory stand in for a redis client (tests , local development) , the zero value is ready to use
+type MemoryRedis struct {
+	mu    sync.Mutex
+	items map[string]memoryRedisEntry
+}
+
+type memoryRedisEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// Get : GET key , found is false if the key does not exist
+func (m *MemoryRedis) Get(ctx context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.items[key]
+	if !ok {
+		return "", false, nil
+	}
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(m.items, key)
+		return "", false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set : SET key value (PX ttl if ttl > 0)
+func (m *MemoryRedis) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.items == nil {
+		m.items = make(map[string]memoryRedisEntry)
+	}
+	entry := memoryRedisEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	m.items[key] = entry
+	return nil
+}
+
+// Del : DEL keys
+func (m *MemoryRedis) Del(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.items, key)
+	}
+	return nil
+}
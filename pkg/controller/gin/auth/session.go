@@ -2,6 +2,7 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
@@ -12,12 +13,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/baderkha/library/pkg/cache"
 	"github.com/baderkha/library/pkg/conditional"
 	"github.com/baderkha/library/pkg/controller/gin/auth/sso"
 	"github.com/baderkha/library/pkg/controller/response"
 	"github.com/baderkha/library/pkg/email"
 	"github.com/baderkha/library/pkg/store/entity"
 	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/store/repository/gormrepo"
+	"github.com/baderkha/library/pkg/store/repository/otelrepo"
+	"github.com/baderkha/library/pkg/telemetry"
 	"github.com/badoux/checkmail"
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
@@ -102,6 +107,82 @@ type SessionAuthGinController struct {
 	// html templates here
 	ResetPasswordTemplateHTML []byte
 	VerifyAccountTemplateHTML []byte
+
+	// Telemetry : optional , traces the login / validate paths (nil disables it)
+	Telemetry *telemetry.Telemetry
+}
+
+// trace : starts the span of the auth operation , the request context carries it until the returned end func is called ,
+// a response status >= 400 marks the span as failed . no op without telemetry
+//
+// Example :
+//			defer c.trace(ctx, "login")()
+func (c *SessionAuthGinController) trace(ctx *gin.Context, operation string) (end func()) {
+	if c.Telemetry == nil || ctx.Request == nil {
+		return func() {}
+	}
+	req := ctx.Request
+	spanCtx, op := c.Telemetry.Start(req.Context(), "auth", operation, "")
+	ctx.Request = req.WithContext(spanCtx)
+	return func() {
+		var err error
+		status := ctx.Writer.Status()
+		if status >= http.StatusBadRequest {
+			err = fmt.Errorf("auth : %s responded %d %s", operation, status, http.StatusText(status))
+		}
+		op.End(err)
+		ctx.Request = req
+	}
+}
+
+// requestContext : the http request context of a gin context (gin does not forward its cancellation)
+func requestContext(ctx context.Context) context.Context {
+	gctx, ok := ctx.(*gin.Context)
+	if ok && gctx.Request != nil {
+		return gctx.Request.Context()
+	}
+	return ctx
+}
+
+// accounts : account repository bound to the request context (queries are cancelled with the request)
+func (c *SessionAuthGinController) accounts(ctx context.Context) repository.IAccount {
+	acc, ok := c.Arepo.WithContext(requestContext(ctx)).(repository.IAccount)
+	if !ok {
+		return c.Arepo
+	}
+	return acc
+}
+
+// sessions : session repository bound to the request context
+func (c *SessionAuthGinController) sessions(ctx context.Context) repository.ISession {
+	return c.SRepo.WithContext(requestContext(ctx))
+}
+
+// hashes : verification hash repository bound to the request context
+func (c *SessionAuthGinController) hashes(ctx context.Context) repository.IHashVerificationAccount {
+	return c.Hrepo.WithContext(requestContext(ctx))
+}
+
+// mailer : email sender bound to the request context if it supports it (ie email.TracedSender)
+func (c *SessionAuthGinController) mailer(ctx context.Context) email.ISender {
+	sender, ok := c.MailValidation.(interface {
+		WithContext(ctx context.Context) email.ISender
+	})
+	if !ok {
+		return c.MailValidation
+	}
+	return sender.WithContext(requestContext(ctx))
+}
+
+// ssoHandler : sso handler bound to the request context if it supports it (ie sso.TracedHandler)
+func (c *SessionAuthGinController) ssoHandler(ctx context.Context) sso.Handler {
+	handler, ok := c.SSOHandler.(interface {
+		WithContext(ctx context.Context) sso.Handler
+	})
+	if !ok {
+		return c.SSOHandler
+	}
+	return handler.WithContext(requestContext(ctx))
 }
 
 func (c *SessionAuthGinController) toAccount(e *entity.Account, emailRedact bool) *entity.Account {
@@ -117,7 +198,7 @@ func (c *SessionAuthGinController) SerializeSession(accountID string, ctx *gin.C
 		session = existingSession
 		session.ExpiresAt = time.Now().Add(c.AccountSessionDuration)
 
-		err := c.SRepo.Update(session)
+		err := c.sessions(ctx).Update(session)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(err))
 			return
@@ -128,7 +209,7 @@ func (c *SessionAuthGinController) SerializeSession(accountID string, ctx *gin.C
 		session.ExpiresAt = time.Now().Add(c.AccountSessionDuration)
 		session.AccountID = accountID
 
-		err := c.SRepo.Create(session)
+		err := c.sessions(ctx).Create(session)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(err))
 			return
@@ -162,7 +243,7 @@ func (c *SessionAuthGinController) DeleteCookie(ctx *gin.Context) {
 func (c *SessionAuthGinController) IsLoggedIn(ctx *gin.Context) bool {
 	sessionId, _ := ctx.Cookie(c.CookieName)
 	if sessionId != "" {
-		session, err := c.SRepo.GetById(sessionId)
+		session, err := c.sessions(ctx).GetById(sessionId)
 		if err != nil {
 			return false
 		}
@@ -172,6 +253,7 @@ func (c *SessionAuthGinController) IsLoggedIn(ctx *gin.Context) bool {
 }
 
 func (c *SessionAuthGinController) login(ctx *gin.Context) {
+	defer c.trace(ctx, "login")()
 	if !c.IsLoggedIn(ctx) {
 		var info loginObj
 		err := ctx.BindJSON(&info)
@@ -179,7 +261,7 @@ func (c *SessionAuthGinController) login(ctx *gin.Context) {
 			ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
 			return
 		}
-		acc, err := c.Arepo.GetById(info.UserName)
+		acc, err := c.accounts(ctx).GetById(info.UserName)
 		if err != nil || acc.IsSSO {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.NewError(errUnauthorized))
 			return
@@ -195,18 +277,19 @@ func (c *SessionAuthGinController) login(ctx *gin.Context) {
 
 // once user is login we will use our own session logic
 func (c *SessionAuthGinController) loginSSO(ctx *gin.Context) {
+	defer c.trace(ctx, "login_sso")()
 	if !c.IsLoggedIn(ctx) {
-		acc, err := c.SSOHandler.VerifyUser(ctx.Request.Header)
+		acc, err := c.ssoHandler(ctx).VerifyUser(ctx.Request.Header)
 		if err != nil {
 			fmt.Println(err)
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.NewError(errUnauthorized))
 			return
 		}
 
-		doesAccountExist, dbAccount := c.Arepo.DoesAccountExistByEmail(acc.Email)
+		doesAccountExist, dbAccount := c.accounts(ctx).DoesAccountExistByEmail(acc.Email)
 		if !doesAccountExist {
 			acc.New()
-			err := c.Arepo.Create(acc)
+			err := c.accounts(ctx).Create(acc)
 			if err != nil {
 				ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
 				return
@@ -231,7 +314,7 @@ func (s *SessionAuthGinController) sendAccountInfo(ctx *gin.Context, accID, sesI
 func (c *SessionAuthGinController) logout(ctx *gin.Context) {
 	sesId, isFoundSes := ctx.Get("session_id")
 	if isFoundSes {
-		c.SRepo.DeleteById(sesId.(string))
+		c.sessions(ctx).DeleteById(sesId.(string))
 	}
 	c.DeleteCookie(ctx)
 	ctx.JSON(200, "logged out")
@@ -265,16 +348,16 @@ func (c *SessionAuthGinController) validatePassword(hash string, inputPassword s
 	return nil
 }
 
-func (c *SessionAuthGinController) onNewAccount(acc *entity.Account) error {
+func (c *SessionAuthGinController) onNewAccount(ctx context.Context, acc *entity.Account) error {
 	// email to the client
-	err := c.sendVerificationEmail(acc, entity.HashVerificationAccountTypeVerify)
+	err := c.sendVerificationEmail(ctx, acc, entity.HashVerificationAccountTypeVerify)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *SessionAuthGinController) sendVerificationEmail(acc *entity.Account, Type string) error {
+func (c *SessionAuthGinController) sendVerificationEmail(ctx context.Context, acc *entity.Account, Type string) error {
 	uuid, _ := uuid.NewV4()
 
 	pwdB64 := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s_%s", password.MustGenerate(64, 10, 0, false, true), uuid.String())))
@@ -291,7 +374,7 @@ func (c *SessionAuthGinController) sendVerificationEmail(acc *entity.Account, Ty
 		Type:      Type,
 	}
 
-	err := c.Hrepo.Create(&hashVerificationForAccount)
+	err := c.hashes(ctx).Create(&hashVerificationForAccount)
 	if err != nil {
 		return err
 	}
@@ -346,7 +429,7 @@ func (c *SessionAuthGinController) sendVerificationEmail(acc *entity.Account, Ty
 		return errUnauthorized
 	}
 
-	return c.MailValidation.SendHTMLEmail(&config)
+	return c.mailer(ctx).SendHTMLEmail(&config)
 }
 
 func (c *SessionAuthGinController) sendVerificationLink(Type string) gin.HandlerFunc {
@@ -357,26 +440,26 @@ func (c *SessionAuthGinController) sendVerificationLink(Type string) gin.Handler
 			ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
 			return
 		}
-		isExist, acc := c.Arepo.DoesAccountExistByEmail(e.Email)
+		isExist, acc := c.accounts(ctx).DoesAccountExistByEmail(e.Email)
 		if isExist {
-			_ = c.sendVerificationEmail(acc, Type)
+			_ = c.sendVerificationEmail(ctx, acc, Type)
 		}
 		ctx.JSON(http.StatusOK, "ok")
 	}
 }
 
-func (c *SessionAuthGinController) verifyValidationHashAndGrabAccount(hash string) (acc *entity.Account, isValid bool) {
+func (c *SessionAuthGinController) verifyValidationHashAndGrabAccount(ctx context.Context, hash string) (acc *entity.Account, isValid bool) {
 	hashByte := (sha256.Sum256([]byte(hash)))
 	hash = fmt.Sprintf("%x", hashByte[:])
 
-	hashRes, err := c.Hrepo.GetById(hash)
+	hashRes, err := c.hashes(ctx).GetById(hash)
 
 	isValid = err == nil && !hashRes.HasBeenUsed && hashRes.TTLExpiry.Unix() > time.Now().Unix()
 
 	if isValid {
-		acc, err = c.Arepo.GetById(hashRes.AccountID)
+		acc, err = c.accounts(ctx).GetById(hashRes.AccountID)
 		hashRes.HasBeenUsed = true
-		_ = c.Hrepo.Update(hashRes)
+		_ = c.hashes(ctx).Update(hashRes)
 	}
 	return acc, isValid && err == nil
 
@@ -398,7 +481,7 @@ func (c *SessionAuthGinController) verifyValidationLink(ctx *gin.Context) {
 	hash := sha256.Sum256([]byte(signature))
 	signature = fmt.Sprintf("%x", hash[:])
 
-	acc, isValid := c.verifyValidationHashAndGrabAccount(signature)
+	acc, isValid := c.verifyValidationHashAndGrabAccount(ctx, signature)
 	if !isValid {
 		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.NewError(errUnauthorized))
 		return
@@ -406,7 +489,7 @@ func (c *SessionAuthGinController) verifyValidationLink(ctx *gin.Context) {
 
 	acc.IsVerified = true // account is verified and enabled for use
 
-	errUpdateDB = c.Arepo.Update(acc)
+	errUpdateDB = c.accounts(ctx).Update(acc)
 
 	if errUpdateDB != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
@@ -431,7 +514,7 @@ func (c *SessionAuthGinController) newAccount(ctx *gin.Context) {
 		return
 	}
 
-	if c.Arepo.DoesAccountExist(acc.ID, acc.Email) {
+	if c.accounts(ctx).DoesAccountExist(acc.ID, acc.Email) {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
 		return
 	}
@@ -440,14 +523,14 @@ func (c *SessionAuthGinController) newAccount(ctx *gin.Context) {
 
 	tx := c.Tx.Begin()
 
-	err = c.Arepo.Create(&acc)
+	err = c.accounts(ctx).Create(&acc)
 	if err != nil {
 		tx.RollBack()
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(err))
 		return
 	}
 
-	err = c.onNewAccount(&acc)
+	err = c.onNewAccount(ctx, &acc)
 	if err != nil {
 		tx.RollBack()
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(err))
@@ -460,7 +543,7 @@ func (c *SessionAuthGinController) newAccount(ctx *gin.Context) {
 
 func (c *SessionAuthGinController) getAccountInfo(ctx *gin.Context) {
 	id := ctx.Param("id")
-	ac, err := c.Arepo.GetById(id)
+	ac, err := c.accounts(ctx).GetById(id)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusNotFound, response.NewError(errorNotFoundAccount))
 		return
@@ -477,7 +560,7 @@ func (c *SessionAuthGinController) updateAccountPassword(newpassword string, acc
 	}
 	acc.Password = newpassword
 	acc = c.genHashAuth(acc)
-	err = c.Arepo.Update(acc)
+	err = c.accounts(ctx).Update(acc)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
 		return
@@ -499,7 +582,7 @@ func (c *SessionAuthGinController) changePasswordWithAuthToken(ctx *gin.Context)
 		return
 	}
 
-	acc, isValid := c.verifyValidationHashAndGrabAccount(p.Token)
+	acc, isValid := c.verifyValidationHashAndGrabAccount(ctx, p.Token)
 	if !isValid {
 		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.NewError(errUnauthorized))
 		return
@@ -524,7 +607,7 @@ func (c *SessionAuthGinController) changePassword(ctx *gin.Context) {
 		return
 	}
 
-	acc, err := c.Arepo.GetById(accId.(string))
+	acc, err := c.accounts(ctx).GetById(accId.(string))
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
 		return
@@ -541,7 +624,7 @@ func (c *SessionAuthGinController) changePassword(ctx *gin.Context) {
 
 func (c *SessionAuthGinController) deleteAccount(ctx *gin.Context) {
 	id, _ := ctx.Get("account_id")
-	err := c.Arepo.DeleteById(id.(string))
+	err := c.accounts(ctx).DeleteById(id.(string))
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
 		return
@@ -550,21 +633,32 @@ func (c *SessionAuthGinController) deleteAccount(ctx *gin.Context) {
 }
 
 func (c *SessionAuthGinController) validate(ctx *gin.Context) {
+	end := c.trace(ctx, "validate")
+	isValid := c.authenticate(ctx)
+	// the span only covers the session check , not the handlers after the middleware
+	end()
+	if isValid {
+		ctx.Next()
+	}
+}
+
+// authenticate : checks + refreshes the session of the cookie , aborts the request if it is not valid
+func (c *SessionAuthGinController) authenticate(ctx *gin.Context) bool {
 	sessionId, _ := ctx.Cookie(c.CookieName)
 	if sessionId != "" {
-		session, err := c.SRepo.GetById(sessionId)
+		session, err := c.sessions(ctx).GetById(sessionId)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.NewError(errUnauthorized))
-			return
+			return false
 		}
 		// send the account id
 		c.sendAccountInfo(ctx, session.AccountID, sessionId)
 		// refresh cookie
 		c.SerializeSession(session.AccountID, ctx, session)
-		ctx.Next()
-		return
+		return !ctx.IsAborted()
 	}
 	ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.NewError(errUnauthorized))
+	return false
 }
 
 func (c *SessionAuthGinController) GetAuthMiddleWare() gin.HandlerFunc {
@@ -640,22 +734,29 @@ type SessionConfig struct {
 	ResetPasswordTemplateHTML []byte
 	// VerifyAccountTemplateHTML : your app's html template this operation as []byte , the file grabbing portion is your bit
 	VerifyAccountTemplateHTML []byte
+	// SessionCache : optional cache of the sessions looked up on every authenticated request (see repository.CachedCrud)
+	SessionCache cache.ICache
+	// SessionCacheTTL : time a session stays cached , default is 1m
+	SessionCacheTTL time.Duration
+	// Telemetry : optional , traces the repositories , the mailer , the sso handler and the login / validate paths
+	// (register gormtelemetry.GormPlugin on the DB to trace the sql statements too)
+	Telemetry *telemetry.Telemetry
 }
 
 func NewGinSessionAuthGorm(s *SessionConfig) *SessionAuthGinController {
-	return &SessionAuthGinController{
+	c := &SessionAuthGinController{
 		CookieName:             s.CookieName,
 		URLPathPrefix:          s.BasePathRoute,
 		AccountSessionDuration: s.LoginExpiryTime,
 		Domain:                 s.Domain,
-		Arepo: &repository.AccountGorm{
-			CrudGorm: repository.CrudGorm[entity.Account]{
+		Arepo: &gormrepo.AccountGorm{
+			CrudGorm: gormrepo.CrudGorm[entity.Account]{
 				DB:     s.DB,
 				Parser: nil,
 				Sorter: nil,
 			},
 		},
-		SRepo: &repository.SessionGorm{
+		SRepo: &gormrepo.SessionGorm{
 			DB:     s.DB,
 			Parser: nil,
 			Sorter: nil,
@@ -664,12 +765,12 @@ func NewGinSessionAuthGorm(s *SessionConfig) *SessionAuthGinController {
 		PasswordResetURL:                   s.PasswordResetURLFull,
 		VerifyEmailURLFull:                 s.VerifyEmailURLFull,
 		Verification_PasswordResetDuration: s.PasswordResetLinkDuration,
-		Hrepo: &repository.CrudGorm[entity.HashVerificationAccount]{
+		Hrepo: &gormrepo.CrudGorm[entity.HashVerificationAccount]{
 			DB:     s.DB,
 			Parser: nil,
 			Sorter: nil,
 		},
-		Tx: &repository.GormTransaction{
+		Tx: &gormrepo.GormTransaction{
 			DB: s.DB,
 		},
 		MailValidation:            s.Mailer,
@@ -680,4 +781,25 @@ func NewGinSessionAuthGorm(s *SessionConfig) *SessionAuthGinController {
 			From:                 s.EmailSender,
 		},
 	}
+	if s.SessionCache != nil {
+		c.SRepo = repository.NewCachedCrud[entity.Session](
+			c.SRepo,
+			s.SessionCache,
+			conditional.Ternary(s.SessionCacheTTL > 0, s.SessionCacheTTL, time.Minute),
+		)
+	}
+	if s.Telemetry != nil {
+		// wraps the cache so the lookups served by it are traced too
+		c.Telemetry = s.Telemetry
+		c.Arepo = &otelrepo.TracedAccount{
+			TracedCrud: otelrepo.TracedCrud[entity.Account]{Repo: c.Arepo, Telemetry: s.Telemetry},
+		}
+		c.SRepo = &otelrepo.TracedCrud[entity.Session]{Repo: c.SRepo, Telemetry: s.Telemetry}
+		c.Hrepo = &otelrepo.TracedCrud[entity.HashVerificationAccount]{Repo: c.Hrepo, Telemetry: s.Telemetry}
+		c.SSOHandler = &sso.TracedHandler{Handler: c.SSOHandler, Telemetry: s.Telemetry}
+		if c.MailValidation != nil {
+			c.MailValidation = &email.TracedSender{Sender: c.MailValidation, Telemetry: s.Telemetry}
+		}
+	}
+	return c
 }
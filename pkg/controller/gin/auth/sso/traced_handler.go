@@ -0,0 +1,38 @@
+package sso
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/telemetry"
+)
+
+var _ Handler = &TracedHandler{}
+
+// TracedHandler : decorator tracing the sso verifications (span + latency / error metrics) ,
+// the span is tagged with the sso type of the request
+//
+// Example :
+//			handler := &sso.TracedHandler{Handler: sso.New(config), Telemetry: t}
+//			acc, err := handler.WithContext(ctx.Request.Context()).VerifyUser(ctx.Request.Header)
+type TracedHandler struct {
+	Handler   Handler
+	Telemetry *telemetry.Telemetry
+
+	// ctx : parent context of the spans (see WithContext)
+	ctx context.Context
+}
+
+// WithContext : view of the handler whose spans are children of the span in the context
+func (h *TracedHandler) WithContext(ctx context.Context) Handler {
+	return &TracedHandler{Handler: h.Handler, Telemetry: h.Telemetry, ctx: ctx}
+}
+
+// VerifyUser : Calls the wrapped Handler to do verification
+func (h *TracedHandler) VerifyUser(req http.Header) (acc *entity.Account, err error) {
+	_, op := h.Telemetry.Start(h.ctx, "sso", "verify_user", "", telemetry.AttrSSOType.String(req.Get("sso_type")))
+	acc, err = h.Handler.VerifyUser(req)
+	op.End(err)
+	return acc, err
+}
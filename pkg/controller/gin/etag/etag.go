@@ -0,0 +1,128 @@
+// Package etag : maps the versions of versioned entities (see entity.BaseVersioned) to http ETag / If-Match headers
+//
+// Example :
+//			func (c *Controller) get(ctx *gin.Context) {
+//				rec, _ := c.Repo.GetById(ctx.Param("id"))
+//				etag.Set(ctx, rec)
+//				ctx.JSON(http.StatusOK, rec)
+//			}
+//
+//			func (c *Controller) update(ctx *gin.Context) {
+//				rec, _ := c.Repo.GetById(ctx.Param("id"))
+//				if !etag.CheckIfMatch(ctx, rec) {
+//					return // 412
+//				}
+//				... apply the body to rec
+//				err := c.Repo.Update(rec)
+//				if etag.AbortOnConflict(ctx, err) {
+//					return // 412
+//				}
+//				etag.Set(ctx, rec)
+//				ctx.JSON(http.StatusOK, rec)
+//			}
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/baderkha/library/pkg/controller/response"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderETag    = "ETag"
+	HeaderIfMatch = "If-Match"
+)
+
+var (
+	ErrPreconditionFailed   = errors.New("the record was modified since it was read , reload it and try again")
+	ErrPreconditionRequired = errors.New("the If-Match header is required to modify this record")
+)
+
+// Format : the ETag of a version (ie `"3"`)
+func Format(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Set : sets the ETag header to the version of the record
+func Set(ctx *gin.Context, v entity.Versioned) {
+	ctx.Header(HeaderETag, Format(v.GetVersion()))
+}
+
+// IfMatch : versions of the If-Match header , isAny is true for `*` , found is false if the header is missing
+// (weak ETags are compared as strong ones , unknown ETags are skipped)
+func IfMatch(ctx *gin.Context) (versions []int64, isAny bool, found bool) {
+	header := strings.TrimSpace(ctx.GetHeader(HeaderIfMatch))
+	if header == "" {
+		return nil, false, false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true, true
+		}
+		unquoted, err := strconv.Unquote(strings.TrimPrefix(tag, "W/"))
+		if err != nil {
+			continue
+		}
+		version, err := strconv.ParseInt(unquoted, 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return versions, false, true
+}
+
+// matches : the If-Match header matches the version of the record
+func matches(ctx *gin.Context, v entity.Versioned) (isMatch bool, found bool) {
+	versions, isAny, found := IfMatch(ctx)
+	if !found || isAny {
+		return true, found
+	}
+	for _, version := range versions {
+		if version == v.GetVersion() {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// CheckIfMatch : aborts with 412 if the If-Match header does not match the version of the record ,
+// a missing header is allowed (use RequireIfMatch to make it mandatory)
+func CheckIfMatch(ctx *gin.Context, v entity.Versioned) bool {
+	isMatch, _ := matches(ctx, v)
+	if !isMatch {
+		ctx.AbortWithStatusJSON(http.StatusPreconditionFailed, response.NewError(ErrPreconditionFailed))
+		return false
+	}
+	return true
+}
+
+// RequireIfMatch : same as CheckIfMatch but aborts with 428 if the If-Match header is missing
+func RequireIfMatch(ctx *gin.Context, v entity.Versioned) bool {
+	isMatch, found := matches(ctx, v)
+	if !found {
+		ctx.AbortWithStatusJSON(http.StatusPreconditionRequired, response.NewError(ErrPreconditionRequired))
+		return false
+	}
+	if !isMatch {
+		ctx.AbortWithStatusJSON(http.StatusPreconditionFailed, response.NewError(ErrPreconditionFailed))
+		return false
+	}
+	return true
+}
+
+// AbortOnConflict : aborts with 412 if the error is a version conflict (see repository.ErrVersionConflict)
+func AbortOnConflict(ctx *gin.Context, err error) bool {
+	if !errors.Is(err, repository.ErrVersionConflict) {
+		return false
+	}
+	ctx.AbortWithStatusJSON(http.StatusPreconditionFailed, response.NewError(ErrPreconditionFailed))
+	return true
+}
@@ -0,0 +1,290 @@
+package view
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/baderkha/library/pkg/controller/response"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/store/repository/gormrepo"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errUnauthorized   = errors.New("Unauthorized")
+	errorNotFound     = errors.New("saved view not found")
+	errRepo           = errors.New("could not transact with repository")
+	errorAccountIDReq = errors.New("account_id is required")
+)
+
+// savedViewObj : the fields of a view a client can set
+type savedViewObj struct {
+	Name       string `json:"name" binding:"required"`
+	EntityName string `json:"entity_name" binding:"required"`
+	Filter     string `json:"filter"`
+	Sort       string `json:"sort"`
+	PageSize   int    `json:"page_size"`
+}
+
+func (o *savedViewObj) apply(v *entity.SavedView) *entity.SavedView {
+	v.Name = o.Name
+	v.EntityName = o.EntityName
+	v.Filter = o.Filter
+	v.Sort = o.Sort
+	v.PageSize = o.PageSize
+	return v
+}
+
+type shareObj struct {
+	AccountID string `json:"account_id" binding:"required"`
+}
+
+// SavedViewGinController : saved filters / views per account
+//
+// 1) create / list / get / update / delete views
+//
+// 2) share views (read only) with other accounts
+//
+// routes expect the `account_id` to be set on the gin context by the auth middleware (see auth.SessionAuthGinController.GetAuthMiddleWare)
+type SavedViewGinController struct {
+	URLPathPrefix string
+	Repo          repository.ISavedView
+	// AuthMiddleWare : sets the `account_id` on the context
+	AuthMiddleWare gin.HandlerFunc
+}
+
+func (c *SavedViewGinController) accountID(ctx *gin.Context) (string, bool) {
+	accID, isFound := ctx.Get("account_id")
+	if !isFound {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.NewError(errUnauthorized))
+		return "", false
+	}
+	id, ok := accID.(string)
+	if !ok || id == "" {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.NewError(errUnauthorized))
+		return "", false
+	}
+	return id, true
+}
+
+// ownedView : view owned by the account in the context , not found otherwise
+func (c *SavedViewGinController) ownedView(ctx *gin.Context) (*entity.SavedView, bool) {
+	accID, ok := c.accountID(ctx)
+	if !ok {
+		return nil, false
+	}
+	id := ctx.Param("id")
+	if !c.Repo.IsOwnedBy(id, accID) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, response.NewError(errorNotFound))
+		return nil, false
+	}
+	v, err := c.Repo.GetById(id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, response.NewError(errorNotFound))
+		return nil, false
+	}
+	return v, true
+}
+
+func (c *SavedViewGinController) create(ctx *gin.Context) {
+	accID, ok := c.accountID(ctx)
+	if !ok {
+		return
+	}
+	var body savedViewObj
+	err := ctx.ShouldBindJSON(&body)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
+		return
+	}
+	var v entity.SavedView
+	v.New()
+	v.AccountID = accID
+	body.apply(&v)
+
+	err = c.Repo.Create(&v)
+	if errors.Is(err, repository.ErrSavedViewInvalid) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
+		return
+	}
+	ctx.JSON(http.StatusCreated, &v)
+}
+
+func (c *SavedViewGinController) list(ctx *gin.Context) {
+	accID, ok := c.accountID(ctx)
+	if !ok {
+		return
+	}
+	views, err := c.Repo.GetVisibleToAccount(accID, ctx.Query("entity_name"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
+		return
+	}
+	ctx.JSON(http.StatusOK, views)
+}
+
+func (c *SavedViewGinController) get(ctx *gin.Context) {
+	accID, ok := c.accountID(ctx)
+	if !ok {
+		return
+	}
+	id := ctx.Param("id")
+	if !c.Repo.CanRead(id, accID) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, response.NewError(errorNotFound))
+		return
+	}
+	v, err := c.Repo.GetById(id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, response.NewError(errorNotFound))
+		return
+	}
+	ctx.JSON(http.StatusOK, v)
+}
+
+func (c *SavedViewGinController) update(ctx *gin.Context) {
+	v, ok := c.ownedView(ctx)
+	if !ok {
+		return
+	}
+	var body savedViewObj
+	err := ctx.ShouldBindJSON(&body)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
+		return
+	}
+	body.apply(v)
+
+	err = c.Repo.Update(v)
+	if errors.Is(err, repository.ErrSavedViewInvalid) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
+		return
+	}
+	ctx.JSON(http.StatusOK, v)
+}
+
+func (c *SavedViewGinController) delete(ctx *gin.Context) {
+	v, ok := c.ownedView(ctx)
+	if !ok {
+		return
+	}
+	err := c.Repo.Delete(v.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
+		return
+	}
+	ctx.JSON(http.StatusOK, map[string]interface{}{"message": "Deleted Saved View " + v.ID})
+}
+
+func (c *SavedViewGinController) listShares(ctx *gin.Context) {
+	v, ok := c.ownedView(ctx)
+	if !ok {
+		return
+	}
+	shares, err := c.Repo.GetShares(v.ID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
+		return
+	}
+	ctx.JSON(http.StatusOK, shares)
+}
+
+func (c *SavedViewGinController) share(ctx *gin.Context) {
+	v, ok := c.ownedView(ctx)
+	if !ok {
+		return
+	}
+	var body shareObj
+	err := ctx.ShouldBindJSON(&body)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
+		return
+	}
+	err = c.Repo.Share(v.ID, body.AccountID)
+	if errors.Is(err, repository.ErrSavedViewShareWithOwner) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(err))
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
+		return
+	}
+	ctx.JSON(http.StatusOK, "ok")
+}
+
+func (c *SavedViewGinController) unshare(ctx *gin.Context) {
+	v, ok := c.ownedView(ctx)
+	if !ok {
+		return
+	}
+	accID := ctx.Param("account_id")
+	if accID == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, response.NewError(errorAccountIDReq))
+		return
+	}
+	err := c.Repo.Unshare(v.ID, accID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.NewError(errRepo))
+		return
+	}
+	ctx.JSON(http.StatusOK, "ok")
+}
+
+func (c *SavedViewGinController) ApplyRoutes(e *gin.Engine) *gin.Engine {
+	grp := e.Group(c.URLPathPrefix)
+	if c.AuthMiddleWare != nil {
+		grp.Use(c.AuthMiddleWare)
+	}
+	{
+		grp.POST("views", c.create)
+		grp.GET("views", c.list)
+		grp.GET("views/:id", c.get)
+		grp.PUT("views/:id", c.update)
+		grp.DELETE("views/:id", c.delete)
+
+		grp.GET("views/:id/shares", c.listShares)
+		grp.POST("views/:id/shares", c.share)
+		grp.DELETE("views/:id/shares/:account_id", c.unshare)
+	}
+	return e
+}
+
+// SavedViewConfig : Saved View Config
+type SavedViewConfig struct {
+	// DB : gorm data base this is required
+	DB *gorm.DB
+	// BasePathRoute : the base uri
+	BasePathRoute string
+	// AuthMiddleWare : middleware setting the `account_id` on the context (see auth.SessionAuthGinController.GetAuthMiddleWare)
+	AuthMiddleWare gin.HandlerFunc
+	// Targets : entity name => target , views can only be saved for these entities (see repository.NewSavedViewTarget)
+	Targets map[string]repository.SavedViewTarget
+	// MaxPageSize : max page size a view can store , default is repository.SavedViewDefaultMaxPageSize
+	MaxPageSize int
+}
+
+func NewGinSavedViewGorm(s *SavedViewConfig) *SavedViewGinController {
+	return &SavedViewGinController{
+		URLPathPrefix:  s.BasePathRoute,
+		AuthMiddleWare: s.AuthMiddleWare,
+		Repo: &repository.SavedViewRepo{
+			Views: &gormrepo.CrudGorm[entity.SavedView]{
+				DB: s.DB,
+			},
+			Shares: &gormrepo.CrudGorm[entity.SavedViewShare]{
+				DB: s.DB,
+			},
+			Targets:     s.Targets,
+			MaxPageSize: s.MaxPageSize,
+		},
+	}
+}
@@ -8,6 +8,8 @@ import (
 const (
 	// DialectMYSQL : use this for mysql dsn generation
 	DialectMYSQL = "MYSQL"
+	// DialectPostgres : use this for postgres dsn generation
+	DialectPostgres = "POSTGRES"
 )
 
 // GetDSN : generate dsn string for the correct sql dialect
@@ -15,6 +17,8 @@ func GetDSN(dialect string, host string, username string, password string, port
 	switch dialect {
 	case DialectMYSQL:
 		return fmt.Sprintf(`%s:%s@%s:%s/%s?%s`, username, password, host, port, database, strings.Join(queryParam, "&"))
+	case DialectPostgres:
+		return fmt.Sprintf(`host=%s user=%s password=%s port=%s dbname=%s %s`, host, username, password, port, database, strings.Join(queryParam, " "))
 	default:
 		return ""
 	}
@@ -0,0 +1,45 @@
+package email
+
+import (
+	"context"
+
+	"github.com/baderkha/library/pkg/telemetry"
+)
+
+var _ ISender = &TracedSender{}
+
+// TracedSender : decorator tracing the emails sent (span + latency / error metrics)
+//
+// Example :
+//			sender := &email.TracedSender{Sender: email.NewSendGridSender(apiKey), Telemetry: t}
+//			err := sender.WithContext(ctx.Request.Context()).SendHTMLEmail(content)
+type TracedSender struct {
+	Sender    ISender
+	Telemetry *telemetry.Telemetry
+
+	// ctx : parent context of the spans (see WithContext)
+	ctx context.Context
+}
+
+// WithContext : view of the sender whose spans are children of the span in the context
+func (s *TracedSender) WithContext(ctx context.Context) ISender {
+	return &TracedSender{Sender: s.Sender, Telemetry: s.Telemetry, ctx: ctx}
+}
+
+// send : runs the send in the span of the operation
+func (s *TracedSender) send(operation string, c *Content, send func(c *Content) error) error {
+	_, op := s.Telemetry.Start(s.ctx, "email", operation, "")
+	err := send(c)
+	op.End(err)
+	return err
+}
+
+// SendEmail : Sends a plain text email to the client
+func (s *TracedSender) SendEmail(c *Content) error {
+	return s.send("send_email", c, s.Sender.SendEmail)
+}
+
+// SendHTMLEmail : Sends an email that is formatted with HTML
+func (s *TracedSender) SendHTMLEmail(c *Content) error {
+	return s.send("send_html_email", c, s.Sender.SendHTMLEmail)
+}
@@ -0,0 +1,118 @@
+package rql
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Col : a typed column reference derived from an entity struct field (see ColumnOf)
+// the predicates only accept values of the field's type
+type Col[V any] struct {
+	name string
+}
+
+// ColumnOf : column reference for a struct field using the `db` tag (the tag the repositories build their schema with)
+// panics if the selector does not return a tagged field of the entity.
+//
+// Example :
+//			email := rql.ColumnOf(func(a *entity.Account) *string { return &a.Email })
+//			f, err := email.Eq("bob@mail.com").Build()
+func ColumnOf[T any, V any](field func(m *T) *V) Col[V] {
+	return ColumnOfTag(field, "db")
+}
+
+// ColumnOfTag : same as ColumnOf but with a custom tag for the column name
+func ColumnOfTag[T any, V any](field func(m *T) *V, filterColTag string) Col[V] {
+	var m T
+	base := reflect.ValueOf(&m)
+	target := reflect.ValueOf(field(&m))
+	name, found := findTaggedField(base.Elem().Type(), target.Pointer()-base.Pointer(), target.Type().Elem(), filterColTag)
+	if !found {
+		panic(fmt.Sprintf("rql : ColumnOf : could not find a field tagged with `%s` of type %s in %s", filterColTag, target.Type().Elem(), base.Elem().Type()))
+	}
+	return Col[V]{name: name}
+}
+
+// findTaggedField : finds the field at the byte offset (walking embedded structs) with the given type
+func findTaggedField(structType reflect.Type, offset uintptr, fieldType reflect.Type, filterColTag string) (string, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if offset < field.Offset || offset >= field.Offset+field.Type.Size() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type != fieldType {
+			if name, found := findTaggedField(field.Type, offset-field.Offset, fieldType, filterColTag); found {
+				return name, true
+			}
+			continue
+		}
+		name := field.Tag.Get(filterColTag)
+		if offset == field.Offset && field.Type == fieldType && name != "" && name != "-" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Name : the column name
+func (c Col[V]) Name() string {
+	return c.name
+}
+
+// Where : untyped column reference
+func (c Col[V]) Where() *ColumnRef {
+	return Where(c.name)
+}
+
+// Eq : column = value
+func (c Col[V]) Eq(value V) *FilterBuilder {
+	return c.Where().Eq(value)
+}
+
+// Ne : column <> value
+func (c Col[V]) Ne(value V) *FilterBuilder {
+	return c.Where().Ne(value)
+}
+
+// Gt : column > value
+func (c Col[V]) Gt(value V) *FilterBuilder {
+	return c.Where().Gt(value)
+}
+
+// Ge : column >= value
+func (c Col[V]) Ge(value V) *FilterBuilder {
+	return c.Where().Ge(value)
+}
+
+// Lt : column < value
+func (c Col[V]) Lt(value V) *FilterBuilder {
+	return c.Where().Lt(value)
+}
+
+// Le : column <= value
+func (c Col[V]) Le(value V) *FilterBuilder {
+	return c.Where().Le(value)
+}
+
+// In : column in (values...)
+func (c Col[V]) In(values ...V) *FilterBuilder {
+	return c.Where().In(toInterfaces(values)...)
+}
+
+// Nin : column not in (values...)
+func (c Col[V]) Nin(values ...V) *FilterBuilder {
+	return c.Where().Nin(toInterfaces(values)...)
+}
+
+// SortBy : start a sort expression on this column
+func (c Col[V]) SortBy() *SortKey {
+	return SortBy(c.name)
+}
+
+func toInterfaces[V any](values []V) []interface{} {
+	out := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		out = append(out, v)
+	}
+	return out
+}
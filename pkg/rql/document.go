@@ -0,0 +1,149 @@
+package rql
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/baderkha/library/pkg/err"
+)
+
+const (
+	// RQLJSONTag : tag label for a column holding a json document , this enables json path column references
+	// ie `settings.theme` filters on the theme key of the settings column.
+	// columns tagged with `tsense_type:"object"` are also considered json columns
+	//
+	// Example :
+	//			Settings datatypes.JSON `json:"settings" db:"settings" rql_json:"1"`
+	RQLJSONTag = "rql_json"
+
+	// RQLArrayTag : tag label for a column holding an array , this enables the array operators on it.
+	// mysql expects a json array column , postgres a native array column where the tag value
+	// can be the element type used to cast the values (ie `rql_array:"int"`)
+	//
+	// Example :
+	//			Tags pq.StringArray `json:"tags" db:"tags" rql_array:"text"`
+	RQLArrayTag = "rql_array"
+
+	typesenseObjectType      = "object"
+	typesenseObjectArrayType = "object[]"
+
+	jsonPathSeparator = "."
+)
+
+var (
+	ErrArrayColumnNotArray   = err.Compose("RQL : Array : Column `%s` is not an array column , tag it with `rql_array`")
+	ErrArrayValueMalformed   = err.Compose("RQL : Array : value for operation `%s` on column `%s` is malformed : %s")
+	ErrJSONPathOpUnsupported = err.Compose("RQL : JSON : operation `%s` is not supported on the json path `%s`")
+
+	jsonPathSegmentRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	arrayElemTypeRegex   = regexp.MustCompile(`^[a-z][a-z0-9_ ]*$`)
+)
+
+// jsonPath : a column reference into a json document ie `settings.theme` => column `settings` , path [theme]
+type jsonPath struct {
+	column string
+	path   []string
+}
+
+// IsJSONColumn : checks if the column holds a json document (see RQLJSONTag)
+func (s *Schema) IsJSONColumn(col string) bool {
+	tsenseType := s.GetTagValue(col, typesenseTypeTag)
+	return s.CheckTagExists(col, RQLJSONTag) || tsenseType == typesenseObjectType || tsenseType == typesenseObjectArrayType
+}
+
+// IsArrayColumn : checks if the column holds an array (see RQLArrayTag)
+func (s *Schema) IsArrayColumn(col string) bool {
+	return s.CheckTagExists(col, RQLArrayTag) || strings.HasSuffix(s.GetTagValue(col, typesenseTypeTag), "[]")
+}
+
+// arrayElemType : postgres element type of the array column , empty if the values should not be cast
+func (s *Schema) arrayElemType(col string) string {
+	elemType := strings.ToLower(s.GetTagValue(col, RQLArrayTag))
+	if elemType == "1" || elemType == "true" || !arrayElemTypeRegex.MatchString(elemType) {
+		return ""
+	}
+	return elemType
+}
+
+// resolveJSONPath : resolves `col.key.sub_key` when col is a json column , path keys can only be alphanumeric / underscores
+func (s *Schema) resolveJSONPath(col string) (*jsonPath, bool) {
+	segments := strings.Split(col, jsonPathSeparator)
+	if len(segments) < 2 || !s.IsJSONColumn(segments[0]) {
+		return nil, false
+	}
+	for _, segment := range segments[1:] {
+		if !jsonPathSegmentRegex.MatchString(segment) {
+			return nil, false
+		}
+	}
+	return &jsonPath{column: segments[0], path: segments[1:]}, true
+}
+
+// IsJSONPath : checks if the column reference is a valid path into a json column
+func (s *Schema) IsJSONPath(col string) bool {
+	_, ok := s.resolveJSONPath(col)
+	return ok
+}
+
+// DoesColOrJSONPathExist : checks if the column exists or is a valid path into a json column
+func (s *Schema) DoesColOrJSONPathExist(col string) bool {
+	return s.DoesColExist(col) || s.IsJSONPath(col)
+}
+
+func isArrayOperator(op string) bool {
+	return op == filterContainsAny || op == filterContainsAll || op == filterLength
+}
+
+// arrayValues : the values of a contains_any / contains_all filter , scalars are treated as a list of 1
+func arrayValues(filter *FilterExpression) ([]interface{}, error) {
+	values := flattenValues(nil, filter.Value)
+	if len(values) == 0 {
+		return nil, ErrArrayValueMalformed(filter.Op, filter.Column, "expected at least 1 value")
+	}
+	return values, nil
+}
+
+// arrayLength : the value of a length filter , must be a non negative whole number
+func arrayLength(filter *FilterExpression) (int64, error) {
+	rv := reflect.ValueOf(filter.Value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() >= 0 {
+			return rv.Int(), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		// json numbers are decoded as floats
+		if f := rv.Float(); f >= 0 && f == float64(int64(f)) {
+			return int64(f), nil
+		}
+	}
+	return 0, ErrArrayValueMalformed(filter.Op, filter.Column, "expected a non negative whole number")
+}
+
+// validateArrayFilter : makes sure the column is an array column (or a json path) and the value is well formed
+func validateArrayFilter(filter *FilterExpression, schema *Schema) error {
+	if !schema.IsArrayColumn(filter.Column) && !schema.IsJSONPath(filter.Column) {
+		return ErrArrayColumnNotArray(filter.Column)
+	}
+	if filter.Value == nil {
+		return nil
+	}
+	var err error
+	if filter.Op == filterLength {
+		_, err = arrayLength(filter)
+	} else {
+		_, err = arrayValues(filter)
+	}
+	return err
+}
+
+// validateJSONPathFilter : geo , full text and fuzzy operators need a real column
+func validateJSONPathFilter(filter *FilterExpression) error {
+	if isGeoOperator(filter.Op) || filter.Op == filterSearch || filter.Op == filterFuzzy {
+		return ErrJSONPathOpUnsupported(filter.Op, filter.Column)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package rql
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/baderkha/library/pkg/err"
+)
+
+const (
+	// FacetDefaultMaxValues : number of values counted per column if not set
+	FacetDefaultMaxValues = 10
+	// FacetMaxValuesLimit : largest number of values that can be counted per column
+	FacetMaxValuesLimit = 250
+)
+
+var (
+	ErrFacetEmpty = errors.New("RQL : FacetExpression must have at least 1 column")
+
+	ErrFacetColumnDoesntExist = err.Compose("RQL : FacetExpression Column `%s` Does Not Exist")
+	ErrFacetColumnUnsupported = err.Compose("RQL : FacetExpression Column `%s` cannot be faceted , geo , json and array columns are not supported")
+	ErrFacetMaxValues         = err.Compose("RQL : FacetExpression max values must be between 1 and %d got %s")
+
+	typesenseNumericTypes = map[string]bool{
+		"int32": true,
+		"int64": true,
+		"float": true,
+	}
+)
+
+// IsNumericColumn : checks if the column holds numbers (using its typesense type) , stats are computed for numeric facets
+func (s *Schema) IsNumericColumn(col string) bool {
+	return typesenseNumericTypes[s.GetTagValue(col, typesenseTypeTag)]
+}
+
+// FacetExpression : columns to count the values of alongside a filtered result
+type FacetExpression struct {
+	columns   []string
+	maxValues int
+}
+
+// FacetBy : facet expression for the columns
+//
+// Example :
+//			facets := rql.FacetBy("brand", "category").MaxValues(20)
+//			res, err := repo.GetWithFilterExpressionFaceted(f, p, s, facets)
+func FacetBy(cols ...string) *FacetExpression {
+	return &FacetExpression{
+		columns:   cols,
+		maxValues: FacetDefaultMaxValues,
+	}
+}
+
+// FacetExpressionFromUserInput : comma separated columns ie `brand,category` , maxValues is optional
+func FacetExpressionFromUserInput(columns string, maxValues string) (*FacetExpression, error) {
+	var cols []string
+	for _, col := range strings.Split(columns, ",") {
+		col = strings.TrimSpace(col)
+		if col != "" {
+			cols = append(cols, col)
+		}
+	}
+	if len(cols) == 0 {
+		return nil, ErrFacetEmpty
+	}
+	f := FacetBy(cols...)
+	if maxValues == "" {
+		return f, nil
+	}
+	n, err := strconv.Atoi(maxValues)
+	if err != nil || n < 1 || n > FacetMaxValuesLimit {
+		return nil, ErrFacetMaxValues(FacetMaxValuesLimit, maxValues)
+	}
+	return f.MaxValues(n), nil
+}
+
+// MaxValues : number of values counted per column (the most frequent ones) , clamped to FacetMaxValuesLimit
+func (f *FacetExpression) MaxValues(n int) *FacetExpression {
+	switch {
+	case n < 1:
+		n = FacetDefaultMaxValues
+	case n > FacetMaxValuesLimit:
+		n = FacetMaxValuesLimit
+	}
+	f.maxValues = n
+	return f
+}
+
+// Columns : the faceted columns
+func (f *FacetExpression) Columns() []string {
+	return f.columns
+}
+
+// GetMaxValues : number of values counted per column
+func (f *FacetExpression) GetMaxValues() int {
+	return f.maxValues
+}
+
+// Validate : validates the columns against the schema
+func (f *FacetExpression) Validate(schema *Schema) error {
+	if len(f.columns) == 0 {
+		return ErrFacetEmpty
+	}
+	for _, col := range f.columns {
+		if !schema.DoesColExist(col) {
+			return ErrFacetColumnDoesntExist(col)
+		}
+	}
+	return nil
+}
+
+// ValidateForSQL : validates the columns against the schema , sql facets are grouped by the column value
+// so geo , json and array columns cannot be faceted
+func (f *FacetExpression) ValidateForSQL(schema *Schema) error {
+	err := f.Validate(schema)
+	if err != nil {
+		return err
+	}
+	for _, col := range f.columns {
+		if schema.IsGeoColumn(col) || schema.IsJSONColumn(col) || schema.IsArrayColumn(col) {
+			return ErrFacetColumnUnsupported(col)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package rql
+
+import (
+	"errors"
+
+	"github.com/baderkha/library/pkg/err"
+)
+
+var (
+	ErrBuilderEmptyValues      = err.Compose("RQL : Builder : Column `%s` operation `%s` needs at least 1 value")
+	ErrBuilderNilValue         = err.Compose("RQL : Builder : Column `%s` operation `%s` cannot have a nil value , use a variable instead")
+	ErrBuilderColumnNotFound   = err.Compose("RQL : Builder : Column `%s` does not exist")
+	ErrBuilderColumnNameEmpty  = errors.New("RQL : Builder : column name cannot be empty")
+	ErrBuilderGroupHasNoFilter = errors.New("RQL : Builder : and / or groups need at least 1 filter")
+)
+
+// FilterBuilder : fluent builder for filter expressions
+//
+// Example :
+//			f, err := rql.Where("status").Eq("active").
+//				And(rql.Or(rql.Where("age").Gt(18), rql.Where("is_verified").Eq(true))).
+//				Build()
+type FilterBuilder struct {
+	expr *FilterExpression
+	errs []error
+}
+
+// ColumnRef : a column to build a predicate on , see Where
+type ColumnRef struct {
+	name string
+}
+
+// Where : start a predicate on a column , json columns can be referenced by path (see RQLJSONTag)
+//
+// Example :
+//			rql.Where("settings.theme").Eq("dark")
+func Where(col string) *ColumnRef {
+	return &ColumnRef{name: col}
+}
+
+func (c *ColumnRef) predicate(op string, value interface{}) *FilterBuilder {
+	b := &FilterBuilder{expr: &FilterExpression{Column: c.name, Op: op, Value: value}}
+	if c.name == "" {
+		b.errs = append(b.errs, ErrBuilderColumnNameEmpty)
+	}
+	if value == nil {
+		b.errs = append(b.errs, ErrBuilderNilValue(c.name, op))
+	}
+	return b
+}
+
+// multiPredicate : slices passed as a single value are spread ie In([]string{"a","b"}) == In("a","b")
+func (c *ColumnRef) multiPredicate(op string, values []interface{}) *FilterBuilder {
+	var flat []interface{}
+	for _, v := range values {
+		flat = flattenValues(flat, v)
+	}
+	values = flat
+	b := c.predicate(op, values)
+	if len(values) == 0 {
+		b.errs = append(b.errs, ErrBuilderEmptyValues(c.name, op))
+	}
+	return b
+}
+
+// Eq : column = value
+func (c *ColumnRef) Eq(value interface{}) *FilterBuilder {
+	return c.predicate(filterEq, value)
+}
+
+// Ne : column <> value
+func (c *ColumnRef) Ne(value interface{}) *FilterBuilder {
+	return c.predicate(filterNe, value)
+}
+
+// Gt : column > value
+func (c *ColumnRef) Gt(value interface{}) *FilterBuilder {
+	return c.predicate(filterGt, value)
+}
+
+// Ge : column >= value
+func (c *ColumnRef) Ge(value interface{}) *FilterBuilder {
+	return c.predicate(filterGe, value)
+}
+
+// Lt : column < value
+func (c *ColumnRef) Lt(value interface{}) *FilterBuilder {
+	return c.predicate(filterLt, value)
+}
+
+// Le : column <= value
+func (c *ColumnRef) Le(value interface{}) *FilterBuilder {
+	return c.predicate(filterLe, value)
+}
+
+// Like : column like pattern
+func (c *ColumnRef) Like(pattern string) *FilterBuilder {
+	return c.predicate(filterLike, pattern)
+}
+
+// NotLike : column not like pattern
+func (c *ColumnRef) NotLike(pattern string) *FilterBuilder {
+	return c.predicate(filterNotLike, pattern)
+}
+
+// IsNull : column is null
+func (c *ColumnRef) IsNull() *FilterBuilder {
+	return c.predicate(filterPresent, false)
+}
+
+// IsNotNull : column is not null
+func (c *ColumnRef) IsNotNull() *FilterBuilder {
+	return c.predicate(filterPresent, true)
+}
+
+// Fuzzy : fuzzy search on the column
+func (c *ColumnRef) Fuzzy(term string) *FilterBuilder {
+	return c.predicate(filterFuzzy, term)
+}
+
+// Search : full text search on the column
+func (c *ColumnRef) Search(term string) *FilterBuilder {
+	return c.predicate(filterSearch, term)
+}
+
+// In : column in (values...)
+func (c *ColumnRef) In(values ...interface{}) *FilterBuilder {
+	return c.multiPredicate(filterIn, values)
+}
+
+// Nin : column not in (values...)
+func (c *ColumnRef) Nin(values ...interface{}) *FilterBuilder {
+	return c.multiPredicate(filterNin, values)
+}
+
+// ContainsAny : array column has at least 1 of the values
+func (c *ColumnRef) ContainsAny(values ...interface{}) *FilterBuilder {
+	return c.multiPredicate(filterContainsAny, values)
+}
+
+// ContainsAll : array column has every one of the values
+func (c *ColumnRef) ContainsAll(values ...interface{}) *FilterBuilder {
+	return c.multiPredicate(filterContainsAll, values)
+}
+
+// Length : array column has exactly n items
+func (c *ColumnRef) Length(n int) *FilterBuilder {
+	return c.predicate(filterLength, n)
+}
+
+// WithinRadius : geo point within radiusKm of the center
+func (c *ColumnRef) WithinRadius(center GeoPoint, radiusKm float64) *FilterBuilder {
+	return c.predicate(filterGeoRadius, GeoRadius{Center: center, RadiusKm: radiusKm})
+}
+
+// WithinBoundingBox : geo point inside of the box
+func (c *ColumnRef) WithinBoundingBox(southWest GeoPoint, northEast GeoPoint) *FilterBuilder {
+	return c.predicate(filterGeoBBox, GeoBoundingBox{SouthWest: southWest, NorthEast: northEast})
+}
+
+// WithinPolygon : geo point inside of the polygon
+func (c *ColumnRef) WithinPolygon(points ...GeoPoint) *FilterBuilder {
+	return c.predicate(filterGeoPolygon, GeoPolygon{Points: points})
+}
+
+// Var : predicate with a variable instead of a value (see FilterExpression.MapVariablesToValue)
+//
+// Example :
+//			rql.Where("account_id").Var("eq", "current_account")
+func (c *ColumnRef) Var(op string, variable string) *FilterBuilder {
+	b := &FilterBuilder{expr: &FilterExpression{Column: c.name, Op: op, Variable: &variable}}
+	if c.name == "" {
+		b.errs = append(b.errs, ErrBuilderColumnNameEmpty)
+	}
+	return b
+}
+
+// And : all of the filters must match
+func And(filters ...*FilterBuilder) *FilterBuilder {
+	return group(ANDOperator, filters)
+}
+
+// Or : any of the filters must match
+func Or(filters ...*FilterBuilder) *FilterBuilder {
+	return group(OROperator, filters)
+}
+
+func group(op string, filters []*FilterBuilder) *FilterBuilder {
+	b := &FilterBuilder{expr: &FilterExpression{BinaryOperation: op}}
+	if len(filters) == 0 {
+		b.errs = append(b.errs, ErrBuilderGroupHasNoFilter)
+	}
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		b.errs = append(b.errs, f.errs...)
+		// same operation groups are flattened into this one
+		if f.expr.BinaryOperation == op && !f.expr.isLeaf() {
+			b.expr.Properties = append(b.expr.Properties, f.expr.Properties...)
+		} else {
+			b.expr.Properties = append(b.expr.Properties, f.expr)
+		}
+	}
+	return b
+}
+
+// And : this filter and all the others must match
+func (b *FilterBuilder) And(filters ...*FilterBuilder) *FilterBuilder {
+	return And(append([]*FilterBuilder{b}, filters...)...)
+}
+
+// Or : this filter or any of the others must match
+func (b *FilterBuilder) Or(filters ...*FilterBuilder) *FilterBuilder {
+	return Or(append([]*FilterBuilder{b}, filters...)...)
+}
+
+// Build : returns the filter expression or the first error found while building it
+func (b *FilterBuilder) Build() (*FilterExpression, error) {
+	if len(b.errs) > 0 {
+		return nil, b.errs[0]
+	}
+	if b.expr.isLeaf() {
+		return &FilterExpression{BinaryOperation: ANDOperator, Properties: []*FilterExpression{b.expr}}, nil
+	}
+	return b.expr, nil
+}
+
+// MustBuild : same as Build but panics on error
+func (b *FilterBuilder) MustBuild() *FilterExpression {
+	f, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// BuildForSchema : Build and make sure every column exists in the schema (and geo / array operators target geo / array columns)
+func (b *FilterBuilder) BuildForSchema(schema *Schema) (*FilterExpression, error) {
+	f, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	err = validateBuiltColumns(f, schema)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func validateBuiltColumns(f *FilterExpression, schema *Schema) error {
+	if f.isLeaf() {
+		if !schema.DoesColOrJSONPathExist(f.Column) {
+			return ErrBuilderColumnNotFound(f.Column)
+		}
+		if !schema.DoesColExist(f.Column) {
+			err := validateJSONPathFilter(f)
+			if err != nil {
+				return err
+			}
+		}
+		switch {
+		case isGeoOperator(f.Op):
+			return validateGeoFilter(f, schema)
+		case isArrayOperator(f.Op):
+			return validateArrayFilter(f, schema)
+		}
+		return nil
+	}
+	for _, prop := range f.Properties {
+		err := validateBuiltColumns(prop, schema)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
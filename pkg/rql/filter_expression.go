@@ -4,8 +4,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/baderkha/library/pkg/conditional"
+	"github.com/baderkha/library/pkg/err"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -20,6 +22,28 @@ const (
 	filterIn    = "in"
 	filterNin   = "nin"
 	filterFuzzy = "fuzzy"
+	// filterNotLike : negation of like
+	filterNotLike = "not_like"
+	// filterPresent : value true => column is not null , false => column is null
+	filterPresent = "present"
+	// filterSearch : full text search , typesense query_by or the sql full text index (see RQLFullTextTag)
+	filterSearch = "search"
+
+	// geo operators , see GeoRadius , GeoBoundingBox , GeoPolygon for the expected values
+	filterGeoRadius  = "geo_radius"
+	filterGeoBBox    = "geo_bbox"
+	filterGeoPolygon = "geo_polygon"
+
+	// array operators , see RQLArrayTag . contains_any / contains_all expect a list of values and length a whole number
+	filterContainsAny = "contains_any"
+	filterContainsAll = "contains_all"
+	filterLength      = "length"
+)
+
+var (
+	ErrPresentValueMustBeBool = err.Compose("RQL : Column `%s` operation `present` expects a boolean value")
+
+	likePatternEscapeRepl = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 )
 
 // FilterExpression : recursive filter expression that can be used to do complex binary logic filtering
@@ -91,3 +115,20 @@ func FilterExpressionFromUserInput(expr string, isBase64 bool) (*FilterExpressio
 	}
 	return &f, nil
 }
+
+// presentValue : value of a `present` filter , true => not null
+func presentValue(filter *FilterExpression) (bool, error) {
+	isPresent, ok := filter.Value.(bool)
+	if !ok {
+		return false, ErrPresentValueMustBeBool(filter.Column)
+	}
+	return isPresent, nil
+}
+
+// EscapeLikePattern : escapes the like wildcards (% and _) so the value is matched literally
+//
+// Example :
+//			rql.Where("name").Like("%" + rql.EscapeLikePattern(userInput) + "%")
+func EscapeLikePattern(value string) string {
+	return likePatternEscapeRepl.Replace(value)
+}
@@ -0,0 +1,73 @@
+package rql
+
+import "github.com/baderkha/library/pkg/err"
+
+var (
+	ErrCannotNegateOperation = err.Compose("RQL : operation `%s` on column `%s` cannot be negated")
+
+	negatedOps = map[string]string{
+		filterEq:      filterNe,
+		filterNe:      filterEq,
+		filterGt:      filterLe,
+		filterGe:      filterLt,
+		filterLt:      filterGe,
+		filterLe:      filterGt,
+		filterIn:      filterNin,
+		filterNin:     filterIn,
+		filterLike:    filterNotLike,
+		filterNotLike: filterLike,
+	}
+)
+
+// Negate : returns a copy of the expression that matches what the original does not (the original is not modified).
+//
+// the negation is pushed down to the predicates (de morgan) since the expression has no not operator ,
+// ie `not (a eq 1 AND b gt 2)` => `a ne 1 OR b le 2`.
+// note that sql comparisons with null are neither true nor false so negated comparisons do not match nulls either
+func (f *FilterExpression) Negate() (*FilterExpression, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if f.isLeaf() {
+		return negateLeaf(f)
+	}
+	negated := &FilterExpression{
+		BinaryOperation: OROperator,
+		Properties:      make([]*FilterExpression, 0, len(f.Properties)),
+	}
+	if f.BinaryOperation == OROperator {
+		negated.BinaryOperation = ANDOperator
+	}
+	for _, prop := range f.Properties {
+		if prop == nil {
+			continue
+		}
+		negatedProp, err := prop.Negate()
+		if err != nil {
+			return nil, err
+		}
+		negated.Properties = append(negated.Properties, negatedProp)
+	}
+	return negated, nil
+}
+
+func negateLeaf(f *FilterExpression) (*FilterExpression, error) {
+	leaf := &FilterExpression{
+		Column:   f.Column,
+		Op:       negatedOps[f.Op],
+		Value:    f.Value,
+		Variable: f.Variable,
+	}
+	if f.Op == filterPresent && f.Variable == nil {
+		isPresent, err := presentValue(f)
+		if err != nil {
+			return nil, err
+		}
+		leaf.Op = filterPresent
+		leaf.Value = !isPresent
+	}
+	if leaf.Op == "" {
+		return nil, ErrCannotNegateOperation(f.Op, f.Column)
+	}
+	return leaf, nil
+}
@@ -0,0 +1,210 @@
+package rql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Normalize : returns a canonical copy of the filter expression , the original is not modified.
+//
+// 1) nested groups with the same operation are flattened into their parent
+//
+// 2) empty groups are removed and groups with a single property are replaced by that property
+//
+// 3) `eq` / `in` predicates on the same column under an OR are merged into 1 `in`
+//
+// 4) duplicated predicates are removed
+//
+// 5) properties (and `in` / `nin` / `contains_any` / `contains_all` values) are sorted into a stable order
+//
+// the result always is a group (ie it has a BinaryOperation) so it can be fed to any of the parsers
+func (f *FilterExpression) Normalize() *FilterExpression {
+	if f == nil {
+		return nil
+	}
+	if f.isLeaf() {
+		return &FilterExpression{BinaryOperation: ANDOperator, Properties: []*FilterExpression{normalizeLeaf(f)}}
+	}
+	rootOp := f.BinaryOperation
+	if rootOp == "" {
+		rootOp = ANDOperator
+	}
+	// the root operation defaults to AND , set it before normalizing so a group of 2+ properties keeps it
+	root := *f
+	root.BinaryOperation = rootOp
+	normalized := normalizeGroup(&root)
+	switch {
+	case normalized == nil:
+		return &FilterExpression{BinaryOperation: rootOp}
+	case normalized.isLeaf():
+		return &FilterExpression{BinaryOperation: rootOp, Properties: []*FilterExpression{normalized}}
+	}
+	return normalized
+}
+
+// Hash : stable sha256 hash of the canonical form (see Normalize) , usable as a cache key.
+// expressions that only differ by nesting / ordering / duplicates produce the same hash
+func (f *FilterExpression) Hash() (string, error) {
+	b, err := json.Marshal(f.Normalize())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (f *FilterExpression) isLeaf() bool {
+	return f.Column != "" && f.Op != ""
+}
+
+func normalizeLeaf(f *FilterExpression) *FilterExpression {
+	leaf := &FilterExpression{
+		Column:   f.Column,
+		Op:       f.Op,
+		Value:    f.Value,
+		Variable: f.Variable,
+	}
+	if leaf.Op == filterIn || leaf.Op == filterNin || leaf.Op == filterContainsAny || leaf.Op == filterContainsAll {
+		leaf.Value = canonicalValues(flattenValues(nil, leaf.Value))
+	}
+	return leaf
+}
+
+// normalizeGroup : returns nil for an empty group and the property itself for a group of 1
+func normalizeGroup(g *FilterExpression) *FilterExpression {
+	var properties []*FilterExpression
+	for _, prop := range g.Properties {
+		if prop == nil {
+			continue
+		}
+		if prop.isLeaf() {
+			properties = append(properties, normalizeLeaf(prop))
+			continue
+		}
+		child := normalizeGroup(prop)
+		switch {
+		case child == nil:
+			continue
+		case !child.isLeaf() && child.BinaryOperation == g.BinaryOperation:
+			properties = append(properties, child.Properties...)
+		default:
+			properties = append(properties, child)
+		}
+	}
+	if g.BinaryOperation == OROperator {
+		properties = mergeEqualsIntoIn(properties)
+	}
+	properties = sortAndDeduplicate(properties)
+
+	switch len(properties) {
+	case 0:
+		return nil
+	case 1:
+		return properties[0]
+	}
+	return &FilterExpression{
+		BinaryOperation: g.BinaryOperation,
+		Properties:      properties,
+	}
+}
+
+// mergeEqualsIntoIn : `a eq 1 OR a eq 2 OR a in [3]` => `a in [1,2,3]`
+func mergeEqualsIntoIn(properties []*FilterExpression) []*FilterExpression {
+	var (
+		merged   []*FilterExpression
+		byColumn = make(map[string][]*FilterExpression)
+		columns  []string
+	)
+	for _, prop := range properties {
+		if !prop.isLeaf() || prop.Variable != nil || (prop.Op != filterEq && prop.Op != filterIn) {
+			merged = append(merged, prop)
+			continue
+		}
+		if _, exists := byColumn[prop.Column]; !exists {
+			columns = append(columns, prop.Column)
+		}
+		byColumn[prop.Column] = append(byColumn[prop.Column], prop)
+	}
+	for _, col := range columns {
+		group := byColumn[col]
+		if len(group) == 1 {
+			merged = append(merged, group[0])
+			continue
+		}
+		var values []interface{}
+		for _, prop := range group {
+			values = flattenValues(values, prop.Value)
+		}
+		merged = append(merged, &FilterExpression{
+			Column: col,
+			Op:     filterIn,
+			Value:  canonicalValues(values),
+		})
+	}
+	return merged
+}
+
+// flattenValues : appends a scalar or every item of a slice to values
+func flattenValues(values []interface{}, value interface{}) []interface{} {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return append(values, value)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		values = append(values, rv.Index(i).Interface())
+	}
+	return values
+}
+
+// canonicalValues : sorted and deduplicated values
+func canonicalValues(values []interface{}) []interface{} {
+	var (
+		out  = make([]interface{}, 0, len(values))
+		keys = make(map[string]bool, len(values))
+	)
+	for _, v := range values {
+		key := canonicalKey(v)
+		if keys[key] {
+			continue
+		}
+		keys[key] = true
+		out = append(out, v)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return canonicalKey(out[i]) < canonicalKey(out[j])
+	})
+	return out
+}
+
+func sortAndDeduplicate(properties []*FilterExpression) []*FilterExpression {
+	var (
+		out  = make([]*FilterExpression, 0, len(properties))
+		keys = make(map[string]bool, len(properties))
+	)
+	for _, prop := range properties {
+		key := canonicalKey(prop)
+		if keys[key] {
+			continue
+		}
+		keys[key] = true
+		out = append(out, prop)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return canonicalKey(out[i]) < canonicalKey(out[j])
+	})
+	return out
+}
+
+// canonicalKey : json is deterministic for our purposes (struct field order , sorted map keys) ,
+// values json cannot encode fall back to their go syntax so distinct values never share a key
+func canonicalKey(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%T:%#v", v, v)
+	}
+	return string(b)
+}
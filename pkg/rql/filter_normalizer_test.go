@@ -0,0 +1,157 @@
+package rql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func leaf(col string, op string, value interface{}) *FilterExpression {
+	return &FilterExpression{Column: col, Op: op, Value: value}
+}
+
+func groupOf(op string, properties ...*FilterExpression) *FilterExpression {
+	return &FilterExpression{BinaryOperation: op, Properties: properties}
+}
+
+func mustHash(t *testing.T, f *FilterExpression) string {
+	t.Helper()
+	h, err := f.Hash()
+	if err != nil {
+		t.Fatalf("hash : %v", err)
+	}
+	return h
+}
+
+func TestNormalizeRootOperation(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *FilterExpression
+		want string
+	}{
+		{name: "empty root op with 2 properties", in: groupOf("", leaf("a", filterEq, 1), leaf("b", filterEq, 2)), want: ANDOperator},
+		{name: "empty root op with 1 property", in: groupOf("", leaf("a", filterEq, 1)), want: ANDOperator},
+		{name: "empty root op without properties", in: groupOf(""), want: ANDOperator},
+		{name: "leaf", in: leaf("a", filterEq, 1), want: ANDOperator},
+		{name: "or root", in: groupOf(OROperator, leaf("a", filterGt, 1), leaf("b", filterEq, 2)), want: OROperator},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in.Normalize()
+			if got.BinaryOperation != tt.want {
+				t.Fatalf("root operation = %q , want %q", got.BinaryOperation, tt.want)
+			}
+			if got.isLeaf() {
+				t.Fatalf("normalized expression is a leaf")
+			}
+		})
+	}
+}
+
+func TestHashEquivalentExpressions(t *testing.T) {
+	tests := []struct {
+		name string
+		a    *FilterExpression
+		b    *FilterExpression
+	}{
+		{
+			name: "property order",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1), leaf("b", filterEq, 2)),
+			b:    groupOf(ANDOperator, leaf("b", filterEq, 2), leaf("a", filterEq, 1)),
+		},
+		{
+			name: "nested groups of the same operation",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1), groupOf(ANDOperator, leaf("b", filterEq, 2), leaf("c", filterEq, 3))),
+			b:    groupOf(ANDOperator, leaf("a", filterEq, 1), leaf("b", filterEq, 2), leaf("c", filterEq, 3)),
+		},
+		{
+			name: "duplicates",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1), leaf("a", filterEq, 1)),
+			b:    groupOf(ANDOperator, leaf("a", filterEq, 1)),
+		},
+		{
+			name: "leaf and group of 1",
+			a:    leaf("a", filterEq, 1),
+			b:    groupOf(ANDOperator, leaf("a", filterEq, 1)),
+		},
+		{
+			name: "empty root op and AND",
+			a:    groupOf("", leaf("a", filterEq, 1), leaf("b", filterEq, 2)),
+			b:    groupOf(ANDOperator, leaf("a", filterEq, 1), leaf("b", filterEq, 2)),
+		},
+		{
+			name: "eq under or merged into in",
+			a:    groupOf(OROperator, leaf("a", filterEq, 1), leaf("a", filterEq, 2), leaf("b", filterGt, 3)),
+			b:    groupOf(OROperator, leaf("b", filterGt, 3), leaf("a", filterIn, []interface{}{2, 1})),
+		},
+		{
+			name: "in values order and duplicates",
+			a:    groupOf(ANDOperator, leaf("a", filterIn, []interface{}{"x", "y", "x"})),
+			b:    groupOf(ANDOperator, leaf("a", filterIn, []string{"y", "x"})),
+		},
+		{
+			name: "empty nested group",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1), groupOf(OROperator)),
+			b:    groupOf(ANDOperator, leaf("a", filterEq, 1)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if mustHash(t, tt.a) != mustHash(t, tt.b) {
+				a, _ := json.Marshal(tt.a.Normalize())
+				b, _ := json.Marshal(tt.b.Normalize())
+				t.Fatalf("hashes differ\n%s\n%s", a, b)
+			}
+		})
+	}
+}
+
+func TestHashDistinctExpressions(t *testing.T) {
+	tests := []struct {
+		name string
+		a    *FilterExpression
+		b    *FilterExpression
+	}{
+		{
+			name: "operation",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1), leaf("b", filterEq, 2)),
+			b:    groupOf(OROperator, leaf("a", filterEq, 1), leaf("b", filterEq, 2)),
+		},
+		{
+			name: "value",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1)),
+			b:    groupOf(ANDOperator, leaf("a", filterEq, 2)),
+		},
+		{
+			name: "value type",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1)),
+			b:    groupOf(ANDOperator, leaf("a", filterEq, "1")),
+		},
+		{
+			name: "operator",
+			a:    groupOf(ANDOperator, leaf("a", filterGt, 1)),
+			b:    groupOf(ANDOperator, leaf("a", filterGe, 1)),
+		},
+		{
+			name: "column",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1)),
+			b:    groupOf(ANDOperator, leaf("b", filterEq, 1)),
+		},
+		{
+			name: "eq under and is not merged into in",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1), leaf("a", filterEq, 2)),
+			b:    groupOf(ANDOperator, leaf("a", filterIn, []interface{}{1, 2})),
+		},
+		{
+			name: "nesting of different operations",
+			a:    groupOf(ANDOperator, leaf("a", filterEq, 1), groupOf(OROperator, leaf("b", filterEq, 2), leaf("c", filterEq, 3))),
+			b:    groupOf(OROperator, leaf("a", filterEq, 1), groupOf(ANDOperator, leaf("b", filterEq, 2), leaf("c", filterEq, 3))),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if mustHash(t, tt.a) == mustHash(t, tt.b) {
+				t.Fatalf("hashes are equal")
+			}
+		})
+	}
+}
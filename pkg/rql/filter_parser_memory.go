@@ -0,0 +1,344 @@
+package rql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baderkha/library/pkg/conditional"
+	"github.com/baderkha/library/pkg/err"
+)
+
+var (
+	MemErrBoolOp               = err.Compose("RQL : Memory : FilterParser : unsupported boolean operation `%s` expected either `%s`,`%s`")
+	MemErrColumnNotFound       = err.Compose("RQL : Memory : FilterParser : Column `%s` does not exist")
+	MemErrOperatorNotSupported = err.Compose("RQL : Memory : FilterParser : this operator %s is not supported")
+	MemErrPatternMustBeString  = err.Compose("RQL : Memory : FilterParser : Column `%s` operation `%s` expects a string value")
+
+	MemErrVariables = errors.New("RQL : Memory : FilterParser : you cannot have variables and values set or null . it's either one or the other being set or null")
+)
+
+// MemoryRecord : a record as column internal name => value , time columns are time.Time and nulls are nil
+type MemoryRecord map[string]interface{}
+
+// MemoryPredicate : compiled filter expression , true if the record matches
+type MemoryPredicate func(record MemoryRecord) bool
+
+var _ IFilterParser[MemoryPredicate] = &FilterParserMemory{}
+
+// FilterParserMemory : compiles filter expressions into predicates evaluated in memory (ie embedded key value stores)
+//
+// comparisons follow sql : null values never match a comparison (use the present operator) ,
+// like is case sensitive and fuzzy / search are case insensitive substring matches . geo operators and json paths are not supported
+//
+// Example :
+//			match, err := rql.NewMemoryFilterParser().Parse(f, schema)
+//			if (*match)(rql.MemoryRecord{"name": "bob", "age": 31}) { ... }
+type FilterParserMemory struct{}
+
+// NewMemoryFilterParser : in memory filter parser
+func NewMemoryFilterParser() *FilterParserMemory {
+	return &FilterParserMemory{}
+}
+
+// Parse : compiles the expression , an empty expression matches every record
+func (m *FilterParserMemory) Parse(expression *FilterExpression, schema *Schema) (*MemoryPredicate, error) {
+	match, err := m.parse(expression, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+func (m *FilterParserMemory) parse(expression *FilterExpression, schema *Schema) (MemoryPredicate, error) {
+	if expression == nil {
+		return func(MemoryRecord) bool { return true }, nil
+	}
+	if expression.isLeaf() {
+		return m.parseLeaf(expression, schema)
+	}
+	isOr := expression.BinaryOperation == OROperator
+	if !isOr && expression.BinaryOperation != ANDOperator {
+		return nil, MemErrBoolOp(expression.BinaryOperation, ANDOperator, OROperator)
+	}
+	var children []MemoryPredicate
+	for _, prop := range expression.Properties {
+		if prop == nil || (!prop.isLeaf() && len(prop.Properties) == 0) {
+			continue
+		}
+		child, err := m.parse(prop, schema)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	return func(record MemoryRecord) bool {
+		for _, child := range children {
+			if child(record) == isOr {
+				return isOr
+			}
+		}
+		return !isOr || len(children) == 0
+	}, nil
+}
+
+func (m *FilterParserMemory) parseLeaf(filter *FilterExpression, schema *Schema) (MemoryPredicate, error) {
+	if !schema.DoesColExist(filter.Column) {
+		return nil, MemErrColumnNotFound(filter.Column)
+	}
+	if (filter.Value != nil && filter.Variable != nil) || (filter.Value == nil && filter.Variable == nil) {
+		return nil, MemErrVariables
+	}
+	var (
+		col   = schema.GetColumnInternalName(filter.Column)
+		value = filter.Value
+	)
+	compare := func(cmp func(int) bool) MemoryPredicate {
+		return func(record MemoryRecord) bool {
+			res, ok := compareMemoryValues(record[col], value)
+			return ok && cmp(res)
+		}
+	}
+	switch filter.Op {
+	case filterEq:
+		return compare(func(res int) bool { return res == 0 }), nil
+	case filterNe:
+		return compare(func(res int) bool { return res != 0 }), nil
+	case filterGt:
+		return compare(func(res int) bool { return res > 0 }), nil
+	case filterGe:
+		return compare(func(res int) bool { return res >= 0 }), nil
+	case filterLt:
+		return compare(func(res int) bool { return res < 0 }), nil
+	case filterLe:
+		return compare(func(res int) bool { return res <= 0 }), nil
+	case filterIn, filterNin:
+		values := flattenValues(nil, value)
+		isIn := filter.Op == filterIn
+		return func(record MemoryRecord) bool {
+			if record[col] == nil {
+				return false
+			}
+			for _, v := range values {
+				res, ok := compareMemoryValues(record[col], v)
+				if ok && res == 0 {
+					return isIn
+				}
+			}
+			return !isIn
+		}, nil
+	case filterLike, filterNotLike:
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, MemErrPatternMustBeString(filter.Column, filter.Op)
+		}
+		re := likePatternRegexp(pattern)
+		isLike := filter.Op == filterLike
+		return func(record MemoryRecord) bool {
+			if record[col] == nil {
+				return false
+			}
+			return re.MatchString(memoryString(record[col])) == isLike
+		}, nil
+	case filterFuzzy, filterSearch:
+		term, ok := value.(string)
+		if !ok {
+			return nil, MemErrPatternMustBeString(filter.Column, filter.Op)
+		}
+		terms := strings.Fields(strings.ToLower(term))
+		return func(record MemoryRecord) bool {
+			if record[col] == nil {
+				return false
+			}
+			text := strings.ToLower(memoryString(record[col]))
+			for _, term := range terms {
+				if !strings.Contains(text, term) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case filterPresent:
+		isPresent, err := presentValue(filter)
+		if err != nil {
+			return nil, err
+		}
+		return func(record MemoryRecord) bool {
+			return (record[col] != nil) == isPresent
+		}, nil
+	case filterContainsAny, filterContainsAll:
+		values, err := arrayValues(filter)
+		if err != nil {
+			return nil, err
+		}
+		isAll := filter.Op == filterContainsAll
+		return func(record MemoryRecord) bool {
+			elems := memorySlice(record[col])
+			for _, v := range values {
+				if memoryContains(elems, v) != isAll {
+					return !isAll
+				}
+			}
+			return isAll
+		}, nil
+	case filterLength:
+		length, err := arrayLength(filter)
+		if err != nil {
+			return nil, err
+		}
+		return func(record MemoryRecord) bool {
+			return record[col] != nil && int64(len(memorySlice(record[col]))) == length
+		}, nil
+	}
+	return nil, MemErrOperatorNotSupported(filter.Op)
+}
+
+// likePatternRegexp : sql like pattern (% , _ and \ escapes) as an anchored regexp
+func likePatternRegexp(pattern string) *regexp.Regexp {
+	var (
+		expr    strings.Builder
+		escaped bool
+	)
+	expr.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr.WriteString(".*")
+		case r == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// compareMemoryValues : -1 / 0 / 1 comparing the record value to the filter value , false if they cannot be compared (ie null)
+//
+// numbers are compared as floats , times accept time.Time , RFC3339 / date strings and unix seconds
+func compareMemoryValues(recordValue interface{}, value interface{}) (int, bool) {
+	if recordValue == nil || value == nil {
+		return 0, false
+	}
+	switch v := recordValue.(type) {
+	case time.Time:
+		other, ok := memoryTime(value)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case v.Before(other):
+			return -1, true
+		case v.After(other):
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		other, ok := value.(bool)
+		if !ok {
+			parsed, err := strconv.ParseBool(fmt.Sprint(value))
+			if err != nil {
+				return 0, false
+			}
+			other = parsed
+		}
+		return compareFloats(conditional.Ternary(v, 1.0, 0.0), conditional.Ternary(other, 1.0, 0.0)), true
+	}
+	a, isNumber := memoryNumber(recordValue)
+	if !isNumber {
+		return strings.Compare(memoryString(recordValue), memoryString(value)), true
+	}
+	b, ok := memoryNumber(value)
+	if !ok {
+		parsed, err := strconv.ParseFloat(memoryString(value), 64)
+		if err != nil {
+			return 0, false
+		}
+		b = parsed
+	}
+	return compareFloats(a, b), true
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// memoryNumber : the value as a float if it is a number
+func memoryNumber(value interface{}) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// memoryTime : the value as a time (time.Time , RFC3339 / 2006-01-02 strings or unix seconds)
+func memoryTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			t, err := time.Parse(layout, v)
+			if err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	seconds, ok := memoryNumber(value)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+func memoryString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// memorySlice : the elements of an array value , nil if the value is not an array
+func memorySlice(value interface{}) []interface{} {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	return flattenValues(nil, value)
+}
+
+func memoryContains(elems []interface{}, value interface{}) bool {
+	for _, elem := range elems {
+		res, ok := compareMemoryValues(elem, value)
+		if ok && res == 0 {
+			return true
+		}
+	}
+	return false
+}
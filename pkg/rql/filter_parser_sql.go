@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/baderkha/library/pkg/db"
 	"github.com/baderkha/library/pkg/err"
 )
 
@@ -46,14 +47,27 @@ var (
 	filterOps2 = sQLOperators{
 		sQLOperator{Name: filterFuzzy, SQL: "LIKE ?", MultiValue: false},
 		sQLOperator{Name: filterLike, SQL: "like ? ", MultiValue: false},
+		sQLOperator{Name: filterNotLike, SQL: "NOT LIKE ? ", MultiValue: false},
 		sQLOperator{Name: filterGt, SQL: "> ? ", MultiValue: false},
 		sQLOperator{Name: filterGe, SQL: ">= ? ", MultiValue: false},
-		sQLOperator{Name: filterGe, SQL: "< ? ", MultiValue: false},
+		sQLOperator{Name: filterLt, SQL: "< ? ", MultiValue: false},
 		sQLOperator{Name: filterLe, SQL: "<= ? ", MultiValue: false},
 		sQLOperator{Name: filterEq, SQL: "= ? ", MultiValue: false},
 		sQLOperator{Name: filterNe, SQL: "<> ? ", MultiValue: false},
 		sQLOperator{Name: filterIn, SQL: "IN (?) ", MultiValue: true},
 		sQLOperator{Name: filterNin, SQL: "NOT IN (?) ", MultiValue: true},
+		// present has no value to bind see nullSQL
+		sQLOperator{Name: filterPresent, SQL: "", MultiValue: false},
+		// geo operators are resolved per dialect see parseGeo
+		sQLOperator{Name: filterGeoRadius, SQL: "", MultiValue: false},
+		sQLOperator{Name: filterGeoBBox, SQL: "", MultiValue: false},
+		sQLOperator{Name: filterGeoPolygon, SQL: "", MultiValue: false},
+		// full text search is resolved per dialect see parseFullText
+		sQLOperator{Name: filterSearch, SQL: "", MultiValue: false},
+		// array operators are resolved per dialect see parseDocument
+		sQLOperator{Name: filterContainsAny, SQL: "", MultiValue: true},
+		sQLOperator{Name: filterContainsAll, SQL: "", MultiValue: true},
+		sQLOperator{Name: filterLength, SQL: "", MultiValue: false},
 	}
 	errorColumnNotFound = errors.New("column not found")
 )
@@ -81,7 +95,15 @@ func NewSQLFilterValidator() IFilterValidator {
 	return &SQLBaseFilterParser{}
 }
 
+// NewSQLFilterParser : sql filter parser for a specific dialect (see db.DialectMYSQL , db.DialectPostgres)
+// the dialect is only required for the dialect specific operators (ie geo operators)
+func NewSQLFilterParser(dialect string) *SQLBaseFilterParser {
+	return &SQLBaseFilterParser{Dialect: dialect}
+}
+
 type SQLBaseFilterParser struct {
+	// Dialect : sql dialect , leave empty for generic sql
+	Dialect string
 }
 
 func (s *SQLBaseFilterParser) Validate(expression *FilterExpression, schema *Schema) error {
@@ -98,7 +120,7 @@ func (s *SQLBaseFilterParser) Validate(expression *FilterExpression, schema *Sch
 	for i := 0; i < len(properties); i++ {
 		filter := properties[i]
 		if filter.Column != "" && filter.Op != "" {
-			hasCol := schema.DoesColExist(filter.Column)
+			hasCol := schema.DoesColOrJSONPathExist(filter.Column)
 			if !hasCol {
 				return SQLErrorColumnNotFound(filter.Column)
 			}
@@ -112,8 +134,45 @@ func (s *SQLBaseFilterParser) Validate(expression *FilterExpression, schema *Sch
 				return SQLErrVariables
 			}
 
+			if filter.Op == filterPresent && filter.Value != nil {
+				_, err := presentValue(filter)
+				if err != nil {
+					return err
+				}
+			}
+
+			if isGeoOperator(filter.Op) {
+				if s.Dialect != db.DialectMYSQL && s.Dialect != db.DialectPostgres {
+					return SQLErrGeoDialectNotSupported(filter.Op, s.Dialect, db.DialectMYSQL, db.DialectPostgres)
+				}
+				err := validateGeoFilter(filter, schema)
+				if err != nil {
+					return err
+				}
+			}
+
+			if filter.Op == filterSearch {
+				if s.Dialect != db.DialectMYSQL && s.Dialect != db.DialectPostgres {
+					return SQLErrFullTextDialectNotSupported(filter.Op, s.Dialect, db.DialectMYSQL, db.DialectPostgres)
+				}
+				err := validateFullTextFilter(filter, schema)
+				if err != nil {
+					return err
+				}
+			}
+
+			if isDocumentFilter(filter, schema) {
+				err := s.validateDocumentFilter(filter, schema)
+				if err != nil {
+					return err
+				}
+			}
+
 		} else if filter.Properties != nil && len(filter.Properties) > 0 {
-			return s.Validate(filter, schema)
+			err := s.Validate(filter, schema)
+			if err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -147,11 +206,53 @@ func (s SQLBaseFilterParser) ParseRaw(expression *FilterExpression, schema *Sche
 	for i := 0; i < len(properties); i++ {
 		filter := properties[i]
 		if filter.Column != "" && filter.Op != "" && filter.Value != "" {
-			hasCol := schema.DoesColExist(filter.Column)
+			hasCol := schema.DoesColOrJSONPathExist(filter.Column)
 			if !hasCol {
 				return "", nil, SQLErrorColumnNotFound(filter.Column)
 			}
 
+			if isDocumentFilter(filter, schema) {
+				docSQL, docArgs, err := s.parseDocument(filter, schema, schema.GetColumnInternalName(schema.documentColumn(filter.Column)))
+				if err != nil {
+					return "", nil, err
+				}
+				sqlAr = append(sqlAr, " "+docSQL+" ")
+				args = append(args, docArgs...)
+				continue
+			}
+
+			if isGeoOperator(filter.Op) {
+				if !schema.IsGeoColumn(filter.Column) {
+					return "", nil, ErrGeoColumnNotGeo(filter.Column)
+				}
+				geoSQL, geoArgs, err := s.parseGeo(filter, schema.GetColumnInternalName(filter.Column))
+				if err != nil {
+					return "", nil, err
+				}
+				sqlAr = append(sqlAr, " "+geoSQL+" ")
+				args = append(args, geoArgs...)
+				continue
+			}
+
+			if filter.Op == filterSearch {
+				searchSQL, searchArgs, err := s.parseFullText(filter, schema, schema.GetColumnInternalName(filter.Column))
+				if err != nil {
+					return "", nil, err
+				}
+				sqlAr = append(sqlAr, " "+searchSQL+" ")
+				args = append(args, searchArgs...)
+				continue
+			}
+
+			if filter.Op == filterPresent {
+				presentSQL, err := nullSQL(filter, schema.GetColumnInternalName(filter.Column))
+				if err != nil {
+					return "", nil, err
+				}
+				sqlAr = append(sqlAr, " "+presentSQL+" ")
+				continue
+			}
+
 			op, _, err := filterOps2.getOperator(filter.Op)
 			if err != nil {
 				return "", nil, err
@@ -170,3 +271,15 @@ func (s SQLBaseFilterParser) ParseRaw(expression *FilterExpression, schema *Sche
 	}
 	return " ( " + strings.Join(sqlAr, " "+boolOp+" ") + " ) ", args, nil
 }
+
+// nullSQL : `col IS NOT NULL` / `col IS NULL` for the present operator
+func nullSQL(filter *FilterExpression, col string) (string, error) {
+	isPresent, err := presentValue(filter)
+	if err != nil {
+		return "", err
+	}
+	if isPresent {
+		return col + " IS NOT NULL", nil
+	}
+	return col + " IS NULL", nil
+}
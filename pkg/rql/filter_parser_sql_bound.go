@@ -0,0 +1,82 @@
+package rql
+
+// comparison operators of a BoundLeaf
+const (
+	OpEq      = filterEq
+	OpNe      = filterNe
+	OpGt      = filterGt
+	OpGe      = filterGe
+	OpLt      = filterLt
+	OpLe      = filterLe
+	OpLike    = filterLike
+	OpNotLike = filterNotLike
+	OpIn      = filterIn
+	OpNin     = filterNin
+)
+
+// BoundLeaf : a predicate compiled for a sql backend that binds the columns as arguments so its dialect quotes them (see gormrql) ,
+// either a comparison (Op is one of the Op constants) the backend builds itself or a raw condition (SQL with its Args) for the
+// json path , array , geo , full text and present operators
+type BoundLeaf struct {
+	// Column : internal column name of the comparison
+	Column string
+	Op     string
+	// Value : the values as a list for OpIn / OpNin
+	Value interface{}
+
+	SQL  string
+	Args []interface{}
+}
+
+// ResolveBoolOp : boolean operation of a group , errors if it is neither AND nor OR
+func (s *SQLBaseFilterParser) ResolveBoolOp(boolop string) (string, error) {
+	return s.resolveBoolOp(boolop)
+}
+
+// ParseBoundLeaf : compiles a single predicate (Column + Op) , bind returns the argument a ? placeholder binds for an internal column name
+func (s *SQLBaseFilterParser) ParseBoundLeaf(filter *FilterExpression, schema *Schema, bind func(col string) interface{}) (*BoundLeaf, error) {
+	if !schema.DoesColOrJSONPathExist(filter.Column) {
+		return nil, SQLErrorColumnNotFound(filter.Column)
+	}
+	if filter.Value == nil {
+		return nil, SQLErrVariables
+	}
+
+	var (
+		leaf = &BoundLeaf{}
+		err  error
+	)
+	if isDocumentFilter(filter, schema) {
+		docCol := bind(schema.GetColumnInternalName(schema.documentColumn(filter.Column)))
+		leaf.SQL, leaf.Args, err = s.parseDocument(filter, schema, "?", docCol)
+		return leaf, err
+	}
+	leaf.Column = schema.GetColumnInternalName(filter.Column)
+	col := bind(leaf.Column)
+
+	switch {
+	case isGeoOperator(filter.Op):
+		if !schema.IsGeoColumn(filter.Column) {
+			return nil, ErrGeoColumnNotGeo(filter.Column)
+		}
+		leaf.SQL, leaf.Args, err = s.parseGeo(filter, "?", col)
+	case filter.Op == filterSearch:
+		leaf.SQL, leaf.Args, err = s.parseFullText(filter, schema, "?", col)
+	case filter.Op == filterPresent:
+		leaf.SQL, err = nullSQL(filter, "?")
+		leaf.Args = []interface{}{col}
+	case filter.Op == filterFuzzy:
+		leaf.Op, leaf.Value = OpLike, filter.Value
+	case filter.Op == filterIn || filter.Op == filterNin:
+		leaf.Op, leaf.Value = filter.Op, flattenValues(nil, filter.Value)
+	case filter.Op == filterEq || filter.Op == filterNe || filter.Op == filterGt || filter.Op == filterGe ||
+		filter.Op == filterLt || filter.Op == filterLe || filter.Op == filterLike || filter.Op == filterNotLike:
+		leaf.Op, leaf.Value = filter.Op, filter.Value
+	default:
+		return nil, SQLErrOperatorForColumnNotSupported(filter.Op)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
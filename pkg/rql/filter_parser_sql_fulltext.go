@@ -0,0 +1,103 @@
+package rql
+
+import (
+	"sort"
+
+	"github.com/baderkha/library/pkg/db"
+	"github.com/baderkha/library/pkg/err"
+)
+
+const (
+	// RQLFullTextTag : tag label for a column that has a full text index , this enables the `search` operator
+	// and relevance sorting on it for the sql parsers.
+	// for postgres the tag value can be the text search configuration to use (ie `rql_fulltext:"french"`)
+	//
+	// Example :
+	//			Title string `json:"title" db:"title" rql_fulltext:"1"`
+	RQLFullTextTag = "rql_fulltext"
+
+	postgresDefaultTextSearchConfig = "english"
+)
+
+var (
+	SQLErrFullTextDialectNotSupported = err.Compose("RQL : SQL : FilterParser : full text search `%s` is not supported for dialect `%s` , set the parser dialect to either `%s`,`%s`")
+	ErrColumnNotFullTextSearchable    = err.Compose("RQL : Column `%s` is not full text searchable , tag it with `rql_fulltext` and add a full text index")
+	ErrFullTextTermMustBeString       = err.Compose("RQL : Column `%s` full text search term must be a non empty string")
+)
+
+// FullTextColumns : the full text searchable columns (see RQLFullTextTag) sorted by name
+func (s *Schema) FullTextColumns() []string {
+	var cols []string
+	for col := range s.supportedColumns {
+		if s.IsFullTextColumn(col) {
+			cols = append(cols, col)
+		}
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// textSearchConfig : postgres text search configuration for the column
+func (s *Schema) textSearchConfig(col string) string {
+	config := s.GetTagValue(col, RQLFullTextTag)
+	if config == "" || config == "1" || config == "true" {
+		return postgresDefaultTextSearchConfig
+	}
+	return config
+}
+
+func validateFullTextFilter(filter *FilterExpression, schema *Schema) error {
+	if !schema.IsFullTextColumn(filter.Column) {
+		return ErrColumnNotFullTextSearchable(filter.Column)
+	}
+	if filter.Value == nil {
+		return nil
+	}
+	if term, ok := filter.Value.(string); !ok || term == "" {
+		return ErrFullTextTermMustBeString(filter.Column)
+	}
+	return nil
+}
+
+// parseFullText : full text predicate for the `search` operator
+//
+// col is written as is in the sql , if colArgs are given they are bound in the column's place (see parseGeo)
+//
+// MYSQL : MATCH(col) AGAINST (? IN NATURAL LANGUAGE MODE) , the column needs a FULLTEXT index of its own
+//
+// POSTGRES : to_tsvector(config, col) @@ websearch_to_tsquery(config, ?)
+func (s *SQLBaseFilterParser) parseFullText(filter *FilterExpression, schema *Schema, col string, colArgs ...interface{}) (string, []interface{}, error) {
+	err := validateFullTextFilter(filter, schema)
+	if err != nil {
+		return "", nil, err
+	}
+	return fullTextSQL(s.Dialect, filter.Op, schema.textSearchConfig(filter.Column), col, colArgs, filter.Value, false)
+}
+
+// fullTextSQL : either the match predicate or the relevance score expression (isRank)
+func fullTextSQL(dialect string, op string, config string, col string, colArgs []interface{}, term interface{}, isRank bool) (string, []interface{}, error) {
+	switch dialect {
+	case db.DialectMYSQL:
+		return "MATCH(" + col + ") AGAINST (? IN NATURAL LANGUAGE MODE)", append(colArgs, term), nil
+	case db.DialectPostgres:
+		args := append([]interface{}{config}, colArgs...)
+		args = append(args, config, term)
+		if isRank {
+			return "ts_rank(to_tsvector(?::regconfig, " + col + "), websearch_to_tsquery(?::regconfig, ?))", args, nil
+		}
+		return "to_tsvector(?::regconfig, " + col + ") @@ websearch_to_tsquery(?::regconfig, ?)", args, nil
+	}
+	return "", nil, SQLErrFullTextDialectNotSupported(op, dialect, db.DialectMYSQL, db.DialectPostgres)
+}
+
+// fullTextRankSQL : relevance score of a column for a search term , used for ordering
+func fullTextRankSQL(dialect string, schema *Schema, col string, term string, colArgs ...interface{}) (string, []interface{}, error) {
+	if !schema.IsFullTextColumn(col) {
+		return "", nil, ErrColumnNotFullTextSearchable(col)
+	}
+	colSQL := schema.GetColumnInternalName(col)
+	if len(colArgs) > 0 {
+		colSQL = "?"
+	}
+	return fullTextSQL(dialect, "relevance sort", schema.textSearchConfig(col), colSQL, colArgs, term, true)
+}
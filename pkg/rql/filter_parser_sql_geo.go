@@ -0,0 +1,74 @@
+package rql
+
+import (
+	"github.com/baderkha/library/pkg/db"
+	"github.com/baderkha/library/pkg/err"
+)
+
+var (
+	SQLErrGeoDialectNotSupported = err.Compose("RQL : SQL : FilterParser : geo operation `%s` is not supported for dialect `%s` , set the parser dialect to either `%s`,`%s`")
+)
+
+const (
+	metersInKm = 1000
+
+	// mySQLGeomFromText : wkt (longitude first) to a geometry in srid 4326 , the axis order is explicit since 4326 is latitude first
+	mySQLGeomFromText = "ST_GeomFromText(?, 4326, 'axis-order=long-lat')"
+)
+
+// parseGeo : turns a geo filter into a spatial sql predicate
+//
+// col is written as is in the sql , if colArgs are given they are bound in the column's place
+// (ie col = "?" and colArgs = clause.Column for gorm)
+//
+// MYSQL (8+) expects a POINT column with srid 4326
+//
+// POSTGRES expects a postgis geometry column with srid 4326
+func (s *SQLBaseFilterParser) parseGeo(filter *FilterExpression, col string, colArgs ...interface{}) (string, []interface{}, error) {
+	if s.Dialect != db.DialectMYSQL && s.Dialect != db.DialectPostgres {
+		return "", nil, SQLErrGeoDialectNotSupported(filter.Op, s.Dialect, db.DialectMYSQL, db.DialectPostgres)
+	}
+	isMySQL := s.Dialect == db.DialectMYSQL
+
+	switch filter.Op {
+	case filterGeoRadius:
+		r, err := decodeGeoValue[GeoRadius](filter)
+		if err != nil {
+			return "", nil, err
+		}
+		if isMySQL {
+			return "ST_Distance_Sphere(" + col + ", " + mySQLGeomFromText + ") <= ?", append(copyArgs(colArgs), r.Center.WKT(), r.RadiusKm*metersInKm), nil
+		}
+		return "ST_DWithin(" + col + "::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)", append(copyArgs(colArgs), r.Center.Lng, r.Center.Lat, r.RadiusKm*metersInKm), nil
+	case filterGeoBBox:
+		b, err := decodeGeoValue[GeoBoundingBox](filter)
+		if err != nil {
+			return "", nil, err
+		}
+		if isMySQL {
+			return "MBRContains(" + mySQLGeomFromText + ", " + col + ")", append([]interface{}{b.Polygon().WKT()}, colArgs...), nil
+		}
+		return "ST_Within(" + col + ", ST_MakeEnvelope(?, ?, ?, ?, 4326))", append(copyArgs(colArgs), b.SouthWest.Lng, b.SouthWest.Lat, b.NorthEast.Lng, b.NorthEast.Lat), nil
+	case filterGeoPolygon:
+		p, err := decodeGeoValue[GeoPolygon](filter)
+		if err != nil {
+			return "", nil, err
+		}
+		if isMySQL {
+			return "ST_Contains(" + mySQLGeomFromText + ", " + col + ")", append([]interface{}{p.WKT()}, colArgs...), nil
+		}
+		return "ST_Within(" + col + ", ST_GeomFromText(?, 4326))", append(copyArgs(colArgs), p.WKT()), nil
+	}
+	return "", nil, SQLErrOperatorForColumnNotSupported(filter.Op)
+}
+
+// geoDistanceSQL : distance expression from a column to a point , used for ordering
+func geoDistanceSQL(dialect string, col string, point GeoPoint) (string, error) {
+	switch dialect {
+	case db.DialectMYSQL:
+		return "ST_Distance_Sphere(" + col + ", ST_GeomFromText('" + point.WKT() + "', 4326, 'axis-order=long-lat'))", nil
+	case db.DialectPostgres:
+		return col + " <-> ST_SetSRID(ST_MakePoint(" + formatFloat(point.Lng) + ", " + formatFloat(point.Lat) + "), 4326)", nil
+	}
+	return "", SQLErrGeoDialectNotSupported("geo distance sort", dialect, db.DialectMYSQL, db.DialectPostgres)
+}
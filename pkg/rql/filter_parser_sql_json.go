@@ -0,0 +1,231 @@
+package rql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/baderkha/library/pkg/db"
+	"github.com/baderkha/library/pkg/err"
+)
+
+var (
+	SQLErrJSONDialectNotSupported = err.Compose("RQL : SQL : FilterParser : json / array operation `%s` on column `%s` is not supported for dialect `%s` , set the parser dialect to either `%s`,`%s`")
+)
+
+const (
+	jsonAsDocument = iota
+	jsonAsText
+	jsonAsNumber
+	jsonAsBool
+)
+
+// isDocumentFilter : json path references and array operators are resolved per dialect see parseDocument
+func isDocumentFilter(filter *FilterExpression, schema *Schema) bool {
+	return isArrayOperator(filter.Op) || (!schema.DoesColExist(filter.Column) && schema.IsJSONPath(filter.Column))
+}
+
+// documentColumn : the schema column holding the document ie `settings` for `settings.theme`
+func (s *Schema) documentColumn(col string) string {
+	if jp, ok := s.resolveJSONPath(col); ok && !s.DoesColExist(col) {
+		return jp.column
+	}
+	return col
+}
+
+// validateDocumentFilter : dialect + operator checks for json path and array filters
+func (s *SQLBaseFilterParser) validateDocumentFilter(filter *FilterExpression, schema *Schema) error {
+	if s.Dialect != db.DialectMYSQL && s.Dialect != db.DialectPostgres {
+		return SQLErrJSONDialectNotSupported(filter.Op, filter.Column, s.Dialect, db.DialectMYSQL, db.DialectPostgres)
+	}
+	if schema.IsJSONPath(filter.Column) && !schema.DoesColExist(filter.Column) {
+		err := validateJSONPathFilter(filter)
+		if err != nil {
+			return err
+		}
+	}
+	if isArrayOperator(filter.Op) {
+		return validateArrayFilter(filter, schema)
+	}
+	return nil
+}
+
+// parseDocument : predicate for a json path reference (ie `settings.theme eq dark`) or an array operator
+//
+// col is the document column written as is in the sql , if colArgs are given they are bound in the column's place (see parseGeo)
+//
+// MYSQL : JSON_EXTRACT / JSON_CONTAINS / JSON_OVERLAPS / JSON_LENGTH on json columns
+//
+// POSTGRES : ->> / #>> for json paths (jsonb) , @> / && / cardinality for native array columns
+// and @> / jsonb_array_length for arrays inside of json documents
+func (s *SQLBaseFilterParser) parseDocument(filter *FilterExpression, schema *Schema, col string, colArgs ...interface{}) (string, []interface{}, error) {
+	err := s.validateDocumentFilter(filter, schema)
+	if err != nil {
+		return "", nil, err
+	}
+	var path []string
+	if jp, ok := schema.resolveJSONPath(filter.Column); ok && !schema.DoesColExist(filter.Column) {
+		path = jp.path
+	}
+
+	if isArrayOperator(filter.Op) {
+		doc := col
+		if len(path) > 0 {
+			doc = jsonPathSQL(s.Dialect, col, path, jsonAsDocument)
+		}
+		isJSONDoc := len(path) > 0 || schema.IsJSONColumn(filter.Column)
+		return arraySQL(s.Dialect, filter, doc, colArgs, isJSONDoc, schema.arrayElemType(filter.Column))
+	}
+
+	if filter.Op == filterPresent {
+		presentSQL, err := nullSQL(filter, jsonPathSQL(s.Dialect, col, path, jsonAsDocument))
+		return presentSQL, copyArgs(colArgs), err
+	}
+
+	op, _, err := filterOps2.getOperator(filter.Op)
+	if err != nil {
+		return "", nil, err
+	}
+	kind, value := jsonScalar(s.Dialect, filter.Value)
+	args := append(copyArgs(colArgs), value)
+	return jsonPathSQL(s.Dialect, col, path, kind) + " " + op, args, nil
+}
+
+// jsonPathSQL : expression reading the path out of a json column , the path keys are validated by resolveJSONPath
+// so they are safe to be written in the sql as literals
+func jsonPathSQL(dialect string, col string, path []string, kind int) string {
+	if dialect == db.DialectMYSQL {
+		extract := "JSON_EXTRACT(" + col + ", '$." + strings.Join(path, ".") + "')"
+		if kind == jsonAsText {
+			return "JSON_UNQUOTE(" + extract + ")"
+		}
+		return extract
+	}
+	arrow := "->"
+	if kind != jsonAsDocument {
+		arrow = "->>"
+	}
+	expr := col + arrow + "'" + path[0] + "'"
+	if len(path) > 1 {
+		expr = col + "#" + strings.TrimPrefix(arrow, "-") + "'{" + strings.Join(path, ",") + "}'"
+	}
+	switch kind {
+	case jsonAsNumber:
+		return "(" + expr + ")::numeric"
+	case jsonAsBool:
+		return "(" + expr + ")::boolean"
+	}
+	return expr
+}
+
+// jsonScalar : how the json value should be read for the comparison value (in / nin use their first value).
+// mysql booleans are compared as text since a json true is not equal to 1
+func jsonScalar(dialect string, value interface{}) (int, interface{}) {
+	values := flattenValues(nil, value)
+	if len(values) == 0 {
+		return jsonAsText, value
+	}
+	switch reflect.ValueOf(values[0]).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return jsonAsNumber, value
+	case reflect.Bool:
+		if dialect != db.DialectMYSQL {
+			return jsonAsBool, value
+		}
+		texts := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			texts = append(texts, strconv.FormatBool(reflect.ValueOf(v).Bool()))
+		}
+		if kind := reflect.ValueOf(value).Kind(); kind != reflect.Slice && kind != reflect.Array {
+			return jsonAsText, texts[0]
+		}
+		return jsonAsText, texts
+	}
+	return jsonAsText, value
+}
+
+// arraySQL : contains_any / contains_all / length predicates on an array (either json or a native postgres array)
+func arraySQL(dialect string, filter *FilterExpression, doc string, colArgs []interface{}, isJSONDoc bool, elemType string) (string, []interface{}, error) {
+	if filter.Op == filterLength {
+		length, err := arrayLength(filter)
+		if err != nil {
+			return "", nil, err
+		}
+		args := append(copyArgs(colArgs), length)
+		switch {
+		case dialect == db.DialectMYSQL:
+			return "JSON_LENGTH(" + doc + ") = ?", args, nil
+		case isJSONDoc:
+			return "jsonb_array_length(" + doc + ") = ?", args, nil
+		}
+		return "COALESCE(cardinality(" + doc + "), 0) = ?", args, nil
+	}
+
+	values, err := arrayValues(filter)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if dialect == db.DialectMYSQL {
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return "", nil, ErrArrayValueMalformed(filter.Op, filter.Column, err.Error())
+		}
+		fn := "JSON_CONTAINS"
+		if filter.Op == filterContainsAny {
+			fn = "JSON_OVERLAPS"
+		}
+		return fn + "(" + doc + ", ?)", append(copyArgs(colArgs), string(encoded)), nil
+	}
+
+	if isJSONDoc {
+		return jsonbContainsSQL(filter, doc, colArgs, values)
+	}
+
+	// native postgres array
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	array := "ARRAY[" + placeholders + "]"
+	if elemType != "" {
+		array += "::" + elemType + "[]"
+	}
+	op := " @> "
+	if filter.Op == filterContainsAny {
+		op = " && "
+	}
+	return doc + op + array, append(copyArgs(colArgs), values...), nil
+}
+
+// jsonbContainsSQL : contains_all is a single containment check , contains_any is 1 containment check per value
+func jsonbContainsSQL(filter *FilterExpression, doc string, colArgs []interface{}, values []interface{}) (string, []interface{}, error) {
+	groups := [][]interface{}{values}
+	if filter.Op == filterContainsAny {
+		groups = nil
+		for _, v := range values {
+			groups = append(groups, []interface{}{v})
+		}
+	}
+	var (
+		sqlAr []string
+		args  []interface{}
+	)
+	for _, group := range groups {
+		encoded, err := json.Marshal(group)
+		if err != nil {
+			return "", nil, ErrArrayValueMalformed(filter.Op, filter.Column, err.Error())
+		}
+		sqlAr = append(sqlAr, doc+" @> ?::jsonb")
+		args = append(append(args, colArgs...), string(encoded))
+	}
+	if len(sqlAr) == 1 {
+		return sqlAr[0], args, nil
+	}
+	return "(" + strings.Join(sqlAr, " OR ") + ")", args, nil
+}
+
+// copyArgs : copy of the column args so appending to them never writes into the caller's slice
+func copyArgs(colArgs []interface{}) []interface{} {
+	return append(make([]interface{}, 0, len(colArgs)+1), colArgs...)
+}
@@ -3,16 +3,23 @@ package rql
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/baderkha/library/pkg/conditional"
 	"github.com/baderkha/library/pkg/err"
 	"github.com/baderkha/typesense"
 )
 
 const (
 	isTypesenseFuzzySearch = "fuzzy_search"
+	isTypesenseGeoFilter   = "geo_filter"
+	isTypesenseContainsAll = "contains_all"
 	unsupportedBaseFilter  = "unsupported"
+
+	typesenseAnd = "&&"
+	typesenseOr  = "||"
 )
 
 var (
@@ -25,29 +32,67 @@ var (
 	TsErrorThisColumnDoesNotSupportFiltering      = err.Compose("RQL : TypeSense : FilterParser : Column `%s` does not support filtering")
 	TsErrorColumnNotFond                          = err.Compose("RQL : TypeSense : FilterParser : Column `%s` does not exist")
 	TsErrorColumnNotFuzzySearchable               = err.Compose("RQL : TypeSense : FilterParser : Column `%s` is not fuzzy searchable , you must have an index on it")
+	TsErrBoolOp                                   = err.Compose("RQL : TypeSense : FilterParser : unsupported boolean operation `%s` expected either `%s`,`%s`")
+	TsErrorValueCannotContainBacktick             = err.Compose("RQL : TypeSense : FilterParser : Column `%s` has a value containing a backtick which typesense cannot escape")
+	TsErrorMultiValueCannotBeEmpty                = err.Compose("RQL : TypeSense : FilterParser : Column `%s` expects at least 1 value for operation `%s`")
 
 	// static errors
+
+	// Deprecated: TsErrTypesenseCannotDoOrs : OR groups are supported by the parser now
 	TsErrTypesenseCannotDoOrs                               = errors.New("RQL : TypeSense : FilterParser : typesense cannot do or logic")
 	TsErrorYourLikeOperationsShouldAllHaveTheSameSearchTerm = errors.New("RQL : TypeSense : FilterParser : Your Like Operations must all have the same values")
-	TsErrTypesenseCannotHaveMoreThan1Level                  = errors.New("RQL : TypeSense : FilterParser : typesense cannot have more than 1 level of filter nesting")
+	// Deprecated: TsErrTypesenseCannotHaveMoreThan1Level : nested groups are supported by the parser now
+	TsErrTypesenseCannotHaveMoreThan1Level = errors.New("RQL : TypeSense : FilterParser : typesense cannot have more than 1 level of filter nesting")
+	TsErrFuzzyCannotBeInsideOr             = errors.New("RQL : TypeSense : FilterParser : fuzzy operations cannot be used inside an OR group , typesense searches are global to the query")
+	TsErrVariables                         = errors.New("RQL : TypeSense : FilterParser : you cannot have variables and values set or null . it's either one or the other being set or null")
 
 	typesenseFilteOps = map[string]string{
-		filterLike:  unsupportedBaseFilter,
-		filterFuzzy: isTypesenseFuzzySearch,
-		filterGt:    ":>",
-		filterGe:    ":>=",
-		filterLt:    ":<",
-		filterLe:    ":<=",
-		filterEq:    ":=",
-		filterNe:    ":!=",
-		filterIn:    ":",
-		filterNin:   ":!",
+		filterLike:    unsupportedBaseFilter,
+		filterNotLike: unsupportedBaseFilter,
+		filterPresent: unsupportedBaseFilter,
+		filterFuzzy:   isTypesenseFuzzySearch,
+		filterSearch:  isTypesenseFuzzySearch,
+		filterGt:      ":>",
+		filterGe:      ":>=",
+		filterLt:      ":<",
+		filterLe:      ":<=",
+		filterEq:      ":=",
+		filterNe:      ":!=",
+		filterIn:      ":=",
+		filterNin:     ":!=",
+
+		// array fields match when any of their items match
+		filterContainsAny: ":=",
+		filterContainsAll: isTypesenseContainsAll,
+		filterLength:      unsupportedBaseFilter,
+
+		filterGeoRadius:  isTypesenseGeoFilter,
+		filterGeoBBox:    isTypesenseGeoFilter,
+		filterGeoPolygon: isTypesenseGeoFilter,
+	}
+
+	typesenseBoolOps = map[string]string{
+		ANDOperator: typesenseAnd,
+		OROperator:  typesenseOr,
 	}
 )
 
+var _ ITypeSenseFilterParser = &FilterParserTypeSense{}
+var _ IFilterValidator = &FilterParserTypeSense{}
+
+// FilterParserTypeSense : turns a filter expression into typesense search parameters
+//
+// fuzzy operations are lifted into the query_by / q parameters , everything else becomes
+// a filter_by clause where nested expressions are wrapped in parentheses
 type FilterParserTypeSense struct {
 }
 
+// typesenseSearchState : search term state collected while walking the expression tree
+type typesenseSearchState struct {
+	fuzzySearchByFields []string
+	fuzzySearchByTerm   string
+}
+
 func (f *FilterParserTypeSense) parseOperation(operation string) (operat string, isMultiValueOperator bool, err error) {
 	op := typesenseFilteOps[operation]
 	switch op {
@@ -56,74 +101,276 @@ func (f *FilterParserTypeSense) parseOperation(operation string) (operat string,
 	case unsupportedBaseFilter:
 		return "", false, TsErrUnsupportedBaseOperation(operation)
 	}
-	return op, operation == filterIn || operation == filterNin, nil
+	return op, operation == filterIn || operation == filterNin || operation == filterContainsAny, nil
 
 }
 
-func (f *FilterParserTypeSense) isPropertyNestingMoreThan1(property *FilterExpression) bool {
-	return len(property.Properties) > 0
+func (f *FilterParserTypeSense) resolveBoolOp(boolop string) (string, error) {
+	op, ok := typesenseBoolOps[boolop]
+	if !ok {
+		return "", TsErrBoolOp(boolop, ANDOperator, OROperator)
+	}
+	return op, nil
 }
 
-func (f *FilterParserTypeSense) Parse(expression *FilterExpression, schema *Schema) (out *typesense.SearchParameters, err error) {
+// escapeValue : formats a single value the way typesense expects it inside of a filter_by clause
+func (f *FilterParserTypeSense) escapeValue(col string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.Contains(v, "`") {
+			return "", TsErrorValueCannotContainBacktick(col)
+		}
+		return "`" + v + "`", nil
+	case *string:
+		if v == nil {
+			return "", TsErrVariables
+		}
+		return f.escapeValue(col, *v)
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case time.Time:
+		return strconv.FormatInt(v.Unix(), 10), nil
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+// escapeMultiValue : formats a list of values as `[a,b,c]` , scalars are treated as a list of 1
+func (f *FilterParserTypeSense) escapeMultiValue(prop *FilterExpression) (string, error) {
 	var (
-		fuzzySearchByFields []string
-		fuzzySearchByTerm   string
-		filterByArgs        []string
+		values []string
+		rv     = reflect.ValueOf(prop.Value)
 	)
-	properties := expression.Properties
-	for _, prop := range properties {
-		if f.isPropertyNestingMoreThan1(prop) {
-			return nil, TsErrTypesenseCannotHaveMoreThan1Level
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		escaped, err := f.escapeValue(prop.Column, prop.Value)
+		if err != nil {
+			return "", err
 		}
-		if !schema.DoesColExist(prop.Column) {
-			return nil, errorColumnNotFound
+		return "[" + escaped + "]", nil
+	}
+	if rv.Len() == 0 {
+		return "", TsErrorMultiValueCannotBeEmpty(prop.Column, prop.Op)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		escaped, err := f.escapeValue(prop.Column, rv.Index(i).Interface())
+		if err != nil {
+			return "", err
 		}
+		values = append(values, escaped)
+	}
+	return "[" + strings.Join(values, ",") + "]", nil
+}
+
+func (f *FilterParserTypeSense) parseFuzzy(prop *FilterExpression, schema *Schema, state *typesenseSearchState) error {
+	if !schema.CheckTagExists(prop.Column, typesense.TagIndex) {
+		return TsErrorColumnNotFuzzySearchable(prop.Column)
+	}
+	fSearchTerm, stringCastable := prop.Value.(string)
+	// not string
+	if !stringCastable {
+		return TsErrorExpectedThisFilterToHaveADifferentType(
+			fmt.Sprintf("%s:%s:%v", prop.Column, prop.Op, prop.Value),
+			"string",
+		)
+	}
 
-		op, isMulti, err := f.parseOperation(prop.Op)
+	if state.fuzzySearchByTerm == "" {
+		state.fuzzySearchByTerm = fSearchTerm
+	} else if fSearchTerm != state.fuzzySearchByTerm {
+		return TsErrorYourLikeOperationsShouldAllHaveTheSameSearchTerm
+	}
+	state.fuzzySearchByFields = append(state.fuzzySearchByFields, prop.Column)
+	return nil
+}
+
+func (f *FilterParserTypeSense) parseLeaf(prop *FilterExpression, schema *Schema, state *typesenseSearchState, canSearch bool) (string, error) {
+	if !schema.DoesColOrJSONPathExist(prop.Column) {
+		return "", TsErrorColumnNotFond(prop.Column)
+	}
+	if prop.Value == nil {
+		return "", TsErrVariables
+	}
+	// json paths are nested fields in typesense ie `settings.theme`
+	if !schema.DoesColExist(prop.Column) {
+		err := validateJSONPathFilter(prop)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
+	}
+	if isArrayOperator(prop.Op) {
+		err := validateArrayFilter(prop, schema)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	op, isMulti, err := f.parseOperation(prop.Op)
+	if err != nil {
+		return "", err
+	}
+
+	if op == isTypesenseFuzzySearch {
+		if !canSearch {
+			return "", TsErrFuzzyCannotBeInsideOr
+		}
+		return "", f.parseFuzzy(prop, schema, state)
+	}
+	if op == isTypesenseGeoFilter {
+		return f.parseGeo(prop, schema)
+	}
+	if op == isTypesenseContainsAll {
+		return f.parseContainsAll(prop)
+	}
 
-		if op == isTypesenseFuzzySearch {
-			if !schema.CheckTagExists(prop.Column, typesense.TagIndex) {
-				return nil, TsErrorColumnNotFuzzySearchable(prop.Column)
+	var value string
+	if isMulti {
+		value, err = f.escapeMultiValue(prop)
+	} else {
+		value, err = f.escapeValue(prop.Column, prop.Value)
+	}
+	if err != nil {
+		return "", err
+	}
+	return prop.Column + op + value, nil
+}
+
+// parseContainsAll : 1 match per value ie `(tags:=`a` && tags:=`b`)`
+func (f *FilterParserTypeSense) parseContainsAll(prop *FilterExpression) (string, error) {
+	values, err := arrayValues(prop)
+	if err != nil {
+		return "", err
+	}
+	var clauses []string
+	for _, v := range values {
+		escaped, err := f.escapeValue(prop.Column, v)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, prop.Column+":="+escaped)
+	}
+	if len(clauses) == 1 {
+		return clauses[0], nil
+	}
+	return "(" + strings.Join(clauses, " "+typesenseAnd+" ") + ")", nil
+}
+
+// parseGeo : geopoint filters ie `location:(48.85, 2.34, 5 km)` or `location:(lat1, lng1, lat2, lng2, ...)`
+func (f *FilterParserTypeSense) parseGeo(prop *FilterExpression, schema *Schema) (string, error) {
+	var points []GeoPoint
+	if !schema.IsGeoColumn(prop.Column) {
+		return "", ErrGeoColumnNotGeo(prop.Column)
+	}
+	switch prop.Op {
+	case filterGeoRadius:
+		r, err := decodeGeoValue[GeoRadius](prop)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s:(%s, %s, %s km)", prop.Column, formatFloat(r.Center.Lat), formatFloat(r.Center.Lng), formatFloat(r.RadiusKm)), nil
+	case filterGeoBBox:
+		b, err := decodeGeoValue[GeoBoundingBox](prop)
+		if err != nil {
+			return "", err
+		}
+		points = b.Polygon().Points
+	case filterGeoPolygon:
+		p, err := decodeGeoValue[GeoPolygon](prop)
+		if err != nil {
+			return "", err
+		}
+		points = p.Points
+	}
+	var coordinates []string
+	for _, p := range points {
+		coordinates = append(coordinates, formatFloat(p.Lat), formatFloat(p.Lng))
+	}
+	return prop.Column + ":(" + strings.Join(coordinates, ", ") + ")", nil
+}
+
+// parseGroup : recursively builds the filter_by clause for a group of expressions
+// canSearch is only true while every group from the root down is an AND chain
+func (f *FilterParserTypeSense) parseGroup(expression *FilterExpression, schema *Schema, state *typesenseSearchState, canSearch bool) (string, error) {
+	var clauses []string
+	properties := expression.Properties
+	// base case
+	if len(properties) == 0 {
+		return "", nil
+	}
+
+	boolOp, err := f.resolveBoolOp(expression.BinaryOperation)
+	if err != nil {
+		return "", err
+	}
+	canSearch = canSearch && (boolOp == typesenseAnd || len(properties) == 1)
+
+	for _, prop := range properties {
+		if prop == nil {
+			continue
+		}
+		if prop.Column != "" && prop.Op != "" {
+			clause, err := f.parseLeaf(prop, schema, state, canSearch)
+			if err != nil {
+				return "", err
 			}
-			fSearchTerm, stringCastable := prop.Value.(string)
-			// not string
-			if !stringCastable {
-				return nil, TsErrorExpectedThisFilterToHaveADifferentType(
-					fmt.Sprintf("%s:%s:%s", prop.Column, prop.Op, prop.Value),
-					"string",
-				)
+			if clause != "" {
+				clauses = append(clauses, clause)
 			}
-
-			if fuzzySearchByTerm == "" {
-				fuzzySearchByTerm = fSearchTerm
-			} else if fSearchTerm != fuzzySearchByTerm {
-				return nil, TsErrorYourLikeOperationsShouldAllHaveTheSameSearchTerm
+		} else if len(prop.Properties) > 0 {
+			clause, err := f.parseGroup(prop, schema, state, canSearch)
+			if err != nil {
+				return "", err
+			}
+			if clause != "" {
+				clauses = append(clauses, "("+clause+")")
 			}
-			fuzzySearchByFields = append(fuzzySearchByFields, prop.Column)
-
-		} else {
-			filterByArgs = conditional.Ternary(
-				isMulti,
-				append(filterByArgs, fmt.Sprintf("%s%s[%s]", prop.Column, op, prop.Value)),
-				append(filterByArgs, fmt.Sprintf("%s%s%s", prop.Column, op, prop.Value)),
-			)
 		}
+	}
+	return strings.Join(clauses, " "+boolOp+" "), nil
+}
 
+// Parse : parse the filter expression into typesense search parameters
+func (f *FilterParserTypeSense) Parse(expression *FilterExpression, schema *Schema) (out *typesense.SearchParameters, err error) {
+	var state typesenseSearchState
+	search := typesense.NewSearchParams()
+	if expression == nil {
+		return search, nil
 	}
 
-	search := typesense.
-		NewSearchParams().
-		AddQueryBy(strings.Join(fuzzySearchByFields, ",")).
-		AddSearchTerm(fuzzySearchByTerm).
-		AddFilterBy(strings.Join(filterByArgs, "&&"))
+	filterBy, err := f.parseGroup(expression, schema, &state, true)
+	if err != nil {
+		return nil, err
+	}
+
+	search.
+		AddQueryBy(strings.Join(state.fuzzySearchByFields, ",")).
+		AddFilterBy(filterBy)
+	if state.fuzzySearchByTerm != "" {
+		search.AddSearchTerm(state.fuzzySearchByTerm)
+	}
 
 	return search, nil
 }
 
+// Validate : validate filter expression
 func (f *FilterParserTypeSense) Validate(expression *FilterExpression, schema *Schema) (err error) {
 	_, err = f.Parse(expression, schema)
 	return err
 }
+
+// JoinTypesenseFilters : joins multiple filter_by clauses with an AND , wrapping each one in parentheses
+// so that OR groups keep their precedence
+func JoinTypesenseFilters(filters ...string) string {
+	var clauses []string
+	for _, filter := range filters {
+		if filter != "" {
+			clauses = append(clauses, filter)
+		}
+	}
+	if len(clauses) <= 1 {
+		return strings.Join(clauses, "")
+	}
+	return "(" + strings.Join(clauses, ") "+typesenseAnd+" (") + ")"
+}
@@ -0,0 +1,169 @@
+package rql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/baderkha/library/pkg/err"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// RQLGeoTag : tag label for a column that holds a geo point , this enables the geo operators on it
+	// columns tagged with `tsense_type:"geopoint"` are also considered geo columns
+	RQLGeoTag = "rql_geo"
+
+	typesenseTypeTag      = "tsense_type"
+	typesenseGeoPointType = "geopoint"
+)
+
+var (
+	ErrGeoValueMalformed = err.Compose("RQL : Geo : value for operation `%s` on column `%s` is malformed : %s")
+	ErrGeoColumnNotGeo   = err.Compose("RQL : Geo : Column `%s` is not a geo column , tag it with `rql_geo`")
+)
+
+// GeoPoint : latitude / longitude pair in degrees
+type GeoPoint struct {
+	Lat float64 `json:"lat" mapstructure:"lat"`
+	Lng float64 `json:"lng" mapstructure:"lng"`
+}
+
+// WKT : well known text representation of the point (longitude first)
+func (g GeoPoint) WKT() string {
+	return "POINT(" + formatFloat(g.Lng) + " " + formatFloat(g.Lat) + ")"
+}
+
+func (g GeoPoint) validate() error {
+	if g.Lat < -90 || g.Lat > 90 {
+		return fmt.Errorf("latitude %v is out of range [-90,90]", g.Lat)
+	}
+	if g.Lng < -180 || g.Lng > 180 {
+		return fmt.Errorf("longitude %v is out of range [-180,180]", g.Lng)
+	}
+	return nil
+}
+
+// GeoRadius : value for the `geo_radius` operator , matches points within RadiusKm of the Center
+type GeoRadius struct {
+	Center   GeoPoint `json:"center" mapstructure:"center"`
+	RadiusKm float64  `json:"radius_km" mapstructure:"radius_km"`
+}
+
+func (g GeoRadius) validate() error {
+	if g.RadiusKm <= 0 {
+		return fmt.Errorf("radius_km must be greater than 0")
+	}
+	return g.Center.validate()
+}
+
+// GeoBoundingBox : value for the `geo_bbox` operator , matches points inside of the box
+type GeoBoundingBox struct {
+	SouthWest GeoPoint `json:"south_west" mapstructure:"south_west"`
+	NorthEast GeoPoint `json:"north_east" mapstructure:"north_east"`
+}
+
+func (g GeoBoundingBox) validate() error {
+	if g.SouthWest.Lat > g.NorthEast.Lat {
+		return fmt.Errorf("south_west latitude must be less than north_east latitude")
+	}
+	if err := g.SouthWest.validate(); err != nil {
+		return err
+	}
+	return g.NorthEast.validate()
+}
+
+// Polygon : the bounding box as a closed polygon (south west , north west , north east , south east)
+func (g GeoBoundingBox) Polygon() GeoPolygon {
+	return GeoPolygon{
+		Points: []GeoPoint{
+			g.SouthWest,
+			{Lat: g.NorthEast.Lat, Lng: g.SouthWest.Lng},
+			g.NorthEast,
+			{Lat: g.SouthWest.Lat, Lng: g.NorthEast.Lng},
+		},
+	}
+}
+
+// GeoPolygon : value for the `geo_polygon` operator , matches points inside of the polygon
+type GeoPolygon struct {
+	Points []GeoPoint `json:"points" mapstructure:"points"`
+}
+
+func (g GeoPolygon) validate() error {
+	if len(g.Points) < 3 {
+		return fmt.Errorf("a polygon needs at least 3 points")
+	}
+	for _, p := range g.Points {
+		if err := p.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WKT : well known text representation of the polygon (ring is closed automatically)
+func (g GeoPolygon) WKT() string {
+	points := make([]string, 0, len(g.Points)+1)
+	for _, p := range g.Points {
+		points = append(points, formatFloat(p.Lng)+" "+formatFloat(p.Lat))
+	}
+	points = append(points, points[0])
+	return "POLYGON((" + strings.Join(points, ", ") + "))"
+}
+
+type geoValue interface {
+	GeoRadius | GeoBoundingBox | GeoPolygon
+	validate() error
+}
+
+func isGeoOperator(op string) bool {
+	return op == filterGeoRadius || op == filterGeoBBox || op == filterGeoPolygon
+}
+
+// decodeGeoValue : accepts either the typed value or a map (ie from json input) for a geo operator
+func decodeGeoValue[t geoValue](filter *FilterExpression) (*t, error) {
+	var out t
+	switch v := filter.Value.(type) {
+	case t:
+		out = v
+	case *t:
+		if v == nil {
+			return nil, ErrGeoValueMalformed(filter.Op, filter.Column, "value is nil")
+		}
+		out = *v
+	default:
+		err := mapstructure.WeakDecode(filter.Value, &out)
+		if err != nil {
+			return nil, ErrGeoValueMalformed(filter.Op, filter.Column, err.Error())
+		}
+	}
+	if err := out.validate(); err != nil {
+		return nil, ErrGeoValueMalformed(filter.Op, filter.Column, err.Error())
+	}
+	return &out, nil
+}
+
+// validateGeoFilter : makes sure the column is a geo column and the value is well formed
+func validateGeoFilter(filter *FilterExpression, schema *Schema) error {
+	if !schema.IsGeoColumn(filter.Column) {
+		return ErrGeoColumnNotGeo(filter.Column)
+	}
+	if filter.Value == nil {
+		return nil
+	}
+	var err error
+	switch filter.Op {
+	case filterGeoRadius:
+		_, err = decodeGeoValue[GeoRadius](filter)
+	case filterGeoBBox:
+		_, err = decodeGeoValue[GeoBoundingBox](filter)
+	case filterGeoPolygon:
+		_, err = decodeGeoValue[GeoPolygon](filter)
+	}
+	return err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,209 @@
+// Package gormrql : compiles rql expressions into gorm clauses and scopes , kept out of the rql package so that it does not depend on gorm
+package gormrql
+
+import (
+	"github.com/baderkha/library/pkg/db"
+	"github.com/baderkha/library/pkg/rql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IGormFilterParser = rql.IFilterParser[clause.Where]
+
+var _ IGormFilterParser = &FilterParserGorm{}
+var _ rql.IFilterValidator = &FilterParserGorm{}
+
+// FilterParserGorm : compiles a filter expression into gorm clauses instead of a raw sql string
+// so that the query composes with regular gorm chains (scopes , hooks , soft delete , preloads , dialect quoting)
+type FilterParserGorm struct {
+	// Dialect : sql dialect , only required for the geo , full text , json and array operators (see db.DialectMYSQL , db.DialectPostgres)
+	Dialect string
+}
+
+// NewGormFilterParser : gorm filter parser with the dialect resolved from the gorm connection
+func NewGormFilterParser(conn *gorm.DB) *FilterParserGorm {
+	return &FilterParserGorm{Dialect: DialectFromGorm(conn)}
+}
+
+// DialectFromGorm : maps the gorm dialector name to one of the db dialects (empty if unknown)
+func DialectFromGorm(conn *gorm.DB) string {
+	if conn == nil || conn.Dialector == nil {
+		return ""
+	}
+	switch conn.Dialector.Name() {
+	case "mysql":
+		return db.DialectMYSQL
+	case "postgres":
+		return db.DialectPostgres
+	}
+	return ""
+}
+
+func (g *FilterParserGorm) sqlParser() *rql.SQLBaseFilterParser {
+	return &rql.SQLBaseFilterParser{Dialect: g.Dialect}
+}
+
+// Validate : validate filter expression
+func (g *FilterParserGorm) Validate(expression *rql.FilterExpression, schema *rql.Schema) error {
+	return g.sqlParser().Validate(expression, schema)
+}
+
+// Parse : Filter Expression to a gorm where clause
+func (g *FilterParserGorm) Parse(expression *rql.FilterExpression, schema *rql.Schema) (*clause.Where, error) {
+	var where clause.Where
+	if expression == nil {
+		return &where, nil
+	}
+	expr, err := g.parseGroup(expression, schema)
+	if err != nil {
+		return nil, err
+	}
+	if expr != nil {
+		where.Exprs = append(where.Exprs, expr)
+	}
+	return &where, nil
+}
+
+func (g *FilterParserGorm) parseGroup(expression *rql.FilterExpression, schema *rql.Schema) (clause.Expression, error) {
+	var exprs []clause.Expression
+	properties := expression.Properties
+	// base case
+	if len(properties) == 0 {
+		return nil, nil
+	}
+
+	boolOp, err := g.sqlParser().ResolveBoolOp(expression.BinaryOperation)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filter := range properties {
+		if filter == nil {
+			continue
+		}
+		var (
+			expr clause.Expression
+			err  error
+		)
+		if filter.Column != "" && filter.Op != "" {
+			expr, err = g.parseLeaf(filter, schema)
+		} else if len(filter.Properties) > 0 {
+			expr, err = g.parseGroup(filter, schema)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil {
+			exprs = append(exprs, expr)
+		}
+	}
+
+	// single conditions are never wrapped , gorm joins a lone OrConditions with OR
+	switch {
+	case len(exprs) == 0:
+		return nil, nil
+	case len(exprs) == 1:
+		return exprs[0], nil
+	case boolOp == rql.OROperator:
+		return clause.OrConditions{Exprs: exprs}, nil
+	}
+	return clause.AndConditions{Exprs: exprs}, nil
+}
+
+func (g *FilterParserGorm) parseLeaf(filter *rql.FilterExpression, schema *rql.Schema) (clause.Expression, error) {
+	leaf, err := g.sqlParser().ParseBoundLeaf(filter, schema, bindColumn)
+	if err != nil {
+		return nil, err
+	}
+	if leaf.SQL != "" {
+		return clause.Expr{SQL: leaf.SQL, Vars: leaf.Args}, nil
+	}
+
+	col := clause.Column{Name: leaf.Column}
+	switch leaf.Op {
+	case rql.OpEq:
+		return clause.Eq{Column: col, Value: leaf.Value}, nil
+	case rql.OpNe:
+		return clause.Neq{Column: col, Value: leaf.Value}, nil
+	case rql.OpGt:
+		return clause.Gt{Column: col, Value: leaf.Value}, nil
+	case rql.OpGe:
+		return clause.Gte{Column: col, Value: leaf.Value}, nil
+	case rql.OpLt:
+		return clause.Lt{Column: col, Value: leaf.Value}, nil
+	case rql.OpLe:
+		return clause.Lte{Column: col, Value: leaf.Value}, nil
+	case rql.OpLike:
+		return clause.Like{Column: col, Value: leaf.Value}, nil
+	case rql.OpNotLike:
+		return clause.Not(clause.Like{Column: col, Value: leaf.Value}), nil
+	case rql.OpIn:
+		return clause.IN{Column: col, Values: leaf.Value.([]interface{})}, nil
+	case rql.OpNin:
+		return clause.Not(clause.IN{Column: col, Values: leaf.Value.([]interface{})}), nil
+	}
+	return nil, rql.SQLErrOperatorForColumnNotSupported(filter.Op)
+}
+
+// bindColumn : binds the column so the gorm dialect quotes it
+func bindColumn(col string) interface{} {
+	return clause.Column{Name: col}
+}
+
+var _ IGormFilterParser = &FilterParserGormSQL{}
+var _ rql.IFilterValidator = &FilterParserGormSQL{}
+
+// FilterParserGormSQL : adapts a sql filter parser into gorm clauses (the sql output becomes a single where expression)
+type FilterParserGormSQL struct {
+	Parser rql.ISQLFilterParser
+}
+
+// Validate : validate filter expression , no op if the sql parser is not an IFilterValidator
+func (g *FilterParserGormSQL) Validate(expression *rql.FilterExpression, schema *rql.Schema) error {
+	validator, ok := g.Parser.(rql.IFilterValidator)
+	if !ok {
+		return nil
+	}
+	return validator.Validate(expression, schema)
+}
+
+// Parse : Filter Expression to a gorm where clause
+func (g *FilterParserGormSQL) Parse(expression *rql.FilterExpression, schema *rql.Schema) (*clause.Where, error) {
+	var where clause.Where
+	if expression == nil {
+		return &where, nil
+	}
+	out, err := g.Parser.Parse(expression, schema)
+	if err != nil {
+		return nil, err
+	}
+	if out.Query != "" {
+		where.Exprs = append(where.Exprs, clause.Expr{SQL: out.Query, Vars: out.Args})
+	}
+	return &where, nil
+}
+
+// GormFilterScope : gorm scope that applies all the (non nil) filter expressions joined by AND
+//
+// Example :
+//			scope, err := gormrql.GormFilterScope(gormrql.NewGormFilterParser(db), schema, filter, baseFilter)
+//			err = db.Table("users").Scopes(scope).Find(&users).Error
+func GormFilterScope(parser IGormFilterParser, schema *rql.Schema, expressions ...*rql.FilterExpression) (func(*gorm.DB) *gorm.DB, error) {
+	var where clause.Where
+	for _, expression := range expressions {
+		if expression == nil {
+			continue
+		}
+		w, err := parser.Parse(expression, schema)
+		if err != nil {
+			return nil, err
+		}
+		where.Exprs = append(where.Exprs, w.Exprs...)
+	}
+	return func(tx *gorm.DB) *gorm.DB {
+		if len(where.Exprs) == 0 {
+			return tx
+		}
+		return tx.Clauses(where)
+	}, nil
+}
@@ -0,0 +1,84 @@
+package gormrql
+
+import (
+	"strings"
+
+	"github.com/baderkha/library/pkg/rql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IGormSortParser = rql.ISortParser[clause.OrderBy]
+
+var _ IGormSortParser = &SortParserGorm{}
+
+// SortParserGorm : compiles a sort expression into a gorm order by clause
+type SortParserGorm struct {
+	// Dialect : sql dialect , only required for geo distance / relevance sorting (see db.DialectMYSQL , db.DialectPostgres)
+	Dialect string
+}
+
+// NewGormSortParser : gorm sort parser with the dialect resolved from the gorm connection
+func NewGormSortParser(conn *gorm.DB) *SortParserGorm {
+	return &SortParserGorm{Dialect: DialectFromGorm(conn)}
+}
+
+// Parse : sort expression to a gorm order by clause , columns are bound so the dialect quotes them
+func (s *SortParserGorm) Parse(expression *rql.SortExpression, schema *rql.Schema) (out *clause.OrderBy, err error) {
+	terms, vars, err := rql.SortParserSQL{Dialect: s.Dialect}.ParseBound(expression, schema, bindColumn)
+	if err != nil {
+		return nil, err
+	}
+	out = &clause.OrderBy{}
+	if len(terms) > 0 {
+		out.Expression = clause.Expr{SQL: strings.Join(terms, ","), Vars: vars}
+	}
+	return out, nil
+}
+
+var _ IGormSortParser = &SortParserGormSQL{}
+
+// SortParserGormSQL : adapts a sql sort parser into a gorm order by clause
+type SortParserGormSQL struct {
+	Sorter rql.ISQLSortParser
+}
+
+// Parse : sort expression to a gorm order by clause
+func (s *SortParserGormSQL) Parse(expression *rql.SortExpression, schema *rql.Schema) (*clause.OrderBy, error) {
+	out, err := s.Sorter.Parse(expression, schema)
+	if err != nil {
+		return nil, err
+	}
+	orderBy := &clause.OrderBy{}
+	if len(out.Clauses) > 0 {
+		orderBy.Expression = clause.Expr{SQL: strings.Join(out.Clauses, ","), Vars: out.Args}
+	}
+	return orderBy, nil
+}
+
+// GormSortScope : gorm scope that applies the sort expression (nil is a no op)
+func GormSortScope(parser IGormSortParser, schema *rql.Schema, expression *rql.SortExpression) (func(*gorm.DB) *gorm.DB, error) {
+	if expression == nil {
+		return func(tx *gorm.DB) *gorm.DB { return tx }, nil
+	}
+	orderBy, err := parser.Parse(expression, schema)
+	if err != nil {
+		return nil, err
+	}
+	return func(tx *gorm.DB) *gorm.DB {
+		if orderBy.Expression == nil {
+			return tx
+		}
+		return tx.Clauses(*orderBy)
+	}, nil
+}
+
+// GormPaginationScope : gorm scope that applies the limit / offset of the pagination expression (nil is a no op)
+func GormPaginationScope(p *rql.PaginationExpression) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if p == nil {
+			return tx
+		}
+		return tx.Limit(p.Size()).Offset(p.Offset())
+	}
+}
@@ -0,0 +1,573 @@
+package rql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/baderkha/library/pkg/err"
+)
+
+const (
+	odataTokenWord = iota
+	odataTokenString
+	odataTokenOpenParen
+	odataTokenCloseParen
+	odataTokenComma
+
+	odataAnd  = "and"
+	odataOr   = "or"
+	odataNot  = "not"
+	odataIn   = "in"
+	odataAsc  = "asc"
+	odataDesc = "desc"
+
+	odataPathSeparator = "/"
+
+	ODataFilter  = "$filter"
+	ODataOrderBy = "$orderby"
+	ODataTop     = "$top"
+	ODataSkip    = "$skip"
+	ODataSelect  = "$select"
+	ODataCount   = "$count"
+)
+
+var (
+	ErrODataInvalidFilter       = err.Compose("RQL : OData : invalid $filter at position %d : %s")
+	ErrODataUnsupportedFunction = err.Compose("RQL : OData : function `%s` is not supported , supported functions are %s")
+	ErrODataUnsupportedOperator = err.Compose("RQL : OData : operator `%s` is not supported , supported operators are %s")
+	ErrODataUnknownProperty     = err.Compose("RQL : OData : property `%s` does not exist")
+	ErrODataNullNotComparable   = err.Compose("RQL : OData : operator `%s` on property `%s` cannot compare with null")
+	ErrODataValueMustBeString   = err.Compose("RQL : OData : function `%s` expects a string literal for property `%s`")
+	ErrODataInvalidOrderBy      = err.Compose("RQL : OData : invalid $orderby `%s` expected `property [asc|desc]`")
+	ErrODataInvalidNumber       = err.Compose("RQL : OData : %s must be a non negative whole number got `%s`")
+	ErrODataInvalidCount        = err.Compose("RQL : OData : $count must be either true or false got `%s`")
+	ErrODataSkipNotAligned      = err.Compose("RQL : OData : $skip (%d) must be a multiple of $top (%d)")
+	ErrODataSkipWithoutTop      = err.Compose("RQL : OData : $skip (%d) requires $top")
+
+	odataCompareOps = map[string]string{"eq": filterEq, "ne": filterNe, "gt": filterGt, "ge": filterGe, "lt": filterLt, "le": filterLe}
+	// odataLikeFunctions : function => like pattern , substringof is the odata v2 form of contains with the arguments swapped
+	odataLikeFunctions = map[string]string{"contains": "%%%s%%", "startswith": "%s%%", "endswith": "%%%s", "substringof": "%%%s%%"}
+	// odataTypedLiterals : v2 literals ie datetime'2020-01-01T00:00:00' , the value is kept as a string
+	odataTypedLiterals = map[string]bool{"datetime": true, "datetimeoffset": true, "guid": true, "time": true}
+)
+
+// ODataMapping : maps odata property paths onto the columns of an entity schema
+//
+// Example :
+//			mapping := rql.ODataMapping{
+//				Schema:     rql.GetSchemaFromTaggedEntity(entity.AccountPublic{}, "db"),
+//				Properties: map[string]string{"Email": "email", "Settings/Theme": "settings.theme"},
+//			}
+//			q, err := mapping.ParseQuery(ctx.Request.URL.Query())
+//			res, err := repo.GetWithFilterExpressionPaginated(q.Filter, q.Pagination, q.Sort)
+type ODataMapping struct {
+	Schema *Schema
+	// Properties : odata property path (case insensitive , `/` separated) => schema column ,
+	// properties that are not mapped fall back to the schema column of the same name (with `/` read as `.` for json paths)
+	Properties map[string]string
+	// Pagination : options of $top / $skip (the max page size , the base of the pages) , defaults to DefaultPaginationOptions
+	Pagination *PaginationOptions
+}
+
+// ODataQuery : the odata query options converted to rql
+type ODataQuery struct {
+	Filter *FilterExpression
+	Sort   *SortExpression
+	// Pagination : nil if neither $top nor $skip are set
+	Pagination *PaginationExpression
+	// Select : selected columns , empty selects every column (see Schema.ProjectColumns)
+	Select []string
+	// Count : $count=true , the total count should be included in the response
+	Count bool
+}
+
+// Column : the schema column for an odata property path ie `Address/City`
+func (m *ODataMapping) Column(property string) (string, error) {
+	if col, ok := mapColumn(m.Schema, m.Properties, property); ok {
+		return col, nil
+	}
+	if col, ok := mapColumn(m.Schema, nil, strings.ReplaceAll(property, odataPathSeparator, jsonPathSeparator)); ok {
+		return col, nil
+	}
+	return "", ErrODataUnknownProperty(property)
+}
+
+// ParseQuery : converts the odata system query options ($filter , $orderby , $top , $skip , $select , $count)
+//
+// $skip has to be a multiple of $top since the pagination is page based
+func (m *ODataMapping) ParseQuery(query url.Values) (*ODataQuery, error) {
+	var (
+		q   ODataQuery
+		err error
+	)
+	q.Filter, err = m.ParseFilter(query.Get(ODataFilter))
+	if err != nil {
+		return nil, err
+	}
+	q.Sort, err = m.ParseOrderBy(query.Get(ODataOrderBy))
+	if err != nil {
+		return nil, err
+	}
+	q.Pagination, err = m.paginationOptions().FromOData(query.Get(ODataTop), query.Get(ODataSkip))
+	if err != nil {
+		return nil, err
+	}
+	q.Select, err = m.ParseSelect(query.Get(ODataSelect))
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(query.Get(ODataCount)) {
+	case "", "false":
+	case "true":
+		q.Count = true
+	default:
+		return nil, ErrODataInvalidCount(query.Get(ODataCount))
+	}
+	return &q, nil
+}
+
+// ParseFilter : converts an odata $filter into a filter expression on the mapped columns (empty filter matches everything)
+//
+// eq , ne , gt , ge , lt , le , in , and , or , not and grouping are supported ,
+// contains / startswith / endswith (and the v2 substringof) map to like , `eq null` / `ne null` map to present
+func (m *ODataMapping) ParseFilter(filter string) (*FilterExpression, error) {
+	tokens, err := tokenizeOData(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &FilterExpression{BinaryOperation: ANDOperator}, nil
+	}
+	p := odataParser{tokens: tokens, mapping: m, length: len(filter)}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, ErrODataInvalidFilter(p.peek().pos, fmt.Sprintf("unexpected `%s`", p.peek().text))
+	}
+	if expr.isLeaf() {
+		return &FilterExpression{BinaryOperation: ANDOperator, Properties: []*FilterExpression{expr}}, nil
+	}
+	return expr, nil
+}
+
+// ParseOrderBy : converts an odata $orderby ie `Name desc,CreatedAt` into a sort expression
+func (m *ODataMapping) ParseOrderBy(orderBy string) (*SortExpression, error) {
+	sort := &SortExpression{}
+	if strings.TrimSpace(orderBy) == "" {
+		return sort, nil
+	}
+	for _, item := range strings.Split(orderBy, ",") {
+		parts := strings.Fields(item)
+		if len(parts) == 0 || len(parts) > 2 || strings.ContainsAny(parts[0], "()") {
+			return nil, ErrODataInvalidOrderBy(strings.TrimSpace(item))
+		}
+		col, err := m.Column(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		direction := odataAsc
+		if len(parts) == 2 {
+			direction = strings.ToLower(parts[1])
+		}
+		switch direction {
+		case odataAsc:
+			sort = sort.ThenBy(col).Asc()
+		case odataDesc:
+			sort = sort.ThenBy(col).Desc()
+		default:
+			return nil, ErrODataInvalidOrderBy(strings.TrimSpace(item))
+		}
+	}
+	return sort, nil
+}
+
+// ParseSelect : converts an odata $select into the selected columns (empty or `*` selects every column)
+func (m *ODataMapping) ParseSelect(sel string) ([]string, error) {
+	var cols []string
+	for _, item := range strings.Split(sel, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || item == "*" {
+			continue
+		}
+		col, err := m.Column(item)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+func (m *ODataMapping) paginationOptions() PaginationOptions {
+	if m.Pagination != nil {
+		return *m.Pagination
+	}
+	return DefaultPaginationOptions
+}
+
+// ParseODataPagination : converts $top / $skip into a page based pagination expression using the DefaultPaginationOptions (see PaginationOptions.FromOData)
+func ParseODataPagination(top string, skip string) (*PaginationExpression, error) {
+	return DefaultPaginationOptions.FromOData(top, skip)
+}
+
+// FromOData : converts $top / $skip into a page based pagination expression (nil if both are empty) ,
+// $top is the page size so it is capped at the MaxSize of the options
+func (o PaginationOptions) FromOData(top string, skip string) (*PaginationExpression, error) {
+	if top == "" && skip == "" {
+		return nil, nil
+	}
+	size, err := parseODataNumber(ODataTop, top)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := parseODataNumber(ODataSkip, skip)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case top == "" || size == 0:
+		if offset == 0 {
+			return nil, nil
+		}
+		return nil, ErrODataSkipWithoutTop(offset)
+	case offset%size != 0:
+		return nil, ErrODataSkipNotAligned(offset, size)
+	}
+	return o.New(offset/size+o.Base, size)
+}
+
+func parseODataNumber(option string, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, ErrODataInvalidNumber(option, value)
+	}
+	return n, nil
+}
+
+// ProjectOData : strips the hidden columns and keeps only the $select columns of the records
+func ProjectOData[t any](s *Schema, q *ODataQuery, records []*t) ([]map[string]interface{}, error) {
+	out := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		projected, err := s.ProjectColumns(record, q.Select)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, projected)
+	}
+	return out, nil
+}
+
+type odataToken struct {
+	kind int
+	text string
+	// value : decoded string literal
+	value string
+	pos   int
+}
+
+func tokenizeOData(filter string) ([]odataToken, error) {
+	var tokens []odataToken
+	for i := 0; i < len(filter); {
+		switch filter[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+			continue
+		case '(':
+			tokens = append(tokens, odataToken{kind: odataTokenOpenParen, text: "(", pos: i})
+			i++
+			continue
+		case ')':
+			tokens = append(tokens, odataToken{kind: odataTokenCloseParen, text: ")", pos: i})
+			i++
+			continue
+		case ',':
+			tokens = append(tokens, odataToken{kind: odataTokenComma, text: ",", pos: i})
+			i++
+			continue
+		case '\'':
+			value, end, err := readODataString(filter, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, odataToken{kind: odataTokenString, text: filter[i:end], value: value, pos: i})
+			i = end
+			continue
+		}
+		end := i
+		for end < len(filter) && !strings.ContainsRune(" \t\n\r(),'", rune(filter[end])) {
+			end++
+		}
+		word := filter[i:end]
+		// typed literal ie datetime'2020-01-01T00:00:00'
+		if end < len(filter) && filter[end] == '\'' && odataTypedLiterals[strings.ToLower(word)] {
+			value, strEnd, err := readODataString(filter, end)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, odataToken{kind: odataTokenString, text: filter[i:strEnd], value: value, pos: i})
+			i = strEnd
+			continue
+		}
+		tokens = append(tokens, odataToken{kind: odataTokenWord, text: word, pos: i})
+		i = end
+	}
+	return tokens, nil
+}
+
+// readODataString : single quoted string where quotes are escaped by doubling them ie 'O''Neil'
+func readODataString(filter string, start int) (string, int, error) {
+	var value strings.Builder
+	for i := start + 1; i < len(filter); i++ {
+		if filter[i] != '\'' {
+			value.WriteByte(filter[i])
+			continue
+		}
+		if i+1 < len(filter) && filter[i+1] == '\'' {
+			value.WriteByte('\'')
+			i++
+			continue
+		}
+		return value.String(), i + 1, nil
+	}
+	return "", 0, ErrODataInvalidFilter(start, "unterminated string")
+}
+
+type odataParser struct {
+	tokens  []odataToken
+	pos     int
+	length  int
+	mapping *ODataMapping
+}
+
+func (p *odataParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *odataParser) peek() odataToken {
+	if p.done() {
+		return odataToken{kind: -1, pos: p.length}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *odataParser) peekAt(offset int) odataToken {
+	if p.pos+offset >= len(p.tokens) {
+		return odataToken{kind: -1, pos: p.length}
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *odataParser) next() odataToken {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *odataParser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == odataTokenWord && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *odataParser) expect(kind int, text string) error {
+	if tok := p.next(); tok.kind != kind {
+		return ErrODataInvalidFilter(tok.pos, fmt.Sprintf("expected `%s`", text))
+	}
+	return nil
+}
+
+func (p *odataParser) parseOr() (*FilterExpression, error) {
+	return p.parseBinary(odataOr, OROperator, p.parseAnd)
+}
+
+func (p *odataParser) parseAnd() (*FilterExpression, error) {
+	return p.parseBinary(odataAnd, ANDOperator, p.parseUnary)
+}
+
+// parseBinary : operand (keyword operand)* , chained operands end up in 1 group
+func (p *odataParser) parseBinary(keyword string, op string, operand func() (*FilterExpression, error)) (*FilterExpression, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isKeyword(keyword) {
+		return left, nil
+	}
+	group := &FilterExpression{BinaryOperation: op}
+	group.Properties = appendFlattened(group.Properties, op, left)
+	for p.isKeyword(keyword) {
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		group.Properties = appendFlattened(group.Properties, op, right)
+	}
+	return group, nil
+}
+
+func (p *odataParser) parseUnary() (*FilterExpression, error) {
+	if p.isKeyword(odataNot) {
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return expr.Negate()
+	}
+	if p.peek().kind == odataTokenOpenParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(odataTokenCloseParen, ")")
+	}
+	if p.peek().kind == odataTokenWord && p.peekAt(1).kind == odataTokenOpenParen {
+		return p.parseFunction()
+	}
+	return p.parseComparison()
+}
+
+// parseFunction : contains(Name,'bob') , startswith / endswith and the v2 substringof('bob',Name)
+func (p *odataParser) parseFunction() (*FilterExpression, error) {
+	fnTok := p.next()
+	fn := strings.ToLower(fnTok.text)
+	pattern, ok := odataLikeFunctions[fn]
+	if !ok {
+		return nil, ErrODataUnsupportedFunction(fnTok.text, "contains , startswith , endswith , substringof")
+	}
+	p.next()
+	first := p.next()
+	if err := p.expect(odataTokenComma, ","); err != nil {
+		return nil, err
+	}
+	second := p.next()
+	if err := p.expect(odataTokenCloseParen, ")"); err != nil {
+		return nil, err
+	}
+	property, literal := first, second
+	if fn == "substringof" {
+		property, literal = second, first
+	}
+	if property.kind != odataTokenWord {
+		return nil, ErrODataInvalidFilter(property.pos, "expected a property")
+	}
+	col, err := p.mapping.Column(property.text)
+	if err != nil {
+		return nil, err
+	}
+	if literal.kind != odataTokenString {
+		return nil, ErrODataValueMustBeString(fnTok.text, property.text)
+	}
+	value := fmt.Sprintf(pattern, EscapeLikePattern(literal.value))
+	return &FilterExpression{Column: col, Op: filterLike, Value: value}, nil
+}
+
+// parseComparison : `property op value` , `property in (values)` or a lone boolean property (`IsActive` => `IsActive eq true`)
+func (p *odataParser) parseComparison() (*FilterExpression, error) {
+	propTok := p.next()
+	if propTok.kind != odataTokenWord {
+		return nil, ErrODataInvalidFilter(propTok.pos, "expected a property")
+	}
+	col, err := p.mapping.Column(propTok.text)
+	if err != nil {
+		return nil, err
+	}
+
+	opTok := p.peek()
+	if opTok.kind != odataTokenWord || p.isKeyword(odataAnd) || p.isKeyword(odataOr) {
+		return &FilterExpression{Column: col, Op: filterEq, Value: true}, nil
+	}
+	p.next()
+	op := strings.ToLower(opTok.text)
+
+	if op == odataIn {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &FilterExpression{Column: col, Op: filterIn, Value: values}, nil
+	}
+	rqlOp, ok := odataCompareOps[op]
+	if !ok {
+		return nil, ErrODataUnsupportedOperator(opTok.text, "eq , ne , gt , ge , lt , le , in , and , or , not")
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		switch rqlOp {
+		case filterEq:
+			return &FilterExpression{Column: col, Op: filterPresent, Value: false}, nil
+		case filterNe:
+			return &FilterExpression{Column: col, Op: filterPresent, Value: true}, nil
+		}
+		return nil, ErrODataNullNotComparable(opTok.text, propTok.text)
+	}
+	return &FilterExpression{Column: col, Op: rqlOp, Value: value}, nil
+}
+
+// parseList : ('a','b',1)
+func (p *odataParser) parseList() ([]interface{}, error) {
+	if err := p.expect(odataTokenOpenParen, "("); err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		tok := p.next()
+		if tok.kind == odataTokenCloseParen {
+			return values, nil
+		}
+		if tok.kind != odataTokenComma {
+			return nil, ErrODataInvalidFilter(tok.pos, "expected `,` or `)`")
+		}
+	}
+}
+
+// parseValue : string , number , boolean , null or an unquoted date / guid (kept as a string)
+func (p *odataParser) parseValue() (interface{}, error) {
+	tok := p.next()
+	switch tok.kind {
+	case odataTokenString:
+		return tok.value, nil
+	case odataTokenWord:
+		switch strings.ToLower(tok.text) {
+		case "null":
+			return nil, nil
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		if n, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimRight(tok.text, "mMdDfF"), 64)
+		if err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, nil
+		}
+		// NaN / INF (and numbers out of the float64 range) cannot be compared by the backends
+		if err == nil || errors.Is(err, strconv.ErrRange) {
+			return nil, ErrODataInvalidFilter(tok.pos, fmt.Sprintf("number `%s` is not finite", tok.text))
+		}
+		if tok.text[0] >= '0' && tok.text[0] <= '9' {
+			return tok.text, nil
+		}
+	}
+	return nil, ErrODataInvalidFilter(tok.pos, "expected a value")
+}
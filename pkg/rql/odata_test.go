@@ -0,0 +1,207 @@
+package rql
+
+import (
+	"net/url"
+	"testing"
+)
+
+type odataTestUser struct {
+	ID       string                 `json:"id" db:"id"`
+	Name     string                 `json:"name" db:"name"`
+	Age      int                    `json:"age" db:"age"`
+	Active   bool                   `json:"active" db:"active"`
+	Settings map[string]interface{} `json:"settings" db:"settings" rql_json:"1"`
+}
+
+func odataTestMapping() *ODataMapping {
+	return &ODataMapping{
+		Schema:     GetSchemaFromTaggedEntity(odataTestUser{}, "db"),
+		Properties: map[string]string{"UserName": "name"},
+	}
+}
+
+func TestODataParseFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   *FilterExpression
+	}{
+		{
+			name:   "empty filter matches everything",
+			filter: "",
+			want:   groupOf(ANDOperator),
+		},
+		{
+			name:   "mapped property",
+			filter: `UserName eq 'bob'`,
+			want:   groupOf(ANDOperator, leaf("name", filterEq, "bob")),
+		},
+		{
+			name:   "and binds tighter than or",
+			filter: `name eq 'a' or name eq 'b' and age gt 3`,
+			want:   groupOf(OROperator, leaf("name", filterEq, "a"), groupOf(ANDOperator, leaf("name", filterEq, "b"), leaf("age", filterGt, int64(3)))),
+		},
+		{
+			name:   "parentheses override the precedence",
+			filter: `(name eq 'a' or name eq 'b') and age gt 3`,
+			want:   groupOf(ANDOperator, groupOf(OROperator, leaf("name", filterEq, "a"), leaf("name", filterEq, "b")), leaf("age", filterGt, int64(3))),
+		},
+		{
+			name:   "not is pushed down",
+			filter: `not (name eq 'a' or age le 3)`,
+			want:   groupOf(ANDOperator, leaf("name", filterNe, "a"), leaf("age", filterGt, int64(3))),
+		},
+		{
+			name:   "lone boolean property",
+			filter: `active and age ge 18`,
+			want:   groupOf(ANDOperator, leaf("active", filterEq, true), leaf("age", filterGe, int64(18))),
+		},
+		{
+			name:   "in list",
+			filter: `age in (1, 2.5, 'x')`,
+			want:   groupOf(ANDOperator, leaf("age", filterIn, []interface{}{int64(1), 2.5, "x"})),
+		},
+		{
+			name:   "functions map to like with escaped wildcards",
+			filter: `contains(name,'50%') and startswith(name,'a_') and endswith(name,'z') and substringof('mid',name)`,
+			want: groupOf(ANDOperator,
+				leaf("name", filterLike, `%50\%%`),
+				leaf("name", filterLike, `a\_%`),
+				leaf("name", filterLike, `%z`),
+				leaf("name", filterLike, `%mid%`),
+			),
+		},
+		{
+			name:   "doubled quotes",
+			filter: `name eq 'O''Neil'`,
+			want:   groupOf(ANDOperator, leaf("name", filterEq, "O'Neil")),
+		},
+		{
+			name:   "null maps to present",
+			filter: `name eq null or age ne null`,
+			want:   groupOf(OROperator, leaf("name", filterPresent, false), leaf("age", filterPresent, true)),
+		},
+		{
+			name:   "typed literal is kept as a string",
+			filter: `name gt datetime'2020-01-01T00:00:00'`,
+			want:   groupOf(ANDOperator, leaf("name", filterGt, "2020-01-01T00:00:00")),
+		},
+		{
+			name:   "json path",
+			filter: `settings/theme eq 'dark'`,
+			want:   groupOf(ANDOperator, leaf("settings.theme", filterEq, "dark")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := odataTestMapping().ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scimJSON(t, got) != scimJSON(t, tt.want) {
+				t.Fatalf("got  %s\nwant %s", scimJSON(t, got), scimJSON(t, tt.want))
+			}
+		})
+	}
+}
+
+func TestODataParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   error
+	}{
+		{name: "unknown property", filter: `nope eq 1`, want: ErrODataUnknownProperty("nope")},
+		{name: "unsupported operator", filter: `age has 1`, want: ErrODataUnsupportedOperator("has", "eq , ne , gt , ge , lt , le , in , and , or , not")},
+		{name: "unsupported function", filter: `length(name) eq 1`, want: ErrODataUnsupportedFunction("length", "contains , startswith , endswith , substringof")},
+		{name: "function on a number", filter: `contains(name,1)`, want: ErrODataValueMustBeString("contains", "name")},
+		{name: "unterminated string", filter: `name eq 'abc`, want: ErrODataInvalidFilter(8, "unterminated string")},
+		{name: "unclosed parenthesis", filter: `(name eq 'a'`, want: ErrODataInvalidFilter(12, "expected `)`")},
+		{name: "trailing token", filter: `name eq 'a' )`, want: ErrODataInvalidFilter(12, "unexpected `)`")},
+		{name: "gt null", filter: `age gt null`, want: ErrODataNullNotComparable("gt", "age")},
+		{name: "nan", filter: `age eq NaN`, want: ErrODataInvalidFilter(7, "number `NaN` is not finite")},
+		{name: "infinity", filter: `age lt Infinity`, want: ErrODataInvalidFilter(7, "number `Infinity` is not finite")},
+		{name: "negative infinity", filter: `age gt -Infinity`, want: ErrODataInvalidFilter(7, "number `-Infinity` is not finite")},
+		{name: "out of range", filter: `age gt 1e400`, want: ErrODataInvalidFilter(7, "number `1e400` is not finite")},
+		{name: "nan in a list", filter: `age in (1, nan)`, want: ErrODataInvalidFilter(11, "number `nan` is not finite")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := odataTestMapping().ParseFilter(tt.filter)
+			if err == nil || err.Error() != tt.want.Error() {
+				t.Fatalf("got %v , want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestODataParseOrderByAndSelect(t *testing.T) {
+	m := odataTestMapping()
+	sort, err := m.ParseOrderBy("UserName desc, age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sort.clauses) != 2 || sort.clauses[0].column != "name" || sort.clauses[0].direction != DESC || sort.clauses[1].direction != ASC {
+		t.Fatalf("sort = %+v", sort.clauses)
+	}
+	_, err = m.ParseOrderBy("age sideways")
+	if err == nil {
+		t.Fatal("expected an error for an unknown direction")
+	}
+	cols, err := m.ParseSelect("UserName, age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 2 || cols[0] != "name" || cols[1] != "age" {
+		t.Fatalf("select = %v", cols)
+	}
+}
+
+func TestODataPagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      *PaginationOptions
+		top, skip string
+		wantNil   bool
+		wantErr   bool
+		page      int
+		size      int
+	}{
+		{name: "nothing set", wantNil: true},
+		{name: "first page", top: "20", page: 1, size: 20},
+		{name: "third page", top: "20", skip: "40", page: 3, size: 20},
+		{name: "zero based options", opts: &PaginationOptions{Base: 0, MaxSize: 50}, top: "20", skip: "40", page: 2, size: 20},
+		{name: "above the max size", opts: &PaginationOptions{Base: 1, MaxSize: 50}, top: "51", wantErr: true},
+		{name: "skip not aligned", top: "20", skip: "10", wantErr: true},
+		{name: "skip without top", skip: "10", wantErr: true},
+		{name: "negative top", top: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := odataTestMapping()
+			m.Pagination = tt.opts
+			q, err := m.ParseQuery(url.Values{ODataTop: {tt.top}, ODataSkip: {tt.skip}})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNil {
+				if q.Pagination != nil {
+					t.Fatalf("pagination = %+v , want nil", q.Pagination)
+				}
+				return
+			}
+			if q.Pagination.Page() != tt.page || q.Pagination.Size() != tt.size {
+				t.Fatalf("page = %d , size = %d , want %d , %d", q.Pagination.Page(), q.Pagination.Size(), tt.page, tt.size)
+			}
+			if q.Pagination.Offset() != q.Pagination.Index()*tt.size {
+				t.Fatalf("offset = %d", q.Pagination.Offset())
+			}
+		})
+	}
+}
@@ -5,30 +5,85 @@ import (
 	"strconv"
 
 	"github.com/baderkha/library/pkg/conditional"
+	"github.com/baderkha/library/pkg/err"
+)
+
+// CountMode : how the total of a paginated query is computed
+type CountMode string
+
+const (
+	// CountExact : run an exact count of the matching records (ie `COUNT(*)`)
+	CountExact CountMode = "exact"
+	// CountEstimated : use the table statistics of the database instead of counting ,
+	// only possible for unfiltered queries , falls back to CountExact otherwise
+	CountEstimated CountMode = "estimated"
+	// CountNone : do not count , only detect if there is a next page (fetches 1 extra record)
+	CountNone CountMode = "none"
 )
 
 var (
-	errorPageNotANumber = errors.New("expected page to be a number got something else instead ...")
-	errorSizeNotANumber = errors.New("expected size to be a number got something else instead ...")
+	errorPageNotANumber = errors.New("RQL : expected page to be a number got something else instead ...")
+	errorSizeNotANumber = errors.New("RQL : expected size to be a number got something else instead ...")
+
+	ErrPageOutOfRange     = err.Compose("RQL : page must be greater than or equal to %d got %d")
+	ErrPageSizeOutOfRange = err.Compose("RQL : page size must be between 1 and %d got %d")
+	ErrPageSizeTooSmall   = err.Compose("RQL : page size must be at least 1 got %d")
+	ErrPaginationBase     = err.Compose("RQL : pagination base must be 0 or 1 got %d")
+	ErrCountModeInvalid   = err.Compose("RQL : count mode `%s` is not supported , supported modes are exact , estimated , none")
+
+	// DefaultPaginationOptions : 1 based pages of 10 records with an exact count ,
+	// the page size is not capped (PaginationExpressionFromUserInput never was) , cap it with your own options
+	DefaultPaginationOptions = PaginationOptions{
+		Base:        1,
+		DefaultSize: 10,
+		Count:       CountExact,
+	}
 )
 
-// PaginationExpression : pagination expression
-type PaginationExpression struct {
-	page int
-	size int
+// ParseCountMode : count mode from user input , empty defaults to CountExact
+func ParseCountMode(mode string) (CountMode, error) {
+	switch CountMode(mode) {
+	case "", CountExact:
+		return CountExact, nil
+	case CountEstimated, CountNone:
+		return CountMode(mode), nil
+	}
+	return "", ErrCountModeInvalid(mode)
 }
 
-func (p *PaginationExpression) Page() int {
-	return p.page
+// PaginationOptions : how user input is turned into a pagination expression
+//
+// Example :
+//			opts := rql.PaginationOptions{Base: 0, DefaultSize: 25, MaxSize: 200, Count: rql.CountNone}
+//			p, err := opts.FromUserInput(ctx.Query("page"), ctx.Query("size"))
+type PaginationOptions struct {
+	// Base : number of the first page , 0 or 1
+	Base int
+	// DefaultSize : page size used when the size is empty
+	DefaultSize int
+	// MaxSize : largest page size a user can ask for (0 for no limit)
+	MaxSize int
+	// Count : how the total is computed , defaults to CountExact
+	Count CountMode
 }
 
-func (p *PaginationExpression) Size() int {
-	return p.size
+func (o PaginationOptions) validate() error {
+	if o.Base != 0 && o.Base != 1 {
+		return ErrPaginationBase(o.Base)
+	}
+	_, err := ParseCountMode(string(o.Count))
+	return err
 }
 
-func PaginationExpressionFromUserInput(page string, size string) (*PaginationExpression, error) {
-	page = conditional.Ternary(page == "", "1", page)
-	size = conditional.Ternary(size == "", "10", size)
+// FromUserInput : pagination expression from the page number (in the options' base) and the page size ,
+// empty values default to the first page and the default size
+func (o PaginationOptions) FromUserInput(page string, size string) (*PaginationExpression, error) {
+	err := o.validate()
+	if err != nil {
+		return nil, err
+	}
+	page = conditional.Ternary(page == "", strconv.Itoa(o.Base), page)
+	size = conditional.Ternary(size == "", strconv.Itoa(o.DefaultSize), size)
 	p, err := strconv.Atoi(page)
 	if err != nil {
 		return nil, errorPageNotANumber
@@ -37,8 +92,98 @@ func PaginationExpressionFromUserInput(page string, size string) (*PaginationExp
 	if err != nil {
 		return nil, errorSizeNotANumber
 	}
+	return o.New(p, s)
+}
+
+// New : pagination expression for a page number (in the options' base) and a page size
+func (o PaginationOptions) New(page int, size int) (*PaginationExpression, error) {
+	err := o.validate()
+	if err != nil {
+		return nil, err
+	}
+	if page < o.Base {
+		return nil, ErrPageOutOfRange(o.Base, page)
+	}
+	if size < 1 {
+		return nil, ErrPageSizeTooSmall(size)
+	}
+	if o.MaxSize > 0 && size > o.MaxSize {
+		return nil, ErrPageSizeOutOfRange(o.MaxSize, size)
+	}
 	return &PaginationExpression{
-		page: p,
-		size: s,
+		index: page - o.Base,
+		size:  size,
+		base:  o.Base,
+		count: conditional.Ternary(o.Count == "", CountExact, o.Count),
 	}, nil
 }
+
+// First : the first page with the default size
+func (o PaginationOptions) First() *PaginationExpression {
+	return &PaginationExpression{
+		size:  conditional.Ternary(o.DefaultSize > 0, o.DefaultSize, DefaultPaginationOptions.DefaultSize),
+		base:  conditional.Ternary(o.Base == 1, 1, 0),
+		count: conditional.Ternary(o.Count == "", CountExact, o.Count),
+	}
+}
+
+// PaginationExpression : pagination expression
+type PaginationExpression struct {
+	// index : zero based page index
+	index int
+	size  int
+	base  int
+	count CountMode
+}
+
+// Page : page number in the base the expression was created with (what the user sent)
+func (p *PaginationExpression) Page() int {
+	return p.index + p.base
+}
+
+// Index : zero based page index
+func (p *PaginationExpression) Index() int {
+	return p.index
+}
+
+// Base : number of the first page , 0 or 1
+func (p *PaginationExpression) Base() int {
+	return p.base
+}
+
+func (p *PaginationExpression) Size() int {
+	return p.size
+}
+
+// Offset : number of records before the page
+func (p *PaginationExpression) Offset() int {
+	return p.index * p.size
+}
+
+// CountMode : how the total should be computed
+func (p *PaginationExpression) CountMode() CountMode {
+	return p.count
+}
+
+// WithCountMode : copy of the expression with a different count mode
+func (p *PaginationExpression) WithCountMode(mode CountMode) (*PaginationExpression, error) {
+	mode, err := ParseCountMode(string(mode))
+	if err != nil {
+		return nil, err
+	}
+	cp := *p
+	cp.count = mode
+	return &cp, nil
+}
+
+// WithIndex : copy of the expression for another zero based page index (used to build navigation links)
+func (p *PaginationExpression) WithIndex(index int) *PaginationExpression {
+	cp := *p
+	cp.index = conditional.Ternary(index < 0, 0, index)
+	return &cp
+}
+
+// PaginationExpressionFromUserInput : pagination expression using the DefaultPaginationOptions (1 based pages)
+func PaginationExpressionFromUserInput(page string, size string) (*PaginationExpression, error) {
+	return DefaultPaginationOptions.FromUserInput(page, size)
+}
@@ -0,0 +1,83 @@
+package rql
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PaginationLinks : navigation links of a page , empty when the page does not exist
+// (ie no prev on the first page , no last when the total is unknown)
+type PaginationLinks struct {
+	First string `json:"first,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// PaginationLinkBuilder : builds navigation links by rewriting the page / size query params of a url ,
+// every other query param (filter , sort ...) is kept as is
+//
+// Example :
+//			res, err := repo.GetWithFilterExpressionPaginated(f, p, s)
+//			res.AddLinks(rql.NewPaginationLinkBuilder(ctx.Request))
+type PaginationLinkBuilder struct {
+	URL *url.URL
+	// PageParam : defaults to `page`
+	PageParam string
+	// SizeParam : defaults to `size`
+	SizeParam string
+}
+
+// NewPaginationLinkBuilder : link builder from the incoming request , links are relative to the host (path + query)
+func NewPaginationLinkBuilder(r *http.Request) *PaginationLinkBuilder {
+	u := *r.URL
+	return &PaginationLinkBuilder{
+		URL: &u,
+	}
+}
+
+func (b *PaginationLinkBuilder) link(p *PaginationExpression) string {
+	var (
+		u         = *b.URL
+		query     = u.Query()
+		pageParam = b.PageParam
+		sizeParam = b.SizeParam
+	)
+	if pageParam == "" {
+		pageParam = "page"
+	}
+	if sizeParam == "" {
+		sizeParam = "size"
+	}
+	query.Set(pageParam, strconv.Itoa(p.Page()))
+	query.Set(sizeParam, strconv.Itoa(p.Size()))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Build : links for the current page , totalPages < 0 means the total is unknown (no last link)
+//
+// there is no last link without pages and the prev link of a page past the end is the last page
+func (b *PaginationLinkBuilder) Build(p *PaginationExpression, totalPages int64, hasNext bool) *PaginationLinks {
+	if b == nil || b.URL == nil || p == nil {
+		return nil
+	}
+	links := &PaginationLinks{
+		First: b.link(p.WithIndex(0)),
+	}
+	prev := p.Index() - 1
+	if totalPages >= 0 && int64(prev) >= totalPages {
+		prev = int(totalPages) - 1
+	}
+	if prev >= 0 {
+		links.Prev = b.link(p.WithIndex(prev))
+	}
+	if hasNext {
+		links.Next = b.link(p.WithIndex(p.Index() + 1))
+	}
+	if totalPages > 0 {
+		links.Last = b.link(p.WithIndex(int(totalPages) - 1))
+	}
+	return links
+}
@@ -100,10 +100,16 @@ type FilterableEntity struct {
 
 type Schema struct {
 	supportedColumns map[string]*FilterableEntity
+	// hiddenColumns : columns stripped by a role view (see Schema.View)
+	hiddenColumns map[string]*FilterableEntity
 }
 
 func (s *Schema) GetColumnInternalName(col string) string {
-	return s.supportedColumns[col].ColumnNameInternal
+	fe := s.supportedColumns[col]
+	if fe == nil {
+		return ""
+	}
+	return fe.ColumnNameInternal
 }
 
 func (s *Schema) DoesColExist(col string) bool {
@@ -116,8 +122,34 @@ func (s *Schema) CheckTagExists(col string, tag string) bool {
 
 func (s *Schema) GetTagValue(col string, tag string) string {
 	fe := s.supportedColumns[col]
-	if fe != nil {
+	if fe == nil {
 		return ""
 	}
 	return fe.Tags[tag]
 }
+
+// IsGeoColumn : checks if the column holds geo points (see RQLGeoTag)
+func (s *Schema) IsGeoColumn(col string) bool {
+	return s.CheckTagExists(col, RQLGeoTag) || s.GetTagValue(col, typesenseTypeTag) == typesenseGeoPointType
+}
+
+// IsFullTextColumn : checks if the column has a full text index (see RQLFullTextTag)
+func (s *Schema) IsFullTextColumn(col string) bool {
+	return s.CheckTagExists(col, RQLFullTextTag)
+}
+
+// mapColumn : resolves an external attribute name (ie scim / odata) to a schema column using the attributes map (case insensitive)
+// attributes that are not mapped fall back to the schema column of the same name
+func mapColumn(schema *Schema, attributes map[string]string, attr string) (string, bool) {
+	col := attr
+	for external, mapped := range attributes {
+		if strings.EqualFold(external, attr) {
+			col = mapped
+			break
+		}
+	}
+	if schema == nil || !schema.DoesColOrJSONPathExist(col) {
+		return "", false
+	}
+	return col, true
+}
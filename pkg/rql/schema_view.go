@@ -0,0 +1,172 @@
+package rql
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	// RQLRolesTag : tag label listing the roles (comma separated) that are allowed to see a column
+	// columns without this tag are visible to every role
+	//
+	// Example :
+	//			Email string `json:"email" db:"email" rql_roles:"admin,support"`
+	RQLRolesTag = "rql_roles"
+)
+
+// IColumnPolicy : decides which columns a role is allowed to see
+type IColumnPolicy interface {
+	// CanSee : returns true if the role can filter / sort / read the column
+	CanSee(role string, col string, entity *FilterableEntity) bool
+}
+
+var _ IColumnPolicy = TagColumnPolicy{}
+var _ IColumnPolicy = MapColumnPolicy{}
+
+// TagColumnPolicy : column policy driven by the `rql_roles` struct tag
+type TagColumnPolicy struct{}
+
+// CanSee : returns true if the role can filter / sort / read the column
+func (p TagColumnPolicy) CanSee(role string, col string, entity *FilterableEntity) bool {
+	roles := entity.Tags[RQLRolesTag]
+	if roles == "" {
+		return true
+	}
+	return containsRole(strings.Split(roles, ","), role)
+}
+
+// MapColumnPolicy : column policy driven by a map of column => roles allowed to see it
+// columns that are not in the map are visible to every role
+//
+// Example :
+//			policy := rql.MapColumnPolicy{"email": {"admin"}, "is_verified": {"admin"}}
+type MapColumnPolicy map[string][]string
+
+// CanSee : returns true if the role can filter / sort / read the column
+func (p MapColumnPolicy) CanSee(role string, col string, entity *FilterableEntity) bool {
+	roles, isRestricted := p[col]
+	if !isRestricted {
+		return true
+	}
+	return containsRole(roles, role)
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if strings.TrimSpace(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ForRole : derive a restricted schema for a role using the `rql_roles` tags
+func (s *Schema) ForRole(role string) *Schema {
+	return s.View(role, TagColumnPolicy{})
+}
+
+// View : derive a restricted schema for a role using a column policy.
+//
+// hidden columns do not exist as far as the parsers and validators are concerned ,
+// so filtering / sorting on them fails the same way an unknown column does.
+// Validate user input against the view and keep using the full schema for your own base expressions
+func (s *Schema) View(role string, policy IColumnPolicy) *Schema {
+	view := Schema{
+		supportedColumns: make(map[string]*FilterableEntity, len(s.supportedColumns)),
+		hiddenColumns:    make(map[string]*FilterableEntity),
+	}
+	for col, hidden := range s.hiddenColumns {
+		view.hiddenColumns[col] = hidden
+	}
+	for col, entity := range s.supportedColumns {
+		if policy.CanSee(role, col, entity) {
+			view.supportedColumns[col] = entity
+		} else {
+			view.hiddenColumns[col] = entity
+		}
+	}
+	return &view
+}
+
+// GetSchemaFromTaggedEntityForRole : same as GetSchemaFromTaggedEntity but restricted to what the role can see (see RQLRolesTag)
+func GetSchemaFromTaggedEntityForRole(model interface{}, filterColTag string, role string) *Schema {
+	return GetSchemaFromTaggedEntity(model, filterColTag).ForRole(role)
+}
+
+// hiddenJSONKeys : the json keys of the hidden columns
+func (s *Schema) hiddenJSONKeys() []string {
+	var keys []string
+	for col, entity := range s.hiddenColumns {
+		if key, ok := columnJSONKey(col, entity); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// columnJSONKey : the json key of a column , false if the column is not serialized
+func columnJSONKey(col string, entity *FilterableEntity) (string, bool) {
+	key := strings.Split(entity.Tags["json"], ",")[0]
+	switch key {
+	case "-":
+		return "", false
+	case "":
+		return col, true
+	}
+	return key, true
+}
+
+// Project : strips the hidden columns from a record , the output is keyed by the json tags of the record
+// (numbers are json.Number so int64 values keep their precision)
+func (s *Schema) Project(record interface{}) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err = dec.Decode(&out)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range s.hiddenJSONKeys() {
+		delete(out, key)
+	}
+	return out, nil
+}
+
+// ProjectColumns : same as Project but only keeps the given columns (no columns keeps every visible one)
+func (s *Schema) ProjectColumns(record interface{}, cols []string) (map[string]interface{}, error) {
+	out, err := s.Project(record)
+	if err != nil || len(cols) == 0 {
+		return out, err
+	}
+	selected := make(map[string]interface{}, len(cols))
+	for _, col := range cols {
+		entity := s.supportedColumns[col]
+		if entity == nil {
+			continue
+		}
+		if key, ok := columnJSONKey(col, entity); ok {
+			if v, exists := out[key]; exists {
+				selected[key] = v
+			}
+		}
+	}
+	return selected, nil
+}
+
+// ProjectMany : strips the hidden columns from many records see Schema.Project
+func ProjectMany[t any](s *Schema, records []*t) ([]map[string]interface{}, error) {
+	out := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		projected, err := s.Project(record)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, projected)
+	}
+	return out, nil
+}
@@ -0,0 +1,400 @@
+package rql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/baderkha/library/pkg/err"
+)
+
+const (
+	scimTokenWord = iota
+	scimTokenString
+	scimTokenOpenParen
+	scimTokenCloseParen
+	scimTokenOpenBracket
+	scimTokenCloseBracket
+
+	scimAnd     = "and"
+	scimOr      = "or"
+	scimNot     = "not"
+	scimPresent = "pr"
+
+	scimSortDescending = "descending"
+	scimSortAscending  = "ascending"
+)
+
+var (
+	ErrSCIMInvalidFilter     = err.Compose("RQL : SCIM : invalid filter at position %d : %s")
+	ErrSCIMUnknownAttribute  = err.Compose("RQL : SCIM : attribute `%s` is not filterable")
+	ErrSCIMValueMustBeString = err.Compose("RQL : SCIM : operator `%s` on attribute `%s` expects a string value")
+	ErrSCIMNullNotComparable = err.Compose("RQL : SCIM : operator `%s` on attribute `%s` cannot compare with null")
+	ErrSCIMInvalidSortOrder  = err.Compose("RQL : SCIM : sortOrder `%s` must be either `ascending` or `descending`")
+	ErrSCIMNestedValuePath   = err.Compose("RQL : SCIM : attribute `%s` value filters cannot be nested")
+	ErrSCIMEmptyFilter       = err.Compose("RQL : SCIM : filter `%s` is empty")
+	ErrSCIMUnsupported       = err.Compose("RQL : SCIM : operator `%s` on attribute `%s` at position %d is not supported : %w")
+
+	scimCompareOps = map[string]string{"eq": filterEq, "ne": filterNe, "gt": filterGt, "ge": filterGe, "lt": filterLt, "le": filterLe}
+	scimLikeOps    = map[string]bool{"co": true, "sw": true, "ew": true}
+)
+
+// SCIMMapping : maps scim attribute paths onto the columns of an entity schema
+//
+// Example :
+//			mapping := rql.SCIMMapping{
+//				Schema: rql.GetSchemaFromTaggedEntity(entity.AccountPublic{}, "db"),
+//				Attributes: map[string]string{
+//					"userName":     "id",
+//					"emails.value": "email",
+//					"active":       "is_verified",
+//				},
+//			}
+//			f, err := mapping.Parse(`userName eq "bob" and emails[value co "@corp.com"]`)
+type SCIMMapping struct {
+	Schema *Schema
+	// Attributes : scim attribute path (case insensitive , without the schema urn) => schema column ,
+	// attributes that are not mapped fall back to the schema column of the same name
+	Attributes map[string]string
+	// Validator : optional , each predicate is validated against the schema as it is parsed so the operators
+	// the backend cannot run fail with ErrSCIMUnsupported (ie &FilterParserTypeSense{} rejects co / sw / ew / pr)
+	Validator IFilterValidator
+}
+
+// Column : the schema column for a scim attribute path ie `name.givenName` or `urn:ietf:params:scim:schemas:core:2.0:User:userName`
+func (m *SCIMMapping) Column(attrPath string) (string, error) {
+	attr := attrPath
+	if idx := strings.LastIndex(attr, ":"); idx >= 0 {
+		attr = attr[idx+1:]
+	}
+	col, ok := mapColumn(m.Schema, m.Attributes, attr)
+	if !ok {
+		return "", ErrSCIMUnknownAttribute(attrPath)
+	}
+	return col, nil
+}
+
+// Parse : converts a scim filter (rfc 7644 3.4.2.2) into a filter expression on the mapped columns
+//
+// eq , ne , gt , ge , lt , le map to their rql counterparts , co / sw / ew to like , pr (and eq / ne null) to present.
+// typesense supports neither like nor present , set the Validator to reject them with a scim error instead of failing in the repository.
+// `and` / `or` / `not` / grouping are supported with not > and > or precedence , `not` is pushed down (see FilterExpression.Negate).
+// value paths ie `emails[type eq "work"]` are flattened into `emails.type eq "work"` , map those paths in the Attributes
+func (m *SCIMMapping) Parse(filter string) (*FilterExpression, error) {
+	tokens, err := tokenizeSCIM(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, ErrSCIMEmptyFilter(filter)
+	}
+	p := scimParser{tokens: tokens, mapping: m, length: len(filter)}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected `%s`", p.peek().text)
+	}
+	if expr.isLeaf() {
+		return &FilterExpression{BinaryOperation: ANDOperator, Properties: []*FilterExpression{expr}}, nil
+	}
+	return expr, nil
+}
+
+// Sort : converts the scim sortBy / sortOrder list parameters into a sort expression (empty sortBy is no sorting)
+func (m *SCIMMapping) Sort(sortBy string, sortOrder string) (*SortExpression, error) {
+	if sortBy == "" {
+		return &SortExpression{}, nil
+	}
+	col, err := m.Column(sortBy)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(sortOrder) {
+	case "", scimSortAscending:
+		return SortBy(col).Asc(), nil
+	case scimSortDescending:
+		return SortBy(col).Desc(), nil
+	}
+	return nil, ErrSCIMInvalidSortOrder(sortOrder)
+}
+
+// FilterExpressionFromSCIM : generate filter expression from a scim filter (see SCIMMapping.Parse)
+func FilterExpressionFromSCIM(filter string, mapping *SCIMMapping) (*FilterExpression, error) {
+	return mapping.Parse(filter)
+}
+
+type scimToken struct {
+	kind int
+	text string
+	// value : decoded string literal
+	value string
+	pos   int
+}
+
+func tokenizeSCIM(filter string) ([]scimToken, error) {
+	var tokens []scimToken
+	for i := 0; i < len(filter); {
+		ch := filter[i]
+		switch ch {
+		case ' ', '\t', '\n', '\r':
+			i++
+			continue
+		case '(':
+			tokens = append(tokens, scimToken{kind: scimTokenOpenParen, text: "(", pos: i})
+			i++
+			continue
+		case ')':
+			tokens = append(tokens, scimToken{kind: scimTokenCloseParen, text: ")", pos: i})
+			i++
+			continue
+		case '[':
+			tokens = append(tokens, scimToken{kind: scimTokenOpenBracket, text: "[", pos: i})
+			i++
+			continue
+		case ']':
+			tokens = append(tokens, scimToken{kind: scimTokenCloseBracket, text: "]", pos: i})
+			i++
+			continue
+		case '"':
+			end := i + 1
+			for end < len(filter) && filter[end] != '"' {
+				if filter[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(filter) {
+				return nil, ErrSCIMInvalidFilter(i, "unterminated string")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(filter[i:end+1]), &value); err != nil {
+				return nil, ErrSCIMInvalidFilter(i, "malformed string "+err.Error())
+			}
+			tokens = append(tokens, scimToken{kind: scimTokenString, text: filter[i : end+1], value: value, pos: i})
+			i = end + 1
+			continue
+		}
+		end := i
+		for end < len(filter) && !strings.ContainsRune(" \t\n\r()[]\"", rune(filter[end])) {
+			end++
+		}
+		tokens = append(tokens, scimToken{kind: scimTokenWord, text: filter[i:end], pos: i})
+		i = end
+	}
+	return tokens, nil
+}
+
+type scimParser struct {
+	tokens  []scimToken
+	pos     int
+	length  int
+	mapping *SCIMMapping
+	// valuePath : the attribute of the value filter being parsed ie `emails` for `emails[type eq "work"]`
+	valuePath string
+}
+
+func (p *scimParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *scimParser) peek() scimToken {
+	if p.done() {
+		return scimToken{kind: -1, pos: p.length}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *scimParser) next() scimToken {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *scimParser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == scimTokenWord && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *scimParser) expect(kind int, text string) error {
+	if tok := p.next(); tok.kind != kind {
+		return ErrSCIMInvalidFilter(tok.pos, fmt.Sprintf("expected `%s`", text))
+	}
+	return nil
+}
+
+func (p *scimParser) errorf(format string, args ...interface{}) error {
+	return ErrSCIMInvalidFilter(p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+func (p *scimParser) parseOr() (*FilterExpression, error) {
+	return p.parseBinary(scimOr, OROperator, p.parseAnd)
+}
+
+func (p *scimParser) parseAnd() (*FilterExpression, error) {
+	return p.parseBinary(scimAnd, ANDOperator, p.parseUnary)
+}
+
+// parseBinary : operand (keyword operand)* , chained operands end up in 1 group
+func (p *scimParser) parseBinary(keyword string, op string, operand func() (*FilterExpression, error)) (*FilterExpression, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isKeyword(keyword) {
+		return left, nil
+	}
+	group := &FilterExpression{BinaryOperation: op}
+	group.Properties = appendFlattened(group.Properties, op, left)
+	for p.isKeyword(keyword) {
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		group.Properties = appendFlattened(group.Properties, op, right)
+	}
+	return group, nil
+}
+
+// appendFlattened : groups with the same operation are merged into their parent
+func appendFlattened(properties []*FilterExpression, op string, expr *FilterExpression) []*FilterExpression {
+	if !expr.isLeaf() && expr.BinaryOperation == op {
+		return append(properties, expr.Properties...)
+	}
+	return append(properties, expr)
+}
+
+func (p *scimParser) parseUnary() (*FilterExpression, error) {
+	if p.isKeyword(scimNot) {
+		p.next()
+		if err := p.expect(scimTokenOpenParen, "("); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(scimTokenCloseParen, ")"); err != nil {
+			return nil, err
+		}
+		return expr.Negate()
+	}
+	if p.peek().kind == scimTokenOpenParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(scimTokenCloseParen, ")")
+	}
+	return p.parseAttribute()
+}
+
+func (p *scimParser) parseAttribute() (*FilterExpression, error) {
+	tok := p.next()
+	if tok.kind != scimTokenWord {
+		return nil, ErrSCIMInvalidFilter(tok.pos, "expected an attribute")
+	}
+	attr := tok.text
+
+	if p.peek().kind == scimTokenOpenBracket {
+		if p.valuePath != "" {
+			return nil, ErrSCIMNestedValuePath(attr)
+		}
+		p.next()
+		p.valuePath = attr
+		expr, err := p.parseOr()
+		p.valuePath = ""
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(scimTokenCloseBracket, "]")
+	}
+
+	if p.valuePath != "" {
+		attr = p.valuePath + "." + attr
+	}
+	col, err := p.mapping.Column(attr)
+	if err != nil {
+		return nil, err
+	}
+
+	opTok := p.next()
+	op := strings.ToLower(opTok.text)
+	if opTok.kind != scimTokenWord {
+		return nil, ErrSCIMInvalidFilter(opTok.pos, "expected an operator")
+	}
+	if op == scimPresent {
+		return p.supported(&FilterExpression{Column: col, Op: filterPresent, Value: true}, attr, opTok)
+	}
+	if _, isCompare := scimCompareOps[op]; !isCompare && !scimLikeOps[op] {
+		return nil, ErrSCIMInvalidFilter(opTok.pos, fmt.Sprintf("unknown operator `%s`", opTok.text))
+	}
+
+	value, isString, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	expr, err := scimPredicate(attr, col, op, value, isString)
+	if err != nil {
+		return nil, err
+	}
+	return p.supported(expr, attr, opTok)
+}
+
+// supported : validates the predicate with the validator of the mapping (if any)
+func (p *scimParser) supported(expr *FilterExpression, attr string, opTok scimToken) (*FilterExpression, error) {
+	if p.mapping.Validator == nil {
+		return expr, nil
+	}
+	err := p.mapping.Validator.Validate(&FilterExpression{BinaryOperation: ANDOperator, Properties: []*FilterExpression{expr}}, p.mapping.Schema)
+	if err != nil {
+		return nil, ErrSCIMUnsupported(opTok.text, attr, opTok.pos, err)
+	}
+	return expr, nil
+}
+
+// parseValue : a json string , number , boolean or null
+func (p *scimParser) parseValue() (interface{}, bool, error) {
+	tok := p.next()
+	switch tok.kind {
+	case scimTokenString:
+		return tok.value, true, nil
+	case scimTokenWord:
+		var value interface{}
+		if err := json.Unmarshal([]byte(strings.ToLower(tok.text)), &value); err == nil {
+			return value, false, nil
+		}
+	}
+	return nil, false, ErrSCIMInvalidFilter(tok.pos, "expected a value")
+}
+
+func scimPredicate(attr string, col string, op string, value interface{}, isString bool) (*FilterExpression, error) {
+	if value == nil {
+		switch op {
+		case "eq":
+			return &FilterExpression{Column: col, Op: filterPresent, Value: false}, nil
+		case "ne":
+			return &FilterExpression{Column: col, Op: filterPresent, Value: true}, nil
+		}
+		return nil, ErrSCIMNullNotComparable(op, attr)
+	}
+	if !scimLikeOps[op] {
+		return &FilterExpression{Column: col, Op: scimCompareOps[op], Value: value}, nil
+	}
+	if !isString {
+		return nil, ErrSCIMValueMustBeString(op, attr)
+	}
+	pattern := EscapeLikePattern(value.(string))
+	switch op {
+	case "co":
+		pattern = "%" + pattern + "%"
+	case "sw":
+		pattern = pattern + "%"
+	case "ew":
+		pattern = "%" + pattern
+	}
+	return &FilterExpression{Column: col, Op: filterLike, Value: pattern}, nil
+}
@@ -0,0 +1,169 @@
+package rql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type scimTestUser struct {
+	ID       string `json:"id" db:"id"`
+	Email    string `json:"email" db:"email"`
+	WorkMail string `json:"work_mail" db:"work_mail"`
+	Active   bool   `json:"active" db:"active"`
+	Age      int    `json:"age" db:"age"`
+}
+
+func scimTestMapping() *SCIMMapping {
+	return &SCIMMapping{
+		Schema: GetSchemaFromTaggedEntity(scimTestUser{}, "db"),
+		Attributes: map[string]string{
+			"userName":     "id",
+			"emails.value": "email",
+			"emails.work":  "work_mail",
+		},
+	}
+}
+
+func scimJSON(t *testing.T, f *FilterExpression) string {
+	t.Helper()
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSCIMParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   *FilterExpression
+	}{
+		{
+			name:   "single predicate is wrapped in a group",
+			filter: `userName eq "bob"`,
+			want:   groupOf(ANDOperator, leaf("id", filterEq, "bob")),
+		},
+		{
+			name:   "and binds tighter than or",
+			filter: `userName eq "a" or userName eq "b" and active eq true`,
+			want:   groupOf(OROperator, leaf("id", filterEq, "a"), groupOf(ANDOperator, leaf("id", filterEq, "b"), leaf("active", filterEq, true))),
+		},
+		{
+			name:   "parentheses override the precedence",
+			filter: `(userName eq "a" or userName eq "b") and active eq true`,
+			want:   groupOf(ANDOperator, groupOf(OROperator, leaf("id", filterEq, "a"), leaf("id", filterEq, "b")), leaf("active", filterEq, true)),
+		},
+		{
+			name:   "chained operations are flattened",
+			filter: `age gt 1 and age lt 9 and active eq false`,
+			want:   groupOf(ANDOperator, leaf("age", filterGt, float64(1)), leaf("age", filterLt, float64(9)), leaf("active", filterEq, false)),
+		},
+		{
+			name:   "not is pushed down",
+			filter: `not (userName eq "a" and age ge 18)`,
+			want:   groupOf(OROperator, leaf("id", filterNe, "a"), leaf("age", filterLt, float64(18))),
+		},
+		{
+			name:   "keywords and operators are case insensitive",
+			filter: `userName EQ "a" AND active Eq TRUE`,
+			want:   groupOf(ANDOperator, leaf("id", filterEq, "a"), leaf("active", filterEq, true)),
+		},
+		{
+			name:   "value path",
+			filter: `emails[value co "@corp.com" and work pr]`,
+			want:   groupOf(ANDOperator, leaf("email", filterLike, "%@corp.com%"), leaf("work_mail", filterPresent, true)),
+		},
+		{
+			name:   "schema urn is stripped",
+			filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName sw "b"`,
+			want:   groupOf(ANDOperator, leaf("id", filterLike, "b%")),
+		},
+		{
+			name:   "like wildcards are escaped",
+			filter: `userName ew "50%_off\\"`,
+			want:   groupOf(ANDOperator, leaf("id", filterLike, `%50\%\_off\\`)),
+		},
+		{
+			name:   "quotes in strings",
+			filter: `userName eq "say \"hi\""`,
+			want:   groupOf(ANDOperator, leaf("id", filterEq, `say "hi"`)),
+		},
+		{
+			name:   "eq null is not present",
+			filter: `email eq null`,
+			want:   groupOf(ANDOperator, leaf("email", filterPresent, false)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scimTestMapping().Parse(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scimJSON(t, got) != scimJSON(t, tt.want) {
+				t.Fatalf("got  %s\nwant %s", scimJSON(t, got), scimJSON(t, tt.want))
+			}
+		})
+	}
+}
+
+func TestSCIMParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   error
+	}{
+		{name: "empty", filter: `  `, want: ErrSCIMEmptyFilter(`  `)},
+		{name: "unknown attribute", filter: `nope eq 1`, want: ErrSCIMUnknownAttribute("nope")},
+		{name: "unknown operator", filter: `userName xx "a"`, want: ErrSCIMInvalidFilter(9, "unknown operator `xx`")},
+		{name: "missing value", filter: `userName eq`, want: ErrSCIMInvalidFilter(11, "expected a value")},
+		{name: "unterminated string", filter: `userName eq "abc`, want: ErrSCIMInvalidFilter(12, "unterminated string")},
+		{name: "unclosed parenthesis", filter: `(userName eq "a"`, want: ErrSCIMInvalidFilter(16, "expected `)`")},
+		{name: "trailing token", filter: `userName eq "a" )`, want: ErrSCIMInvalidFilter(16, "unexpected `)`")},
+		{name: "not without parentheses", filter: `not userName eq "a"`, want: ErrSCIMInvalidFilter(4, "expected `(`")},
+		{name: "nested value path", filter: `emails[value[type eq "a"]]`, want: ErrSCIMNestedValuePath("value")},
+		{name: "like on a number", filter: `age co 1`, want: ErrSCIMValueMustBeString("co", "age")},
+		{name: "gt null", filter: `age gt null`, want: ErrSCIMNullNotComparable("gt", "age")},
+		{name: "bad sort order", filter: "", want: ErrSCIMInvalidSortOrder("up")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.filter == "" {
+				_, err = scimTestMapping().Sort("userName", "up")
+			} else {
+				_, err = scimTestMapping().Parse(tt.filter)
+			}
+			if err == nil || err.Error() != tt.want.Error() {
+				t.Fatalf("got %v , want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSCIMParseWithTypesenseValidator(t *testing.T) {
+	mapping := scimTestMapping()
+	mapping.Validator = &FilterParserTypeSense{}
+
+	for _, filter := range []string{`userName co "a"`, `userName sw "a"`, `userName ew "a"`, `userName pr`, `email ne null`} {
+		_, err := mapping.Parse(filter)
+		if err == nil {
+			t.Fatalf("%s : expected an error", filter)
+		}
+		var unwrapped interface{ Unwrap() error }
+		if !errors.As(err, &unwrapped) {
+			t.Fatalf("%s : error %v does not wrap the validator error", filter, err)
+		}
+	}
+	_, err := mapping.Parse(`userName co "a"`)
+	want := ErrSCIMUnsupported("co", "userName", 9, TsErrUnsupportedBaseOperation(filterLike))
+	if err.Error() != want.Error() {
+		t.Fatalf("got %v , want %v", err, want)
+	}
+	_, err = mapping.Parse(`userName eq "a" and age gt 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
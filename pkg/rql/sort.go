@@ -2,36 +2,105 @@ package rql
 
 import (
 	"errors"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/baderkha/library/pkg/err"
 )
 
 var (
-	ErrBadSortExpression               = errors.New("RQL : SortExpression Malformed must be `col::<ASC|DESC>` ")
+	ErrBadSortExpression               = errors.New("RQL : SortExpression Malformed must be `col::<ASC|DESC>` , `col(lat,lng)::<ASC|DESC>` for geo distance or `relevance(col,term)::<ASC|DESC>` for full text relevance")
 	ErrBadSortExpressionValue          = errors.New("RQL : SortExpression Malformed must be either DESC|ASC for the value")
 	ErrBadSortExpressionNotSortableCol = errors.New("RQL : SortExpression Malformed col not found ")
+	ErrBadSortExpressionGeoPoint       = errors.New("RQL : SortExpression Malformed geo point must be `col(lat,lng)` with a valid latitude and longitude")
+	ErrBadSortExpressionRelevance      = errors.New("RQL : SortExpression Malformed relevance must be `relevance(col,term)` with a non empty term")
 	ErrSortColumnDoesntExist           = err.Compose("SQL : SortExpression Column `%s` Does Not Exist")
 
 	DESC = "DESC"
 	ASC  = "ASC"
+
+	geoSortKeyRegex = regexp.MustCompile(`^([^()\s]+)\(\s*([-+0-9.eE]+)\s*,\s*([-+0-9.eE]+)\s*\)$`)
+)
+
+const (
+	relevanceSortPrefix = "relevance("
 )
 
-// SortExpression : sort expression value
+// sortClause : 1 column to sort by , if geoPoint is set the sort is by distance from that point
+// if relevanceTerm is set the sort is by the full text relevance score of the column for that term
+type sortClause struct {
+	column        string
+	direction     string
+	geoPoint      *GeoPoint
+	relevanceTerm *string
+}
+
+// SortExpression : sort expression value , clauses are kept in the order they were given
 type SortExpression struct {
-	sortMap map[string]string
+	clauses []sortClause
+}
+
+// splitSortExpression : split by commas except the ones inside of parentheses ie `loc(1,2)::ASC,name::DESC`
+func splitSortExpression(sortStr string) []string {
+	var (
+		items []string
+		depth int
+		start int
+	)
+	for i, ch := range sortStr {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, sortStr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, sortStr[start:])
+}
+
+// parseSortKey : parses either `col` , the geo distance form `col(lat,lng)` or the relevance form `relevance(col,term)`
+func parseSortKey(key string) (sortClause, error) {
+	if strings.HasPrefix(key, relevanceSortPrefix) && strings.HasSuffix(key, ")") {
+		colTerm := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(key, relevanceSortPrefix), ")"), ",", 2)
+		if len(colTerm) != 2 || strings.TrimSpace(colTerm[0]) == "" || strings.TrimSpace(colTerm[1]) == "" {
+			return sortClause{}, ErrBadSortExpressionRelevance
+		}
+		term := strings.TrimSpace(colTerm[1])
+		return sortClause{column: strings.TrimSpace(colTerm[0]), relevanceTerm: &term}, nil
+	}
+	if !strings.Contains(key, "(") {
+		return sortClause{column: key}, nil
+	}
+	match := geoSortKeyRegex.FindStringSubmatch(key)
+	if match == nil {
+		return sortClause{}, ErrBadSortExpressionGeoPoint
+	}
+	lat, errLat := strconv.ParseFloat(match[2], 64)
+	lng, errLng := strconv.ParseFloat(match[3], 64)
+	point := GeoPoint{Lat: lat, Lng: lng}
+	if errLat != nil || errLng != nil || point.validate() != nil {
+		return sortClause{}, ErrBadSortExpressionGeoPoint
+	}
+	return sortClause{column: match[1], geoPoint: &point}, nil
 }
 
 // SortExpressionFromUserInput : sort expression from user input
+//
+// Example :
+//			rql.SortExpressionFromUserInput("created_at::DESC,location(48.85,2.34)::ASC,relevance(title,red shoes)::DESC")
 func SortExpressionFromUserInput(sortStr string) (*SortExpression, error) {
 
 	if sortStr == "" {
-		return &SortExpression{
-			sortMap: make(map[string]string, 0),
-		}, nil
+		return &SortExpression{}, nil
 	}
-	exprAr := strings.Split(sortStr, ",")
-	sortMap := make(map[string]string, len(exprAr))
+	exprAr := splitSortExpression(sortStr)
+	clauses := make([]sortClause, 0, len(exprAr))
 
 	for _, item := range exprAr {
 		kv := strings.Split(item, "::")
@@ -41,10 +110,29 @@ func SortExpressionFromUserInput(sortStr string) (*SortExpression, error) {
 		if kv[1] != DESC && kv[1] != ASC {
 			return nil, ErrBadSortExpressionValue
 		}
-		sortMap[kv[0]] = kv[1]
+		clause, err := parseSortKey(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		clause.direction = kv[1]
+		clauses = append(clauses, clause)
 	}
 	return &SortExpression{
-		sortMap: sortMap,
+		clauses: clauses,
 	}, nil
 
 }
+
+// validateSortClause : common checks for all the sort parsers
+func validateSortClause(clause sortClause, schema *Schema) error {
+	if !schema.DoesColExist(clause.column) {
+		return ErrSortColumnDoesntExist(clause.column)
+	}
+	if clause.direction != DESC && clause.direction != ASC {
+		return ErrBadSortExpressionValue
+	}
+	if clause.geoPoint != nil && !schema.IsGeoColumn(clause.column) {
+		return ErrGeoColumnNotGeo(clause.column)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package rql
+
+// SortKey : a column waiting for its direction , see SortBy
+type SortKey struct {
+	parent *SortExpression
+	clause sortClause
+}
+
+// SortBy : start a sort expression
+//
+// Example :
+//			s := rql.SortBy("created_at").Desc().ThenBy("name").Asc()
+func SortBy(col string) *SortKey {
+	return (&SortExpression{}).ThenBy(col)
+}
+
+// SortByDistance : start a sort expression by the distance between a geo column and a point
+func SortByDistance(col string, point GeoPoint) *SortKey {
+	return (&SortExpression{}).ThenByDistance(col, point)
+}
+
+// SortByRelevance : start a sort expression by the full text relevance of a column for a search term
+func SortByRelevance(col string, term string) *SortKey {
+	return (&SortExpression{}).ThenByRelevance(col, term)
+}
+
+// ThenBy : add another column to sort by , the expression itself is not modified (Asc / Desc return a new one)
+func (s *SortExpression) ThenBy(col string) *SortKey {
+	return &SortKey{parent: s, clause: sortClause{column: col}}
+}
+
+// ThenByDistance : add a geo distance from a point to sort by
+func (s *SortExpression) ThenByDistance(col string, point GeoPoint) *SortKey {
+	return &SortKey{parent: s, clause: sortClause{column: col, geoPoint: &point}}
+}
+
+// ThenByRelevance : add the full text relevance of a column for a search term to sort by
+func (s *SortExpression) ThenByRelevance(col string, term string) *SortKey {
+	return &SortKey{parent: s, clause: sortClause{column: col, relevanceTerm: &term}}
+}
+
+// Asc : ascending order
+func (k *SortKey) Asc() *SortExpression {
+	return k.direction(ASC)
+}
+
+// Desc : descending order
+func (k *SortKey) Desc() *SortExpression {
+	return k.direction(DESC)
+}
+
+// direction : a new expression with the clause appended , the parent is left untouched so a builder can be branched
+func (k *SortKey) direction(dir string) *SortExpression {
+	clause := k.clause
+	clause.direction = dir
+	clauses := make([]sortClause, 0, len(k.parent.clauses)+1)
+	clauses = append(clauses, k.parent.clauses...)
+	return &SortExpression{clauses: append(clauses, clause)}
+}
+
+// Validate : make sure every column exists in the schema (and distance sorts target geo columns)
+func (s *SortExpression) Validate(schema *Schema) error {
+	for _, clause := range s.clauses {
+		err := validateSortClause(clause, schema)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
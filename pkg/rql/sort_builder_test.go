@@ -0,0 +1,19 @@
+package rql
+
+import "testing"
+
+func TestSortBuilderDoesNotModifyParent(t *testing.T) {
+	base := SortBy("created_at").Desc()
+	byName := base.ThenBy("name").Asc()
+	byEmail := base.ThenBy("email").Desc()
+
+	if len(base.clauses) != 1 {
+		t.Fatalf("base has %d clauses , want 1", len(base.clauses))
+	}
+	if len(byName.clauses) != 2 || byName.clauses[1].column != "name" || byName.clauses[1].direction != ASC {
+		t.Fatalf("byName = %+v", byName.clauses)
+	}
+	if len(byEmail.clauses) != 2 || byEmail.clauses[1].column != "email" || byEmail.clauses[1].direction != DESC {
+		t.Fatalf("byEmail = %+v", byEmail.clauses)
+	}
+}
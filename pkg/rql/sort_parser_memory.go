@@ -0,0 +1,69 @@
+package rql
+
+import "github.com/baderkha/library/pkg/err"
+
+var (
+	MemErrSortNotSupported = err.Compose("RQL : Memory : SortParser : Column `%s` cannot be sorted by distance / relevance in memory")
+)
+
+// MemoryLess : compiled sort expression , true if record a sorts before record b
+type MemoryLess func(a MemoryRecord, b MemoryRecord) bool
+
+var _ ISortParser[MemoryLess] = &SortParserMemory{}
+
+// SortParserMemory : compiles sort expressions into a less func for sorting records in memory ,
+// nulls sort after every value in ascending order (before in descending order)
+//
+// Example :
+//			less, err := rql.SortParserMemory{}.Parse(s, schema)
+//			sort.SliceStable(records, func(i, j int) bool { return (*less)(records[i], records[j]) })
+type SortParserMemory struct{}
+
+// Parse : compiles the expression , a nil expression keeps the records in their order
+func (s SortParserMemory) Parse(expression *SortExpression, schema *Schema) (*MemoryLess, error) {
+	var clauses []sortClause
+	if expression != nil {
+		clauses = expression.clauses
+	}
+	for _, clause := range clauses {
+		err := validateSortClause(clause, schema)
+		if err != nil {
+			return nil, err
+		}
+		if clause.geoPoint != nil || clause.relevanceTerm != nil {
+			return nil, MemErrSortNotSupported(clause.column)
+		}
+	}
+	less := MemoryLess(func(a MemoryRecord, b MemoryRecord) bool {
+		for _, clause := range clauses {
+			col := schema.GetColumnInternalName(clause.column)
+			res := compareMemorySort(a[col], b[col])
+			if res == 0 {
+				continue
+			}
+			if clause.direction == DESC {
+				return res > 0
+			}
+			return res < 0
+		}
+		return false
+	})
+	return &less, nil
+}
+
+// compareMemorySort : compareMemoryValues with nulls after every value , values that cannot be compared are equal
+func compareMemorySort(a interface{}, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	res, ok := compareMemoryValues(a, b)
+	if !ok {
+		return 0
+	}
+	return res
+}
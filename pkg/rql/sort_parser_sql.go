@@ -13,27 +13,72 @@ type SQLSortOutput struct {
 	RawQuery string
 	// order by arrays ["'DATE DESC' , 'COL ASC'"]
 	Clauses []string
+	// Args : bound arguments for the placeholders in RawQuery (ie full text relevance terms)
+	Args []interface{}
 }
 
 var _ ISortParser[SQLSortOutput] = &SortParserSQL{}
 
 // SortParserSQL : sort parser sql
 type SortParserSQL struct {
+	// Dialect : sql dialect , only required for geo distance / relevance sorting (see db.DialectMYSQL , db.DialectPostgres)
+	Dialect string
 }
 
 // Parse : parse an expression and turn it into sql expression
 func (s SortParserSQL) Parse(expression *SortExpression, schema *Schema) (out *SQLSortOutput, err error) {
 	out = &SQLSortOutput{}
-	for k, v := range expression.sortMap {
-		if !schema.DoesColExist(k) {
-			return nil, ErrSortColumnDoesntExist(k)
+	for _, clause := range expression.clauses {
+		err := validateSortClause(clause, schema)
+		if err != nil {
+			return nil, err
 		}
-		if v != DESC && v != ASC {
-			return nil, ErrBadSortExpressionValue
+		col := schema.GetColumnInternalName(clause.column)
+		if clause.geoPoint != nil {
+			col, err = geoDistanceSQL(s.Dialect, col, *clause.geoPoint)
+			if err != nil {
+				return nil, err
+			}
 		}
-		out.Clauses = append(out.Clauses, fmt.Sprintf("%s %s", k, v))
+		if clause.relevanceTerm != nil {
+			var args []interface{}
+			col, args, err = fullTextRankSQL(s.Dialect, schema, clause.column, *clause.relevanceTerm)
+			if err != nil {
+				return nil, err
+			}
+			out.Args = append(out.Args, args...)
+		}
+		out.Clauses = append(out.Clauses, fmt.Sprintf("%s %s", col, clause.direction))
 	}
 	out.RawQuery = conditional.Ternary(len(out.Clauses) > 0, fmt.Sprintf("ORDER BY %s", strings.Join(out.Clauses, ",")), "")
 
 	return out, nil
 }
+
+// ParseBound : sort expression to an order by list whose columns are bound as arguments so the dialect quotes them (see gormrql) ,
+// bind returns the argument a ? placeholder binds for an internal column name
+func (s SortParserSQL) ParseBound(expression *SortExpression, schema *Schema, bind func(col string) interface{}) (terms []string, args []interface{}, err error) {
+	for _, c := range expression.clauses {
+		err := validateSortClause(c, schema)
+		if err != nil {
+			return nil, nil, err
+		}
+		var (
+			col      = bind(schema.GetColumnInternalName(c.column))
+			term     = "?"
+			termArgs = []interface{}{col}
+		)
+		switch {
+		case c.geoPoint != nil:
+			term, err = geoDistanceSQL(s.Dialect, "?", *c.geoPoint)
+		case c.relevanceTerm != nil:
+			term, termArgs, err = fullTextRankSQL(s.Dialect, schema, c.column, *c.relevanceTerm, col)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		terms = append(terms, term+" "+c.direction)
+		args = append(args, termArgs...)
+	}
+	return terms, args, nil
+}
@@ -5,6 +5,11 @@ import (
 	"strings"
 
 	"github.com/baderkha/library/pkg/ptr"
+	"github.com/baderkha/typesense"
+)
+
+const (
+	typesenseTextMatchSort = "_text_match"
 )
 
 var _ ITypeSenseSortParser = &SortParserTypesense{}
@@ -12,17 +17,26 @@ var _ ITypeSenseSortParser = &SortParserTypesense{}
 type SortParserTypesense struct {
 }
 
-// Parse : parse an expression and turn it into sql expression
+// Parse : parse an expression and turn it into a typesense sort_by parameter
 func (s SortParserTypesense) Parse(expression *SortExpression, schema *Schema) (out *string, err error) {
 	var args []string
-	for k, v := range expression.sortMap {
-		if !schema.DoesColExist(k) {
-			return nil, ErrSortColumnDoesntExist(k)
+	for _, clause := range expression.clauses {
+		err := validateSortClause(clause, schema)
+		if err != nil {
+			return nil, err
+		}
+		col := clause.column
+		if clause.relevanceTerm != nil {
+			// typesense ranks by the search term of the query (see the fuzzy / search operators)
+			if !schema.CheckTagExists(clause.column, typesense.TagIndex) {
+				return nil, TsErrorColumnNotFuzzySearchable(clause.column)
+			}
+			col = typesenseTextMatchSort
 		}
-		if v != DESC && v != ASC {
-			return nil, ErrBadSortExpressionValue
+		if clause.geoPoint != nil {
+			col = fmt.Sprintf("%s(%s, %s)", col, formatFloat(clause.geoPoint.Lat), formatFloat(clause.geoPoint.Lng))
 		}
-		args = append(args, fmt.Sprintf("%s:%s", k, strings.ToLower(v)))
+		args = append(args, fmt.Sprintf("%s:%s", col, strings.ToLower(clause.direction)))
 	}
 	return ptr.Get(strings.Join(args, ",")), nil
 }
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"encoding/json"
+)
+
+var _ Model = &AuditEvent{}
+
+const (
+	AuditActionCreate     = "create"
+	AuditActionUpdate     = "update"
+	AuditActionDelete     = "delete"
+	AuditActionSoftDelete = "soft_delete"
+	AuditActionRestore    = "restore"
+)
+
+// AuditEvent : a change an actor made to a record (see repository.AuditedCrud) , CreatedAt is the time of the change
+type AuditEvent struct {
+	Base
+	// ActorID : account that made the change , empty if the change was not made on behalf of an account
+	ActorID string `json:"actor_id" db:"actor_id" gorm:"type:VARCHAR(255);index"`
+	// EntityName : table name of the changed record
+	EntityName string `json:"entity_name" db:"entity_name" gorm:"type:VARCHAR(255);index:idx_audit_record"`
+	RecordID   string `json:"record_id" db:"record_id" gorm:"type:VARCHAR(100);index:idx_audit_record"`
+	Action     string `json:"action" db:"action" gorm:"type:VARCHAR(20)"`
+	// Changes : json encoded []AuditChange
+	Changes string `json:"changes" db:"changes" gorm:"type:TEXT"`
+}
+
+// AuditChange : before / after json values of a field , before is empty for a create and after for a delete
+type AuditChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+func (a AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// GetIDKey : return the id column name ie your "db" tag
+func (a AuditEvent) GetIDKey() string {
+	return "id"
+}
+
+// GetAccountID : the actor owns the event
+func (a AuditEvent) GetAccountID() string {
+	return a.ActorID
+}
+
+// GetChanges : the decoded field changes
+func (a *AuditEvent) GetChanges() ([]AuditChange, error) {
+	var changes []AuditChange
+	if a.Changes == "" {
+		return changes, nil
+	}
+	err := json.Unmarshal([]byte(a.Changes), &changes)
+	return changes, err
+}
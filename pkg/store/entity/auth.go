@@ -6,15 +6,20 @@ import (
 
 var _ Model = &Account{}
 
+const (
+	// RoleAdmin : role that can see the restricted account columns (see rql.Schema.ForRole)
+	RoleAdmin = "admin"
+)
+
 type Account struct {
 	AccountPublic
-	Password string ` json:"password" db:"password" gorm:"type:varchar(255)"`
+	Password string ` json:"password" db:"password" gorm:"type:varchar(255)" audit:"redact"`
 }
 
 type AccountPublic struct {
 	Base
-	Email      string `json:"email" tsense_default_sort:"1" tsense_sort:"1" db:"email" gorm:"type:varchar(255);index;unique"`
-	IsVerified bool   `json:"is_verified" db:"is_verified"`
+	Email      string `json:"email" tsense_default_sort:"1" tsense_sort:"1" db:"email" gorm:"type:varchar(255);index;unique" rql_roles:"admin" kv_index:"1"`
+	IsVerified bool   `json:"is_verified" db:"is_verified" rql_roles:"admin"`
 	IsSSO      bool   `json:"is_sso" db:"is_sso"` // is an sso account
 	SSOType    string `json:"sso_type" db:"sso_type"`
 }
@@ -30,6 +30,18 @@ func (b Base) GetID() string {
 	return b.ID
 }
 
+// GetIDKey : return the id column name ie your "db" tag
 func (b Base) GetIDKey() string {
-	return b.ID
+	return "id"
+}
+
+// SetDeleted : soft deletes / restores the record , the update time is the deletion time of a soft deleted record
+func (b *Base) SetDeleted(isDeleted bool) {
+	b.IsDeleted = isDeleted
+	b.UpdatedAt = types.Timestamp(time.Now())
+}
+
+// GetIsDeleted : is the record soft deleted
+func (b Base) GetIsDeleted() bool {
+	return b.IsDeleted
 }
@@ -3,7 +3,7 @@ package entity
 // BaseOwned : use this for entities that are owned and need to have an account to own them
 type BaseOwned struct {
 	Base
-	AccountID string `json:"account_id" db:"account_id" gorm:"type:VARCHAR(255);index"`
+	AccountID string `json:"account_id" db:"account_id" gorm:"type:VARCHAR(255);index" kv_index:"1"`
 }
 
 func (b BaseOwned) GetAccountID() string {
@@ -0,0 +1,33 @@
+package entity
+
+// BaseVersioned : use this instead of Base for entities edited concurrently , the version is incremented by the repositories
+// on every update and an update of a stale version fails (optimistic concurrency)
+type BaseVersioned struct {
+	Base
+	Version int64 `json:"version" db:"version" gorm:"not null;default:1"`
+}
+
+func (b *BaseVersioned) New() {
+	b.Base.New()
+	b.Version = 1
+}
+
+// GetVersion : version of the record
+func (b BaseVersioned) GetVersion() int64 {
+	return b.Version
+}
+
+// SetVersion : set the version of the record
+func (b *BaseVersioned) SetVersion(version int64) {
+	b.Version = version
+}
+
+// BaseOwnedVersioned : BaseOwned with a version (see BaseVersioned)
+type BaseOwnedVersioned struct {
+	BaseVersioned
+	AccountID string `json:"account_id" db:"account_id" gorm:"type:VARCHAR(255);index" kv_index:"1"`
+}
+
+func (b BaseOwnedVersioned) GetAccountID() string {
+	return b.AccountID
+}
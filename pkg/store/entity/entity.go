@@ -11,3 +11,19 @@ type Model interface {
 	// TableName : return back the table name
 	TableName() string // table name or e
 }
+
+// SoftDeletable : a model that can be soft deleted (embed Base)
+type SoftDeletable interface {
+	// SetDeleted : mark the record as deleted / restored
+	SetDeleted(isDeleted bool)
+	// GetIsDeleted : is the record soft deleted
+	GetIsDeleted() bool
+}
+
+// Versioned : a model with a version checked on updates (embed BaseVersioned)
+type Versioned interface {
+	// GetVersion : version of the record
+	GetVersion() int64
+	// SetVersion : set the version of the record
+	SetVersion(version int64)
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"github.com/baderkha/typesense/types"
+)
+
+var _ Model = &OutboxEvent{}
+
+const (
+	// OutboxActionUpsert : the record was created / changed , the payload is its new state
+	OutboxActionUpsert = "upsert"
+	// OutboxActionDelete : the record was perma deleted
+	OutboxActionDelete = "delete"
+
+	// OutboxStatusPending : the event is waiting to be relayed (or retried)
+	OutboxStatusPending = "pending"
+	// OutboxStatusFailed : the event ran out of attempts , it is not relayed until retried (see repository.OutboxRelay.RetryFailed)
+	OutboxStatusFailed = "failed"
+)
+
+// OutboxEvent : a change of a record written in the transaction of the change (see repository.OutboxCrud) ,
+// relayed to the search index then deleted (see repository.OutboxRelay)
+type OutboxEvent struct {
+	Base
+	// EntityName : table name of the changed record
+	EntityName string `json:"entity_name" db:"entity_name" gorm:"type:VARCHAR(255);index:idx_outbox_pending"`
+	RecordID   string `json:"record_id" db:"record_id" gorm:"type:VARCHAR(100);index"`
+	Action     string `json:"action" db:"action" gorm:"type:VARCHAR(20)"`
+	// Payload : json encoded record for an upsert , empty for a delete
+	Payload string `json:"payload" db:"payload" gorm:"type:TEXT"`
+	// Sequence : order of the events , auto incremented by the database on insert . the event is written after the change
+	// in its transaction so the lock of the changed row orders the events of a record across processes
+	Sequence int64  `json:"sequence" db:"sequence" auto_increment:"1" gorm:"autoIncrement;uniqueIndex:idx_outbox_sequence;index:idx_outbox_pending"`
+	Status   string `json:"status" db:"status" gorm:"type:VARCHAR(20);index:idx_outbox_pending"`
+	// Attempts : failed relay attempts
+	Attempts  int    `json:"attempts" db:"attempts"`
+	LastError string `json:"last_error" db:"last_error" gorm:"type:TEXT"`
+	// NextAttemptAt : the event is not relayed before this time (retry backoff)
+	NextAttemptAt types.Timestamp `json:"next_attempt_at" db:"next_attempt_at"`
+}
+
+func (o OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// GetIDKey : return the id column name ie your "db" tag
+func (o OutboxEvent) GetIDKey() string {
+	return "id"
+}
+
+// GetAccountID : events are not owned by an account
+func (o OutboxEvent) GetAccountID() string {
+	return ""
+}
@@ -0,0 +1,41 @@
+package entity
+
+var _ Model = &SavedView{}
+var _ Model = &SavedViewShare{}
+
+// SavedView : a named filter / sort / page size an account saved for an entity (see repository.SavedViewRepo)
+type SavedView struct {
+	BaseOwned
+	Name string `json:"name" db:"name" gorm:"type:varchar(255)"`
+	// EntityName : the entity the view targets , must be one of the schemas registered on the repository
+	EntityName string `json:"entity_name" db:"entity_name" gorm:"type:varchar(255);index"`
+	// Filter : json encoded rql.FilterExpression
+	Filter string `json:"filter" db:"filter" gorm:"type:TEXT"`
+	// Sort : rql sort expression ie `created_at::DESC,name::ASC`
+	Sort     string `json:"sort" db:"sort" gorm:"type:varchar(1024)"`
+	PageSize int    `json:"page_size" db:"page_size"`
+}
+
+func (s SavedView) TableName() string {
+	return "saved_views"
+}
+
+// GetIDKey : return the id column name ie your "db" tag
+func (s SavedView) GetIDKey() string {
+	return "id"
+}
+
+// SavedViewShare : grants an account (AccountID) read access to another account's saved view
+type SavedViewShare struct {
+	BaseOwned
+	ViewID string `json:"view_id" db:"view_id" gorm:"type:VARCHAR(100);index"`
+}
+
+func (s SavedViewShare) TableName() string {
+	return "saved_view_shares"
+}
+
+// GetIDKey : return the id column name ie your "db" tag
+func (s SavedViewShare) GetIDKey() string {
+	return "id"
+}
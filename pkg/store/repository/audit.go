@@ -0,0 +1,549 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/tkrajina/go-reflector/reflector"
+)
+
+const (
+	// AuditTag : struct tag controlling how a field is recorded in the audit trail ,
+	// `audit:"-"` skips the field , `audit:"redact"` records that it changed without its values
+	AuditTag = "audit"
+	// AuditSkip : AuditTag value , the field is not recorded
+	AuditSkip = "-"
+	// AuditRedact : AuditTag value , the values of the field are redacted
+	AuditRedact = "redact"
+)
+
+var (
+	auditRedacted = json.RawMessage(`"*** REDACTED ***"`)
+)
+
+var _ ICrud[entity.SavedView] = &AuditedCrud[entity.SavedView]{}
+var _ ISoftDelete[entity.SavedView] = &AuditedCrud[entity.SavedView]{}
+var _ IWhere[entity.SavedView] = &AuditedCrud[entity.SavedView]{}
+
+type actorCtxKey struct{}
+
+// WithActor : context carrying the account making the changes , recorded as the actor by AuditedCrud
+//
+// Example :
+//			repo.WithContext(repository.WithActor(ctx.Request.Context(), accountID)).Update(mdl)
+func WithActor(ctx context.Context, actorID string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actorID)
+}
+
+// ActorFromContext : the account making the changes , empty if not set (see WithActor)
+func ActorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actorID, _ := ctx.Value(actorCtxKey{}).(string)
+	return actorID
+}
+
+// AuditedCrud : decorator recording every create / update / delete of the repository as an entity.AuditEvent
+// with the actor of the context (see WithActor) and the field level changes , reads are not recorded
+//
+// writes run in a transaction started from Tx , or in the transaction of the caller for a transactional view (see WithTransaction) ,
+// so a change is never committed without its event . use the same persistence layer for both repositories
+//
+// Example :
+//			repo := &repository.AuditedCrud[entity.SavedView]{
+//				Repo:  &gormrepo.CrudGorm[entity.SavedView]{DB: db},
+//				Audit: &gormrepo.CrudGorm[entity.AuditEvent]{DB: db},
+//				Tx:    &gormrepo.GormTransaction{DB: db},
+//			}
+//			err := repo.WithContext(repository.WithActor(ctx, accountID)).Update(view)
+//			history, err := repo.History(view.ID, nil, nil, nil)
+type AuditedCrud[t entity.Model] struct {
+	// Repo : the audited repository
+	Repo ICrud[t]
+	// Audit : where the events are recorded
+	Audit ICrud[entity.AuditEvent]
+	// Tx : transaction the writes are wrapped in (ie &gormrepo.GormTransaction{DB: db}) ,
+	// if nil the change and its event are written one after the other and are not atomic
+	Tx ITransaction
+
+	// ctx : context of the calls , the actor is read from it
+	ctx context.Context
+	// inTx : the repository is a transactional view , writes run in the transaction of the caller
+	inTx bool
+}
+
+func (a *AuditedCrud[t]) Model() t {
+	var m t
+	return m
+}
+
+func (a *AuditedCrud[t]) IsForAccountID(id string, accountID string) bool {
+	return a.Repo.IsForAccountID(id, accountID)
+}
+
+func (a *AuditedCrud[t]) DoesIDExist(id string) bool {
+	return a.Repo.DoesIDExist(id)
+}
+
+// GetById : get 1 record by id if not found should return err
+func (a *AuditedCrud[t]) GetById(id string) (*t, error) {
+	return a.Repo.GetById(id)
+}
+
+// GetAll : get all the records (db dump)
+func (a *AuditedCrud[t]) GetAll() ([]*t, error) {
+	return a.Repo.GetAll()
+}
+
+// GetWithFilterExpression : filter + sort a result using the rql package
+func (a *AuditedCrud[t]) GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error) {
+	return a.Repo.GetWithFilterExpression(f, s, baseExpression...)
+}
+
+// GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package
+func (a *AuditedCrud[t]) GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *Paginated[t], err error) {
+	return a.Repo.GetWithFilterExpressionPaginated(f, p, s, baseExpression...)
+}
+
+// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns over all the matching records
+func (a *AuditedCrud[t]) GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *Faceted[t], err error) {
+	return a.Repo.GetWithFilterExpressionFaceted(f, p, s, facets, baseExpression...)
+}
+
+// History : audit events of a record , newest first unless sorted , the filter can narrow them down (ie by actor_id , action , created_at)
+func (a *AuditedCrud[t]) History(recordID string, f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression) (*Paginated[entity.AuditEvent], error) {
+	return AuditHistory(a.Audit, a.Model().TableName(), recordID, f, p, s)
+}
+
+// AuditHistory : audit events of a record of the entity (table name) , newest first unless sorted ,
+// the filter can narrow them down (ie by actor_id , action , created_at)
+func AuditHistory(audit IReadOnly[entity.AuditEvent], entityName string, recordID string, f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression) (*Paginated[entity.AuditEvent], error) {
+	base, err := rql.And(
+		rql.Where("entity_name").Eq(entityName),
+		rql.Where("record_id").Eq(recordID),
+	).Build()
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = rql.SortBy("created_at").Desc()
+	}
+	return audit.GetWithFilterExpressionPaginated(f, p, s, base)
+}
+
+// newEvent : audit event of an action on the record
+func (a *AuditedCrud[t]) newEvent(action string, recordID string, changes []entity.AuditChange) (*entity.AuditEvent, error) {
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+	event := &entity.AuditEvent{
+		ActorID:    ActorFromContext(a.ctx),
+		EntityName: a.Model().TableName(),
+		RecordID:   recordID,
+		Action:     action,
+		Changes:    string(encoded),
+	}
+	event.New()
+	return event, nil
+}
+
+// atomically : runs fn with views of the repositories sharing 1 transaction (the transaction of the caller for a transactional view)
+func (a *AuditedCrud[t]) atomically(fn func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error) error {
+	if a.inTx || a.Tx == nil {
+		return fn(a.Repo, a.Audit)
+	}
+	tx := a.Tx
+	if a.ctx != nil {
+		tx = tx.WithContext(a.ctx)
+	}
+	tx = tx.Begin()
+	err := fn(a.Repo.WithTransaction(tx), a.Audit.WithTransaction(tx))
+	if err != nil {
+		tx.RollBack()
+		return err
+	}
+	return tx.Commit()
+}
+
+// record : records an action on the record with the changes between the before / after state (nil for a create / delete)
+func (a *AuditedCrud[t]) record(audit ICrud[entity.AuditEvent], action string, recordID string, before *t, after *t) error {
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	if action == entity.AuditActionUpdate && len(changes) == 0 {
+		return nil
+	}
+	event, err := a.newEvent(action, recordID, changes)
+	if err != nil {
+		return err
+	}
+	return audit.Create(event)
+}
+
+// Create : create one
+func (a *AuditedCrud[t]) Create(mdl *t) error {
+	return a.atomically(func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error {
+		err := repo.Create(mdl)
+		if err != nil {
+			return err
+		}
+		return a.record(audit, entity.AuditActionCreate, (*mdl).GetID(), nil, mdl)
+	})
+}
+
+// BulkCreate : create many
+func (a *AuditedCrud[t]) BulkCreate(mdl []*t) error {
+	return a.atomically(func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error {
+		err := repo.BulkCreate(mdl)
+		if err != nil {
+			return err
+		}
+		events := make([]*entity.AuditEvent, 0, len(mdl))
+		for _, m := range mdl {
+			changes, err := auditDiff(nil, m)
+			if err != nil {
+				return err
+			}
+			event, err := a.newEvent(entity.AuditActionCreate, (*m).GetID(), changes)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		return audit.BulkCreate(events)
+	})
+}
+
+// Update : update model , only the fields that changed are recorded (nothing is recorded if no field changed) ,
+// the record is read again after the update so fields the update skipped (ie zero values of a partial model) are not recorded
+func (a *AuditedCrud[t]) Update(mdl *t) error {
+	id := (*mdl).GetID()
+	return a.atomically(func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error {
+		// the stored record is missing if it was deleted , the update then decides what happens
+		before, err := repo.GetById(id)
+		if err != nil {
+			before = nil
+		}
+		err = repo.Update(mdl)
+		if err != nil {
+			return err
+		}
+		after, err := repo.GetById(id)
+		if err != nil {
+			return err
+		}
+		return a.record(audit, entity.AuditActionUpdate, id, before, after)
+	})
+}
+
+// DeleteById : perma delete model by id , the last state of the record is recorded
+func (a *AuditedCrud[t]) DeleteById(id string) error {
+	return a.DeleteByIds([]string{id})
+}
+
+// DeleteByIds : perma delet by many ids , the last state of the records is recorded
+func (a *AuditedCrud[t]) DeleteByIds(id []string) error {
+	return a.atomically(func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error {
+		before := make([]*t, len(id))
+		for i := range id {
+			mdl, err := repo.GetById(id[i])
+			if err == nil {
+				before[i] = mdl
+			}
+		}
+		var err error
+		if len(id) == 1 {
+			err = repo.DeleteById(id[0])
+		} else {
+			err = repo.DeleteByIds(id)
+		}
+		if err != nil {
+			return err
+		}
+		for i := range id {
+			err = a.record(audit, entity.AuditActionDelete, id[i], before[i], nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// softDelete : the audited repository as a soft delete repository
+func (a *AuditedCrud[t]) softDelete() (ISoftDelete[t], error) {
+	return softDeleteOf(a.Repo)
+}
+
+// recordDeleted : records a soft delete / restore as a change of the is_deleted field
+func (a *AuditedCrud[t]) recordDeleted(audit ICrud[entity.AuditEvent], action string, ids []string, isDeleted bool) error {
+	changes := []entity.AuditChange{{
+		Field:  ColumnIsDeleted,
+		Before: json.RawMessage(strconv.FormatBool(!isDeleted)),
+		After:  json.RawMessage(strconv.FormatBool(isDeleted)),
+	}}
+	for _, id := range ids {
+		event, err := a.newEvent(action, id, changes)
+		if err != nil {
+			return err
+		}
+		err = audit.Create(event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SoftDeleteById : mark the record as deleted (the audited repository must be an ISoftDelete)
+func (a *AuditedCrud[t]) SoftDeleteById(id string) error {
+	return a.SoftDeleteByIds([]string{id})
+}
+
+// SoftDeleteByIds : mark many records as deleted (the audited repository must be an ISoftDelete)
+func (a *AuditedCrud[t]) SoftDeleteByIds(ids []string) error {
+	return a.atomically(func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error {
+		sd, err := softDeleteOf(repo)
+		if err != nil {
+			return err
+		}
+		err = sd.SoftDeleteByIds(ids)
+		if err != nil {
+			return err
+		}
+		return a.recordDeleted(audit, entity.AuditActionSoftDelete, ids, true)
+	})
+}
+
+// Restore : unmark a soft deleted record (the audited repository must be an ISoftDelete)
+func (a *AuditedCrud[t]) Restore(id string) error {
+	return a.atomically(func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error {
+		sd, err := softDeleteOf(repo)
+		if err != nil {
+			return err
+		}
+		err = sd.Restore(id)
+		if err != nil {
+			return err
+		}
+		return a.recordDeleted(audit, entity.AuditActionRestore, []string{id}, false)
+	})
+}
+
+// PurgeDeletedOlderThan : perma delete the records soft deleted more than age ago (not recorded , the soft delete already was)
+func (a *AuditedCrud[t]) PurgeDeletedOlderThan(age time.Duration) (int64, error) {
+	repo, err := a.softDelete()
+	if err != nil {
+		return 0, err
+	}
+	return repo.PurgeDeletedOlderThan(age)
+}
+
+// CountWhere : number of records matching the filter (the audited repository must be an IWhere)
+func (a *AuditedCrud[t]) CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	repo, err := WhereOf(a.Repo)
+	if err != nil {
+		return 0, err
+	}
+	return repo.CountWhere(f, baseExpression...)
+}
+
+// ExistsWhere : at least 1 record matches the filter (the audited repository must be an IWhere)
+func (a *AuditedCrud[t]) ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error) {
+	repo, err := WhereOf(a.Repo)
+	if err != nil {
+		return false, err
+	}
+	return repo.ExistsWhere(f, baseExpression...)
+}
+
+// UpdateWhere : sets the columns of the patch on the records matching the filter , the matching records are read before and after
+// the update to record their changes (the audited repository must be an IWhere)
+func (a *AuditedCrud[t]) UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	var updated int64
+	err = a.atomically(func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error {
+		where, err := WhereOf(repo)
+		if err != nil {
+			return err
+		}
+		before, err := repo.GetWithFilterExpression(f, nil, baseExpression...)
+		if err != nil {
+			return err
+		}
+		updated, err = where.UpdateWhere(f, patch, baseExpression...)
+		if err != nil {
+			return err
+		}
+		after, err := recordsByIds(repo, RecordIds(before))
+		if err != nil {
+			return err
+		}
+		afterByID := make(map[string]*t, len(after))
+		for _, rec := range after {
+			afterByID[(*rec).GetID()] = rec
+		}
+		for _, rec := range before {
+			id := (*rec).GetID()
+			err = a.record(audit, entity.AuditActionUpdate, id, rec, afterByID[id])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// DeleteWhere : perma deletes the records matching the filter , the last state of the records is recorded
+// (the audited repository must be an IWhere)
+func (a *AuditedCrud[t]) DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	err = a.atomically(func(repo ICrud[t], audit ICrud[entity.AuditEvent]) error {
+		where, err := WhereOf(repo)
+		if err != nil {
+			return err
+		}
+		before, err := repo.GetWithFilterExpression(f, nil, baseExpression...)
+		if err != nil {
+			return err
+		}
+		deleted, err = where.DeleteWhere(f, baseExpression...)
+		if err != nil {
+			return err
+		}
+		for _, rec := range before {
+			err = a.record(audit, entity.AuditActionDelete, (*rec).GetID(), rec, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted records
+func (a *AuditedCrud[t]) IncludeDeleted() ICrud[t] {
+	repo, err := a.softDelete()
+	if err != nil {
+		return a
+	}
+	cp := *a
+	cp.Repo = repo.IncludeDeleted()
+	return &cp
+}
+
+// WithTransaction : transactional pointer , the changes and their events are written in the transaction of the caller
+func (a *AuditedCrud[t]) WithTransaction(tx ITransaction) ICrud[t] {
+	cp := *a
+	cp.Repo = a.Repo.WithTransaction(tx)
+	cp.Audit = a.Audit.WithTransaction(tx)
+	cp.inTx = true
+	return &cp
+}
+
+// WithContext : view of the repository whose calls (and transactions) use the context , the actor of the events is read from it (see WithActor)
+func (a *AuditedCrud[t]) WithContext(ctx context.Context) ICrud[t] {
+	cp := *a
+	cp.Repo = a.Repo.WithContext(ctx)
+	cp.Audit = a.Audit.WithContext(ctx)
+	cp.ctx = ctx
+	return &cp
+}
+
+// auditTags : json name -> audit tag of the fields of the model
+func auditTags(mdl interface{}) map[string]string {
+	tags := make(map[string]string)
+	for _, field := range reflector.New(mdl).FieldsFlattened() {
+		name, _ := field.Tag("json")
+		name = strings.Split(name, ",")[0]
+		tag, _ := field.Tag(AuditTag)
+		if name != "" && tag != "" {
+			tags[name] = tag
+		}
+	}
+	return tags
+}
+
+// auditFields : json fields of the record , empty for nil
+func auditFields[t any](mdl *t) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if mdl == nil {
+		return fields, nil
+	}
+	b, err := json.Marshal(mdl)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &fields)
+	return fields, err
+}
+
+// auditDiff : the json fields that differ between the before / after state of a record , sorted by field
+func auditDiff[t any](before *t, after *t) ([]entity.AuditChange, error) {
+	var (
+		mdl     t
+		tags    = auditTags(mdl)
+		changes = []entity.AuditChange{}
+		names   []string
+	)
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	for name := range b {
+		names = append(names, name)
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if tags[name] == AuditSkip || bytes.Equal(b[name], a[name]) {
+			continue
+		}
+		change := entity.AuditChange{Field: name, Before: b[name], After: a[name]}
+		if tags[name] == AuditRedact {
+			change.Before = redact(change.Before)
+			change.After = redact(change.After)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// redact : the redacted value , nil stays nil (the field did not exist)
+func redact(v json.RawMessage) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	return auditRedacted
+}
@@ -0,0 +1,126 @@
+package repository_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/store/repository/boltrepo"
+	bolt "go.etcd.io/bbolt"
+)
+
+// partialUpdateCrud : repository whose Update skips the zero fields of the model like gorm Updates
+type partialUpdateCrud struct {
+	repository.ICrud[savedViewTestEntity]
+}
+
+func (p *partialUpdateCrud) Update(mdl *savedViewTestEntity) error {
+	stored, err := p.GetById(mdl.GetID())
+	if err != nil {
+		return err
+	}
+	if mdl.Name != "" {
+		stored.Name = mdl.Name
+	}
+	return p.ICrud.Update(stored)
+}
+
+func (p *partialUpdateCrud) WithTransaction(tx repository.ITransaction) repository.ICrud[savedViewTestEntity] {
+	return &partialUpdateCrud{ICrud: p.ICrud.WithTransaction(tx)}
+}
+
+// failingAudit : audit repository that cannot record events
+type failingAudit struct {
+	repository.ICrud[entity.AuditEvent]
+}
+
+var errAuditDown = errors.New("audit down")
+
+func (f *failingAudit) Create(*entity.AuditEvent) error { return errAuditDown }
+
+func (f *failingAudit) WithTransaction(tx repository.ITransaction) repository.ICrud[entity.AuditEvent] {
+	return f
+}
+
+func newAuditTestRepo(t *testing.T) *repository.AuditedCrud[savedViewTestEntity] {
+	store, err := bolt.Open(filepath.Join(t.TempDir(), "audit.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return &repository.AuditedCrud[savedViewTestEntity]{
+		Repo:  &partialUpdateCrud{ICrud: &boltrepo.CrudBolt[savedViewTestEntity]{DB: store}},
+		Audit: &boltrepo.CrudBolt[entity.AuditEvent]{DB: store},
+		Tx:    &boltrepo.BoltTransaction{DB: store},
+	}
+}
+
+func TestAuditedUpdateDiffsTheStoredRecord(t *testing.T) {
+	repo := newAuditTestRepo(t)
+	rec := &savedViewTestEntity{Name: "a"}
+	rec.ID = "rec1"
+	rec.AccountID = "acc1"
+	err := repo.Create(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partial := &savedViewTestEntity{Name: "b"}
+	partial.ID = "rec1"
+	err = repo.Update(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := repo.History("rec1", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var update *entity.AuditEvent
+	for _, event := range history.Records {
+		if event.Action == entity.AuditActionUpdate {
+			update = event
+		}
+	}
+	if update == nil {
+		t.Fatal("the update was not recorded")
+	}
+	if !strings.Contains(update.Changes, `"field":"name"`) {
+		t.Fatalf("changes %s do not record the name", update.Changes)
+	}
+	if strings.Contains(update.Changes, `"field":"account_id"`) {
+		t.Fatalf("changes %s record a field the update skipped", update.Changes)
+	}
+}
+
+func TestAuditedWritesRollBackWhenTheEventFails(t *testing.T) {
+	repo := newAuditTestRepo(t)
+	rec := &savedViewTestEntity{Name: "a"}
+	rec.ID = "rec1"
+	err := repo.Create(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Audit = &failingAudit{ICrud: repo.Audit}
+
+	err = repo.Update(&savedViewTestEntity{BaseOwned: rec.BaseOwned, Name: "b"})
+	if !errors.Is(err, errAuditDown) {
+		t.Fatalf("got %v , want %v", err, errAuditDown)
+	}
+	got, err := repo.GetById("rec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "a" {
+		t.Fatalf("name = %q , want the update rolled back", got.Name)
+	}
+	err = repo.Create(&savedViewTestEntity{BaseOwned: entity.BaseOwned{Base: entity.Base{ID: "rec2"}}})
+	if !errors.Is(err, errAuditDown) {
+		t.Fatalf("got %v , want %v", err, errAuditDown)
+	}
+	if repo.DoesIDExist("rec2") {
+		t.Fatal("the create was not rolled back")
+	}
+}
@@ -0,0 +1,50 @@
+package boltrepo
+
+import (
+	"context"
+
+	"github.com/baderkha/library/pkg/store/repository"
+	bolt "go.etcd.io/bbolt"
+)
+
+var _ repository.ITransaction = &BoltTransaction{}
+
+// BoltTransaction : bolt read / write transaction (see CrudBolt) , begin errors are returned by Commit
+// and by the calls of the repositories using the transaction
+//
+// bolt only allows 1 write transaction at a time , do not write through a repository without the transaction while it is open
+type BoltTransaction struct {
+	DB *bolt.DB
+
+	tx  *bolt.Tx
+	err error
+	ctx context.Context
+}
+
+func (b *BoltTransaction) Begin() repository.ITransaction {
+	if b.ctx != nil && b.ctx.Err() != nil {
+		return &BoltTransaction{DB: b.DB, err: b.ctx.Err(), ctx: b.ctx}
+	}
+	tx, err := b.DB.Begin(true)
+	return &BoltTransaction{DB: b.DB, tx: tx, err: err, ctx: b.ctx}
+}
+
+func (b *BoltTransaction) Commit() error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.tx == nil {
+		return nil
+	}
+	return b.tx.Commit()
+}
+
+func (b *BoltTransaction) RollBack() {
+	if b.tx != nil {
+		b.tx.Rollback()
+	}
+}
+
+func (b *BoltTransaction) WithContext(ctx context.Context) repository.ITransaction {
+	return &BoltTransaction{DB: b.DB, tx: b.tx, err: b.err, ctx: ctx}
+}
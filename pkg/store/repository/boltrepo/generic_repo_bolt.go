@@ -0,0 +1,1064 @@
+package boltrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/baderkha/library/pkg/err"
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/store/repository/internal/dbtag"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// BoltIndexTag : `kv_index:"1"` on a db tagged field keeps a secondary index of its values ,
+	// eq / in filters on the column (at the top level of the filter or of an AND group) scan the index instead of every record
+	BoltIndexTag = "kv_index"
+
+	// boltIndexSuffix : index buckets are named `<table>__idx__<column>`
+	boltIndexSuffix = "__idx__"
+)
+
+var (
+	ErrBoltNotFound = errors.New("Bolt : record not found")
+
+	ErrBoltAlreadyExists = err.Compose("Bolt : record `%s` of `%s` already exists")
+
+	// boltIndexOps : filter operations that can be answered by an index (equality)
+	boltIndexOps = map[string]bool{"eq": true, "in": true}
+)
+
+var _ repository.ICrud[entity.SavedView] = &CrudBolt[entity.SavedView]{}
+var _ repository.ISoftDelete[entity.SavedView] = &CrudBolt[entity.SavedView]{}
+var _ repository.IWhere[entity.SavedView] = &CrudBolt[entity.SavedView]{}
+var _ repository.ISchemaView[entity.SavedView] = &CrudBolt[entity.SavedView]{}
+
+// CrudBolt : repository.ICrud backed by an embedded bolt key value store (no server , ie cli tools / edge deployments) ,
+// the records are stored as json keyed by their id in a bucket named after the table
+//
+// filters and sorts are evaluated in memory (see rql.FilterParserMemory) over the records of the bucket ,
+// or over the records of a secondary index when the filter has an equality on an indexed column (see BoltIndexTag)
+//
+// Example :
+//			store, _ := bolt.Open("app.db", 0600, nil)
+//			repo := &boltrepo.CrudBolt[entity.SavedView]{DB: store}
+//			tx := (&boltrepo.BoltTransaction{DB: store}).Begin()
+//			err := repo.WithTransaction(tx).Create(view)
+//			err = tx.Commit()
+type CrudBolt[t entity.Model] struct {
+	DB *bolt.DB
+	// Parser : filter parser , defaults to rql.NewMemoryFilterParser() if nil
+	Parser rql.IFilterParser[rql.MemoryPredicate]
+	// Sorter : sort parser , defaults to rql.SortParserMemory{} if nil
+	Sorter rql.ISortParser[rql.MemoryLess]
+
+	// tx : transaction of the repository (see WithTransaction)
+	tx *BoltTransaction
+	// ctx : calls fail once it is done (see WithContext)
+	ctx context.Context
+	// includeDeleted : reads return soft deleted records (see IncludeDeleted)
+	includeDeleted bool
+	// schemaView : schema of the filter / sort / facet expressions (see WithSchema)
+	schemaView *rql.Schema
+}
+
+// boltRecord : a decoded record and its column values
+type boltRecord[t any] struct {
+	mdl    *t
+	values rql.MemoryRecord
+}
+
+func (c *CrudBolt[t]) Model() t {
+	var m t
+	return m
+}
+
+func (c *CrudBolt[t]) schema() *rql.Schema {
+	return rql.GetSchemaFromTaggedEntity(c.Model(), "db")
+}
+
+// filterSchema : schema of the filter / sort / facet expressions , the full schema unless restricted (see WithSchema)
+func (c *CrudBolt[t]) filterSchema() *rql.Schema {
+	if c.schemaView != nil {
+		return c.schemaView
+	}
+	return c.schema()
+}
+
+func (c *CrudBolt[t]) columns() []dbtag.Column {
+	return dbtag.ColumnsOf(reflect.TypeOf(c.Model()))
+}
+
+func (c *CrudBolt[t]) bucket() []byte {
+	return []byte(c.Model().TableName())
+}
+
+func (c *CrudBolt[t]) indexBucket(col string) []byte {
+	return []byte(c.Model().TableName() + boltIndexSuffix + col)
+}
+
+// indexedColumns : the columns tagged with BoltIndexTag
+func (c *CrudBolt[t]) indexedColumns() []dbtag.Column {
+	var (
+		schema  = c.schema()
+		indexed []dbtag.Column
+	)
+	for _, col := range c.columns() {
+		if schema.CheckTagExists(col.Name, BoltIndexTag) {
+			indexed = append(indexed, col)
+		}
+	}
+	return indexed
+}
+
+// ctxErr : the error of the context once it is done
+func (c *CrudBolt[t]) ctxErr() error {
+	if c.ctx == nil {
+		return nil
+	}
+	return c.ctx.Err()
+}
+
+// view : runs fn in the transaction of the repository if it has one , in a read transaction otherwise
+func (c *CrudBolt[t]) view(fn func(tx *bolt.Tx) error) error {
+	err := c.ctxErr()
+	if err != nil {
+		return err
+	}
+	if c.tx != nil {
+		if c.tx.err != nil {
+			return c.tx.err
+		}
+		if c.tx.tx != nil {
+			return fn(c.tx.tx)
+		}
+	}
+	return c.DB.View(fn)
+}
+
+// update : runs fn in the transaction of the repository if it has one , in a write transaction otherwise
+func (c *CrudBolt[t]) update(fn func(tx *bolt.Tx) error) error {
+	err := c.ctxErr()
+	if err != nil {
+		return err
+	}
+	if c.tx != nil {
+		if c.tx.err != nil {
+			return c.tx.err
+		}
+		if c.tx.tx != nil {
+			return fn(c.tx.tx)
+		}
+	}
+	return c.DB.Update(fn)
+}
+
+// memoryValue : the value of the field for the in memory filters (time types as time.Time , nil pointers as nil)
+func memoryValue(field reflect.Value) interface{} {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	if dbtag.IsTimeType(field.Type()) {
+		return field.Convert(dbtag.TimeType).Interface()
+	}
+	return field.Interface()
+}
+
+func (c *CrudBolt[t]) record(mdl *t) rql.MemoryRecord {
+	var (
+		cols   = c.columns()
+		v      = reflect.ValueOf(mdl).Elem()
+		values = make(rql.MemoryRecord, len(cols))
+	)
+	for _, col := range cols {
+		values[col.Name] = memoryValue(v.FieldByIndex(col.Index))
+	}
+	return values
+}
+
+// indexKey : the kind (s , n , b , t) and the encoded value of an indexed value , empty if the value cannot be indexed
+func indexKey(value interface{}) (kind string, key string) {
+	if value == nil {
+		return "", ""
+	}
+	tm, ok := value.(time.Time)
+	if ok {
+		return "t", tm.UTC().Format(time.RFC3339Nano)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return "s", rv.String()
+	case reflect.Bool:
+		return "b", strconv.FormatBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "n", strconv.FormatFloat(float64(rv.Int()), 'g', -1, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "n", strconv.FormatFloat(float64(rv.Uint()), 'g', -1, 64)
+	case reflect.Float32, reflect.Float64:
+		return "n", strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+	}
+	return "", ""
+}
+
+// indexKind : the kind of the indexed values of a field type
+func indexKind(tpe reflect.Type) string {
+	for tpe.Kind() == reflect.Ptr {
+		tpe = tpe.Elem()
+	}
+	kind, _ := indexKey(reflect.Zero(tpe).Interface())
+	if dbtag.IsTimeType(tpe) {
+		return "t"
+	}
+	return kind
+}
+
+func indexPrefix(kind string, key string) []byte {
+	return []byte(kind + ":" + key + "\x00")
+}
+
+// indexEntries : column => index entry (`<kind>:<value>\x00<id>`) of the record
+func (c *CrudBolt[t]) indexEntries(mdl *t) map[string][]byte {
+	var (
+		v       = reflect.ValueOf(mdl).Elem()
+		entries = make(map[string][]byte)
+	)
+	for _, col := range c.indexedColumns() {
+		kind, key := indexKey(memoryValue(v.FieldByIndex(col.Index)))
+		if kind == "" {
+			continue
+		}
+		entries[col.Name] = append(indexPrefix(kind, key), (*mdl).GetID()...)
+	}
+	return entries
+}
+
+// lookupIndex : ids of the records matching the first eq / in filter on an indexed column , false if no filter can use an index
+func (c *CrudBolt[t]) lookupIndex(tx *bolt.Tx, expressions []*rql.FilterExpression) ([]string, bool) {
+	var (
+		schema = c.schema()
+		leaves []*rql.FilterExpression
+		kinds  = make(map[string]string)
+		tpe    = reflect.TypeOf(c.Model())
+	)
+	for _, col := range c.indexedColumns() {
+		kinds[col.Name] = indexKind(tpe.FieldByIndex(col.Index).Type)
+	}
+	for _, expression := range expressions {
+		if expression == nil {
+			continue
+		}
+		if expression.Column != "" && expression.Op != "" {
+			leaves = append(leaves, expression)
+			continue
+		}
+		if expression.BinaryOperation != rql.ANDOperator {
+			continue
+		}
+		for _, prop := range expression.Properties {
+			if prop != nil && prop.Column != "" && prop.Op != "" {
+				leaves = append(leaves, prop)
+			}
+		}
+	}
+
+	for _, leaf := range leaves {
+		col := schema.GetColumnInternalName(leaf.Column)
+		if !boltIndexOps[leaf.Op] || kinds[col] == "" || leaf.Value == nil {
+			continue
+		}
+		values := []interface{}{leaf.Value}
+		rv := reflect.ValueOf(leaf.Value)
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			values = values[:0]
+			for i := 0; i < rv.Len(); i++ {
+				values = append(values, rv.Index(i).Interface())
+			}
+		}
+		var prefixes [][]byte
+		for _, value := range values {
+			kind, key := indexKey(value)
+			if kind != kinds[col] {
+				prefixes = nil
+				break
+			}
+			prefixes = append(prefixes, indexPrefix(kind, key))
+		}
+		if len(prefixes) == 0 {
+			continue
+		}
+
+		var (
+			ids  []string
+			seen = make(map[string]bool)
+			ib   = tx.Bucket(c.indexBucket(col))
+		)
+		if ib == nil {
+			return nil, true
+		}
+		cursor := ib.Cursor()
+		for _, prefix := range prefixes {
+			for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+				id := string(k[len(prefix):])
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+		return ids, true
+	}
+	return nil, false
+}
+
+// decode : the stored record , nil if it does not exist
+func (c *CrudBolt[t]) get(tx *bolt.Tx, id string) (*t, error) {
+	b := tx.Bucket(c.bucket())
+	if b == nil {
+		return nil, nil
+	}
+	data := b.Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+	var mdl t
+	err := json.Unmarshal(data, &mdl)
+	if err != nil {
+		return nil, err
+	}
+	return &mdl, nil
+}
+
+// isHidden : the record is soft deleted and the repository is not an IncludeDeleted view
+func (c *CrudBolt[t]) isHidden(mdl *t) bool {
+	deletable, ok := any(mdl).(entity.SoftDeletable)
+	return ok && !c.includeDeleted && deletable.GetIsDeleted()
+}
+
+// each : calls fn with the visible records , only the records of the index if one of the filters can use it
+func (c *CrudBolt[t]) each(tx *bolt.Tx, expressions []*rql.FilterExpression, fn func(rec boltRecord[t])) error {
+	b := tx.Bucket(c.bucket())
+	if b == nil {
+		return nil
+	}
+	visit := func(data []byte) error {
+		var mdl t
+		err := json.Unmarshal(data, &mdl)
+		if err != nil {
+			return err
+		}
+		if c.isHidden(&mdl) {
+			return nil
+		}
+		fn(boltRecord[t]{mdl: &mdl, values: c.record(&mdl)})
+		return nil
+	}
+
+	ids, isIndexed := c.lookupIndex(tx, expressions)
+	if !isIndexed {
+		return b.ForEach(func(k []byte, v []byte) error {
+			return visit(v)
+		})
+	}
+	for _, id := range ids {
+		data := b.Get([]byte(id))
+		if data == nil {
+			continue
+		}
+		err := visit(data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CrudBolt[t]) parser() rql.IFilterParser[rql.MemoryPredicate] {
+	if c.Parser != nil {
+		return c.Parser
+	}
+	return rql.NewMemoryFilterParser()
+}
+
+func (c *CrudBolt[t]) sorter() rql.ISortParser[rql.MemoryLess] {
+	if c.Sorter != nil {
+		return c.Sorter
+	}
+	return rql.SortParserMemory{}
+}
+
+// find : the visible records matching the filter + base expression , sorted
+func (c *CrudBolt[t]) find(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) ([]boltRecord[t], error) {
+	var records []boltRecord[t]
+	err := c.view(func(tx *bolt.Tx) error {
+		var err error
+		records, err = c.findTx(tx, f, s, baseExpression...)
+		return err
+	})
+	return records, err
+}
+
+// findTx : same as find in the transaction
+func (c *CrudBolt[t]) findTx(tx *bolt.Tx, f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) ([]boltRecord[t], error) {
+	var (
+		schema      = c.schema()
+		expressions = append([]*rql.FilterExpression{f}, baseExpression...)
+		matches     []rql.MemoryPredicate
+		records     []boltRecord[t]
+	)
+	for i, expression := range expressions {
+		if repository.IsEmptyFilter(expression) {
+			continue
+		}
+		// the filter is parsed against the schema view , the base expressions against the full schema
+		exprSchema := schema
+		if i == 0 {
+			exprSchema = c.filterSchema()
+		}
+		match, err := c.parser().Parse(expression, exprSchema)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, *match)
+	}
+	var less rql.MemoryLess
+	if s != nil {
+		compiled, err := c.sorter().Parse(s, c.filterSchema())
+		if err != nil {
+			return nil, err
+		}
+		less = *compiled
+	}
+
+	err := c.each(tx, expressions, func(rec boltRecord[t]) {
+		for _, match := range matches {
+			if !match(rec.values) {
+				return
+			}
+		}
+		records = append(records, rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if less != nil {
+		sort.SliceStable(records, func(i, j int) bool {
+			return less(records[i].values, records[j].values)
+		})
+	}
+	return records, nil
+}
+
+func models[t any](records []boltRecord[t]) []*t {
+	res := make([]*t, 0, len(records))
+	for _, rec := range records {
+		res = append(res, rec.mdl)
+	}
+	return res
+}
+
+func (c *CrudBolt[t]) DoesIDExist(id string) bool {
+	obj, err := c.GetById(id)
+	return err == nil && obj != nil
+}
+
+func (c *CrudBolt[t]) IsForAccountID(id string, accountID string) bool {
+	obj, err := c.GetById(id)
+	return err == nil && obj != nil && (*obj).GetAccountID() == accountID
+}
+
+// GetById : get 1 record by id if not found should return err
+func (c *CrudBolt[t]) GetById(id string) (*t, error) {
+	var res *t
+	err := c.view(func(tx *bolt.Tx) error {
+		mdl, err := c.get(tx, id)
+		if err != nil {
+			return err
+		}
+		if mdl == nil || c.isHidden(mdl) {
+			return ErrBoltNotFound
+		}
+		res = mdl
+		return nil
+	})
+	return res, err
+}
+
+// GetAll : get all the records (db dump)
+func (c *CrudBolt[t]) GetAll() ([]*t, error) {
+	records, err := c.find(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return models(records), nil
+}
+
+// GetWithFilterExpression : filter + sort a result using the rql package
+func (c *CrudBolt[t]) GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error) {
+	records, err := c.find(f, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	return models(records), nil
+}
+
+// page : the page of the matching records , the count is exact unless the count mode is rql.CountNone
+func (c *CrudBolt[t]) page(records []boltRecord[t], p *rql.PaginationExpression) *repository.Paginated[t] {
+	var (
+		mode  = rql.CountExact
+		count = int64(len(records))
+		start = p.Offset()
+		end   = p.Offset() + p.Size()
+	)
+	if p.CountMode() == rql.CountNone {
+		mode = rql.CountNone
+		count = -1
+	}
+	if start > len(records) {
+		start = len(records)
+	}
+	if end > len(records) {
+		end = len(records)
+	}
+	return repository.NewPaginated(p, models(records[start:end]), count, mode, end < len(records))
+}
+
+// GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package
+//
+// every matching record is read to sort them so the count is always exact (unless the count mode is rql.CountNone) ,
+// a nil pagination expression is the first page of rql.DefaultPaginationOptions
+func (c *CrudBolt[t]) GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *repository.Paginated[t], err error) {
+	if p == nil {
+		p = rql.DefaultPaginationOptions.First()
+	}
+	records, err := c.find(f, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	return c.page(records, p), nil
+}
+
+// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns over all the matching records
+// (the facet rules of the sql repositories apply , see rql.FacetExpression.ValidateForSQL)
+func (c *CrudBolt[t]) GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *repository.Faceted[t], err error) {
+	var (
+		schema   = c.filterSchema()
+		byColumn = make(map[string]*repository.FacetResult)
+	)
+	if facets == nil {
+		return nil, rql.ErrFacetEmpty
+	}
+	err = facets.ValidateForSQL(schema)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = rql.DefaultPaginationOptions.First()
+	}
+	records, err := c.find(f, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range facets.Columns() {
+		byColumn[col] = boltFacet(records, schema, col, facets.GetMaxValues())
+	}
+	return repository.NewFaceted(c.page(records, p), facets, byColumn), nil
+}
+
+// boltFacet : most frequent values of the column (and its stats if numeric) over the records , null values are not counted
+func boltFacet[t any](records []boltRecord[t], schema *rql.Schema, col string, maxValues int) *repository.FacetResult {
+	var (
+		internal = schema.GetColumnInternalName(col)
+		counts   = make(map[string]int64)
+		res      = repository.FacetResult{Column: col, Counts: make([]repository.FacetCount, 0)}
+		stats    repository.FacetStats
+		numbers  int64
+		distinct = make(map[float64]bool)
+	)
+	for _, rec := range records {
+		value := rec.values[internal]
+		if value == nil {
+			continue
+		}
+		kind, key := indexKey(value)
+		if kind == "" {
+			continue
+		}
+		counts[key]++
+		if kind != "n" {
+			continue
+		}
+		n, _ := strconv.ParseFloat(key, 64)
+		if numbers == 0 || n < stats.Min {
+			stats.Min = n
+		}
+		if numbers == 0 || n > stats.Max {
+			stats.Max = n
+		}
+		stats.Sum += n
+		numbers++
+		distinct[n] = true
+	}
+	for value, count := range counts {
+		res.Counts = append(res.Counts, repository.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(res.Counts, func(i, j int) bool {
+		if res.Counts[i].Count != res.Counts[j].Count {
+			return res.Counts[i].Count > res.Counts[j].Count
+		}
+		return res.Counts[i].Value < res.Counts[j].Value
+	})
+	if len(res.Counts) > maxValues {
+		res.Counts = res.Counts[:maxValues]
+	}
+	if !schema.IsNumericColumn(col) {
+		return &res
+	}
+	if numbers > 0 {
+		stats.Avg = stats.Sum / float64(numbers)
+	}
+	stats.TotalValues = int64(len(distinct))
+	res.Stats = &stats
+	return &res
+}
+
+// put : stores the record and moves its index entries (oldEntries are the entries of the stored record)
+func (c *CrudBolt[t]) put(tx *bolt.Tx, mdl *t, oldEntries map[string][]byte) error {
+	b, err := tx.CreateBucketIfNotExists(c.bucket())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(mdl)
+	if err != nil {
+		return err
+	}
+	for col, entry := range oldEntries {
+		ib := tx.Bucket(c.indexBucket(col))
+		if ib == nil {
+			continue
+		}
+		err := ib.Delete(entry)
+		if err != nil {
+			return err
+		}
+	}
+	err = b.Put([]byte((*mdl).GetID()), data)
+	if err != nil {
+		return err
+	}
+	for col, entry := range c.indexEntries(mdl) {
+		ib, err := tx.CreateBucketIfNotExists(c.indexBucket(col))
+		if err != nil {
+			return err
+		}
+		err = ib.Put(entry, []byte{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create : stores a new record , the version starts at 1 , the creation / update times default to now
+// and the auto incremented columns are set to the next sequence of the bucket (see repository.AutoIncrementTag)
+func (c *CrudBolt[t]) create(tx *bolt.Tx, mdl *t) error {
+	old, err := c.get(tx, (*mdl).GetID())
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		return ErrBoltAlreadyExists((*mdl).GetID(), c.Model().TableName())
+	}
+	var (
+		cols = c.columns()
+		v    = reflect.ValueOf(mdl).Elem()
+		now  = time.Now()
+	)
+	repository.InitVersion(mdl)
+	dbtag.SetTimeColumn(v, cols, "created_at", now, true)
+	dbtag.SetTimeColumn(v, cols, repository.ColumnUpdatedAt, now, true)
+	for _, col := range cols {
+		if !col.AutoIncrement {
+			continue
+		}
+		bucket, err := tx.CreateBucketIfNotExists(c.bucket())
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		v.FieldByIndex(col.Index).SetInt(int64(seq))
+	}
+	return c.put(tx, mdl, nil)
+}
+
+// Create : create one
+func (c *CrudBolt[t]) Create(mdl *t) error {
+	return c.update(func(tx *bolt.Tx) error {
+		return c.create(tx, mdl)
+	})
+}
+
+// BulkCreate : create many , in a single write transaction
+func (c *CrudBolt[t]) BulkCreate(mdl []*t) error {
+	return c.update(func(tx *bolt.Tx) error {
+		for _, m := range mdl {
+			err := c.create(tx, m)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update : update model (the stored record is replaced) , the update time is set to now
+//
+// versioned models (see entity.BaseVersioned) are only updated if the stored version is the version of the model ,
+// the version is then incremented , a *repository.VersionConflictError is returned otherwise
+func (c *CrudBolt[t]) Update(mdl *t) error {
+	versioned, _ := repository.VersionOf(mdl)
+	var version int64
+	if versioned != nil {
+		version = versioned.GetVersion()
+	}
+	err := c.update(func(tx *bolt.Tx) error {
+		old, err := c.get(tx, (*mdl).GetID())
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			return ErrBoltNotFound
+		}
+		if versioned != nil {
+			stored, _ := repository.VersionOf(old)
+			if stored.GetVersion() != version {
+				return &repository.VersionConflictError{Table: c.Model().TableName(), ID: (*mdl).GetID(), Version: version}
+			}
+			versioned.SetVersion(version + 1)
+		}
+		dbtag.SetTimeColumn(reflect.ValueOf(mdl).Elem(), c.columns(), repository.ColumnUpdatedAt, time.Now(), false)
+		return c.put(tx, mdl, c.indexEntries(old))
+	})
+	if err != nil && versioned != nil {
+		versioned.SetVersion(version)
+	}
+	return err
+}
+
+// DeleteById : perma delete model by id
+func (c *CrudBolt[t]) DeleteById(id string) error {
+	return c.DeleteByIds([]string{id})
+}
+
+// DeleteByIds : perma delet by many ids , missing ids are skipped
+func (c *CrudBolt[t]) DeleteByIds(id []string) error {
+	return c.update(func(tx *bolt.Tx) error {
+		return c.delete(tx, id)
+	})
+}
+
+func (c *CrudBolt[t]) delete(tx *bolt.Tx, ids []string) error {
+	b := tx.Bucket(c.bucket())
+	if b == nil {
+		return nil
+	}
+	for _, id := range ids {
+		old, err := c.get(tx, id)
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			continue
+		}
+		for col, entry := range c.indexEntries(old) {
+			ib := tx.Bucket(c.indexBucket(col))
+			if ib == nil {
+				continue
+			}
+			err := ib.Delete(entry)
+			if err != nil {
+				return err
+			}
+		}
+		err = b.Delete([]byte(id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildIndexes : rebuilds the secondary indexes from the stored records (ie after tagging a column with BoltIndexTag)
+func (c *CrudBolt[t]) RebuildIndexes() error {
+	return c.update(func(tx *bolt.Tx) error {
+		for _, col := range c.indexedColumns() {
+			if tx.Bucket(c.indexBucket(col.Name)) == nil {
+				continue
+			}
+			err := tx.DeleteBucket(c.indexBucket(col.Name))
+			if err != nil {
+				return err
+			}
+		}
+		b := tx.Bucket(c.bucket())
+		if b == nil {
+			return nil
+		}
+		var all []*t
+		err := b.ForEach(func(k []byte, v []byte) error {
+			var mdl t
+			err := json.Unmarshal(v, &mdl)
+			if err != nil {
+				return err
+			}
+			all = append(all, &mdl)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, mdl := range all {
+			err := c.put(tx, mdl, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WithTransaction : transactional pointer , the transaction must be a *BoltTransaction
+func (c *CrudBolt[t]) WithTransaction(tx repository.ITransaction) repository.ICrud[t] {
+	cp := *c
+	cp.tx = tx.(*BoltTransaction)
+	return &cp
+}
+
+// WithContext : view of the repository whose calls fail once the context is done (a bolt call cannot be cancelled midway)
+func (c *CrudBolt[t]) WithContext(ctx context.Context) repository.ICrud[t] {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
+
+// setDeleted : soft deletes / restores the records that are not in the state yet , the update time is set to now (and the version incremented)
+func (c *CrudBolt[t]) setDeleted(ids []string, isDeleted bool) (int64, error) {
+	var mdl t
+	_, ok := any(&mdl).(entity.SoftDeletable)
+	if !ok {
+		return 0, repository.ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	var updated int64
+	err := c.update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			rec, err := c.get(tx, id)
+			if err != nil {
+				return err
+			}
+			if rec == nil || any(rec).(entity.SoftDeletable).GetIsDeleted() == isDeleted {
+				continue
+			}
+			oldEntries := c.indexEntries(rec)
+			any(rec).(entity.SoftDeletable).SetDeleted(isDeleted)
+			versioned, _ := repository.VersionOf(rec)
+			if versioned != nil {
+				versioned.SetVersion(versioned.GetVersion() + 1)
+			}
+			err = c.put(tx, rec, oldEntries)
+			if err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// SoftDeleteById : mark the record as deleted
+func (c *CrudBolt[t]) SoftDeleteById(id string) error {
+	_, err := c.setDeleted([]string{id}, true)
+	return err
+}
+
+// SoftDeleteByIds : mark many records as deleted
+func (c *CrudBolt[t]) SoftDeleteByIds(ids []string) error {
+	_, err := c.setDeleted(ids, true)
+	return err
+}
+
+// Restore : unmark a soft deleted record , repository.ErrSoftDeleteNotFound if there is no soft deleted record with the id
+func (c *CrudBolt[t]) Restore(id string) error {
+	restored, err := c.setDeleted([]string{id}, false)
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return repository.ErrSoftDeleteNotFound
+	}
+	return nil
+}
+
+// PurgeDeletedOlderThan : perma delete the records soft deleted more than age ago , returns the number of records purged
+func (c *CrudBolt[t]) PurgeDeletedOlderThan(age time.Duration) (int64, error) {
+	colDeleted, colUpdated := repository.SoftDeleteColumns(c.schema())
+	if colDeleted == "" || colUpdated == "" {
+		return 0, repository.ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	if age < 0 {
+		return 0, repository.ErrSoftDeleteNegativeAge
+	}
+	var (
+		cutoff = time.Now().Add(-age)
+		purged int64
+	)
+	err := c.update(func(tx *bolt.Tx) error {
+		var ids []string
+		all := &CrudBolt[t]{includeDeleted: true}
+		err := all.each(tx, nil, func(rec boltRecord[t]) {
+			updatedAt, ok := rec.values[colUpdated].(time.Time)
+			if rec.values[colDeleted] == true && ok && updatedAt.Before(cutoff) {
+				ids = append(ids, (*rec.mdl).GetID())
+			}
+		})
+		if err != nil {
+			return err
+		}
+		purged = int64(len(ids))
+		return c.delete(tx, ids)
+	})
+	return purged, err
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted records
+func (c *CrudBolt[t]) IncludeDeleted() repository.ICrud[t] {
+	cp := *c
+	cp.includeDeleted = true
+	return &cp
+}
+
+// WithSchema : view of the repository whose filter / sort / facet expressions are parsed and validated against the schema
+// (ie rql.Schema.ForRole) , the base expressions keep using the full schema
+func (c *CrudBolt[t]) WithSchema(schema *rql.Schema) repository.ICrud[t] {
+	cp := *c
+	cp.schemaView = schema
+	return &cp
+}
+
+// CountWhere : number of records matching the filter
+func (c *CrudBolt[t]) CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	records, err := c.find(f, nil, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(records)), nil
+}
+
+// ExistsWhere : at least 1 record matches the filter
+func (c *CrudBolt[t]) ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error) {
+	count, err := c.CountWhere(f, baseExpression...)
+	return count > 0, err
+}
+
+// UpdateWhere : sets the columns of the patch on the records matching the filter in 1 write transaction , returns the number of records updated
+func (c *CrudBolt[t]) UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := repository.RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	updates, err := repository.PatchColumns(c.schema(), patch, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	cols := c.columns()
+	for col, value := range updates {
+		sqlCol := dbtag.FindColumn(cols, col)
+		field := reflect.New(reflect.TypeOf(c.Model())).Elem()
+		if sqlCol == nil || !setFieldValue(field.FieldByIndex(sqlCol.Index), value) {
+			return 0, repository.ErrWherePatchValue(col, value)
+		}
+	}
+	var updated int64
+	err = c.update(func(tx *bolt.Tx) error {
+		records, err := c.findTx(tx, f, nil, baseExpression...)
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			oldEntries := c.indexEntries(rec.mdl)
+			v := reflect.ValueOf(rec.mdl).Elem()
+			for col, value := range updates {
+				setFieldValue(v.FieldByIndex(dbtag.FindColumn(cols, col).Index), value)
+			}
+			versioned, _ := repository.VersionOf(rec.mdl)
+			if versioned != nil {
+				versioned.SetVersion(versioned.GetVersion() + 1)
+			}
+			err = c.put(tx, rec.mdl, oldEntries)
+			if err != nil {
+				return err
+			}
+		}
+		updated = int64(len(records))
+		return nil
+	})
+	return updated, err
+}
+
+// DeleteWhere : perma deletes the records matching the filter in 1 write transaction , returns the number of records deleted
+func (c *CrudBolt[t]) DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := repository.RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	err = c.update(func(tx *bolt.Tx) error {
+		records, err := c.findTx(tx, f, nil, baseExpression...)
+		if err != nil {
+			return err
+		}
+		deleted = int64(len(records))
+		return c.delete(tx, repository.RecordIds(models(records)))
+	})
+	return deleted, err
+}
+
+// setFieldValue : sets the field to the patched value (converted to the type of the field) , nil sets the zero value
+func setFieldValue(field reflect.Value, value interface{}) bool {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return true
+	}
+	var (
+		rv  = reflect.ValueOf(value)
+		tpe = field.Type()
+	)
+	if tpe.Kind() == reflect.Ptr && rv.Kind() != reflect.Ptr {
+		elem := reflect.New(tpe.Elem())
+		if !setFieldValue(elem.Elem(), value) {
+			return false
+		}
+		field.Set(elem)
+		return true
+	}
+	// ints are convertible to strings (as runes) , that is never what a patch means
+	if (tpe.Kind() == reflect.String) != (rv.Kind() == reflect.String) || !rv.Type().ConvertibleTo(tpe) {
+		return false
+	}
+	field.Set(rv.Convert(tpe))
+	return true
+}
@@ -0,0 +1,66 @@
+package boltrepo
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	bolt "go.etcd.io/bbolt"
+)
+
+type boltTestEntity struct {
+	entity.BaseOwned
+	Name string `json:"name" db:"name"`
+}
+
+func (boltTestEntity) TableName() string { return "test_entities" }
+
+func TestBoltRestoreOnlySoftDeletedRecords(t *testing.T) {
+	store, err := bolt.Open(filepath.Join(t.TempDir(), "soft.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	repo := &CrudBolt[boltTestEntity]{DB: store}
+
+	rec := &boltTestEntity{Name: "a"}
+	rec.ID = "rec1"
+	err = repo.Create(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.Restore("rec1")
+	if !errors.Is(err, repository.ErrSoftDeleteNotFound) {
+		t.Fatalf("restore of a live record : got %v , want %v", err, repository.ErrSoftDeleteNotFound)
+	}
+	err = repo.SoftDeleteByIds([]string{"rec1", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.SoftDeleteById("rec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Restore("rec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Restore("rec1")
+	if !errors.Is(err, repository.ErrSoftDeleteNotFound) {
+		t.Fatalf("second restore : got %v , want %v", err, repository.ErrSoftDeleteNotFound)
+	}
+	err = repo.Restore("missing")
+	if !errors.Is(err, repository.ErrSoftDeleteNotFound) {
+		t.Fatalf("restore of a missing id : got %v , want %v", err, repository.ErrSoftDeleteNotFound)
+	}
+	got, err := repo.GetById("rec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "a" {
+		t.Fatalf("name = %q , want a", got.Name)
+	}
+}
@@ -0,0 +1,457 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/baderkha/library/pkg/cache"
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+)
+
+var _ ICrud[entity.Session] = &CachedCrud[entity.Session]{}
+var _ ISoftDelete[entity.Session] = &CachedCrud[entity.Session]{}
+var _ IWhere[entity.Session] = &CachedCrud[entity.Session]{}
+
+// CacheStats : counters of a cached repository since it was created
+type CacheStats struct {
+	// Hits : lookups served by the cache
+	Hits int64 `json:"hits"`
+	// Misses : lookups loaded from the repository
+	Misses int64 `json:"misses"`
+	// SharedLoads : misses that waited for the load of a concurrent lookup of the same record instead of loading it
+	SharedLoads int64 `json:"shared_loads"`
+	// Invalidations : records removed from the cache by writes
+	Invalidations int64 `json:"invalidations"`
+	// Errors : failed cache calls , the repository is used instead
+	Errors int64 `json:"errors"`
+}
+
+// HitRatio : hits / lookups , 0 without lookups
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// cacheState : shared by the views of a cached repository
+type cacheState struct {
+	group cache.Group
+	// generation : incremented by every write , a miss only caches the record it loaded if no write happened during the load
+	generation int64
+
+	hits          int64
+	misses        int64
+	sharedLoads   int64
+	invalidations int64
+	errors        int64
+}
+
+// CachedCrud : read through cache decorator , GetById / DoesIDExist / IsForAccountID are served from the cache
+// and every write of the repository invalidates the records it touches (an update caches the record as stored after it) , the other reads are not cached
+//
+// concurrent misses of the same record only load it once per process (stampede protection) , a miss does not cache
+// the record it loaded if a write of the process happened during the load . writes of other processes (or a write landing between
+// that check and the cache write) can still leave a stale record cached until the ttl expires or the record is written again
+//
+// transactional views (see WithTransaction) read from the repository , their writes invalidate the cache right away
+// so a record read before the transaction commits can be cached with its old value until the ttl expires
+//
+// Example :
+//			sessions := repository.NewCachedCrud[entity.Session](
+//				&repository.SessionGorm{DB: db},
+//				&cache.LRU{MaxEntries: 10000},
+//				time.Minute,
+//			)
+type CachedCrud[t entity.Model] struct {
+	Repo  ICrud[t]
+	Cache cache.ICache
+	// TTL : time a record stays cached (<= 0 until invalidated / evicted)
+	TTL time.Duration
+	// KeyPrefix : prepended to the cache keys (`<prefix><table name>:<id>`)
+	KeyPrefix string
+
+	state *cacheState
+	// ctx : context of the calls (see WithContext)
+	ctx context.Context
+	// bypass : reads skip the cache (transactional views)
+	bypass bool
+}
+
+// NewCachedCrud : read through cache decorator of the repository
+func NewCachedCrud[t entity.Model](repo ICrud[t], c cache.ICache, ttl time.Duration) *CachedCrud[t] {
+	return &CachedCrud[t]{
+		Repo:  repo,
+		Cache: c,
+		TTL:   ttl,
+		state: &cacheState{},
+	}
+}
+
+func (c *CachedCrud[t]) Model() t {
+	var m t
+	return m
+}
+
+// Stats : cache counters shared by every view of the repository
+func (c *CachedCrud[t]) Stats() CacheStats {
+	return CacheStats{
+		Hits:          atomic.LoadInt64(&c.state.hits),
+		Misses:        atomic.LoadInt64(&c.state.misses),
+		SharedLoads:   atomic.LoadInt64(&c.state.sharedLoads),
+		Invalidations: atomic.LoadInt64(&c.state.invalidations),
+		Errors:        atomic.LoadInt64(&c.state.errors),
+	}
+}
+
+func (c *CachedCrud[t]) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func (c *CachedCrud[t]) key(id string) string {
+	return c.KeyPrefix + c.Model().TableName() + ":" + id
+}
+
+// view : copy of the decorator around another view of the repository
+func (c *CachedCrud[t]) view(repo ICrud[t]) *CachedCrud[t] {
+	cp := *c
+	cp.Repo = repo
+	return &cp
+}
+
+// fromCache : the cached record , nil if it is not cached (or cannot be decoded)
+func (c *CachedCrud[t]) fromCache(key string) *t {
+	b, found, err := c.Cache.Get(c.context(), key)
+	if err != nil {
+		atomic.AddInt64(&c.state.errors, 1)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+	var res t
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		atomic.AddInt64(&c.state.errors, 1)
+		return nil
+	}
+	return &res
+}
+
+// GetById : get 1 record by id if not found should return err , not found errors are not cached
+func (c *CachedCrud[t]) GetById(id string) (*t, error) {
+	if c.bypass {
+		return c.Repo.GetById(id)
+	}
+	key := c.key(id)
+	res := c.fromCache(key)
+	if res != nil {
+		atomic.AddInt64(&c.state.hits, 1)
+		return res, nil
+	}
+	atomic.AddInt64(&c.state.misses, 1)
+
+	encoded, err, shared := c.state.group.Do(key, func() (interface{}, error) {
+		generation := atomic.LoadInt64(&c.state.generation)
+		rec, err := c.Repo.GetById(id)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		// a write during the load may have been read before it landed , the next miss loads it again
+		if atomic.LoadInt64(&c.state.generation) != generation {
+			return b, nil
+		}
+		if c.Cache.Set(c.context(), key, b, c.TTL) != nil {
+			atomic.AddInt64(&c.state.errors, 1)
+		}
+		return b, nil
+	})
+	if shared {
+		atomic.AddInt64(&c.state.sharedLoads, 1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// every caller decodes its own copy so mutating the record does not change the other callers' records
+	var rec t
+	err = json.Unmarshal(encoded.([]byte), &rec)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (c *CachedCrud[t]) DoesIDExist(id string) bool {
+	res, err := c.GetById(id)
+	return err == nil && res != nil
+}
+
+func (c *CachedCrud[t]) IsForAccountID(id string, accountID string) bool {
+	res, err := c.GetById(id)
+	return err == nil && res != nil && (*res).GetAccountID() == accountID
+}
+
+// GetAll : get all the records (db dump) , not cached
+func (c *CachedCrud[t]) GetAll() ([]*t, error) {
+	return c.Repo.GetAll()
+}
+
+// GetWithFilterExpression : filter + sort a result using the rql package , not cached
+func (c *CachedCrud[t]) GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error) {
+	return c.Repo.GetWithFilterExpression(f, s, baseExpression...)
+}
+
+// GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package , not cached
+func (c *CachedCrud[t]) GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *Paginated[t], err error) {
+	return c.Repo.GetWithFilterExpressionPaginated(f, p, s, baseExpression...)
+}
+
+// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns , not cached
+func (c *CachedCrud[t]) GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *Faceted[t], err error) {
+	return c.Repo.GetWithFilterExpressionFaceted(f, p, s, facets, baseExpression...)
+}
+
+// invalidate : removes the records from the cache
+func (c *CachedCrud[t]) invalidate(ids ...string) {
+	if len(ids) == 0 {
+		return
+	}
+	atomic.AddInt64(&c.state.generation, 1)
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, c.key(id))
+	}
+	if c.Cache.Delete(c.context(), keys...) != nil {
+		atomic.AddInt64(&c.state.errors, 1)
+		return
+	}
+	atomic.AddInt64(&c.state.invalidations, int64(len(ids)))
+}
+
+// Create : create one
+func (c *CachedCrud[t]) Create(mdl *t) error {
+	err := c.Repo.Create(mdl)
+	if err != nil {
+		return err
+	}
+	c.invalidate((*mdl).GetID())
+	return nil
+}
+
+// BulkCreate : create many
+func (c *CachedCrud[t]) BulkCreate(mdl []*t) error {
+	err := c.Repo.BulkCreate(mdl)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(mdl))
+	for _, m := range mdl {
+		ids = append(ids, (*m).GetID())
+	}
+	c.invalidate(ids...)
+	return nil
+}
+
+// refresh : caches the record as stored after a write (the written model can be partial , ie gorm Updates skips its zero fields) ,
+// invalidated instead for transactional views (the transaction can still roll back) and records the reads do not return (ie soft deleted)
+func (c *CachedCrud[t]) refresh(id string) {
+	atomic.AddInt64(&c.state.generation, 1)
+	if c.bypass {
+		c.invalidate(id)
+		return
+	}
+	rec, err := c.Repo.GetById(id)
+	if err != nil {
+		c.invalidate(id)
+		return
+	}
+	b, err := json.Marshal(rec)
+	if err == nil {
+		err = c.Cache.Set(c.context(), c.key(id), b, c.TTL)
+	}
+	if err != nil {
+		atomic.AddInt64(&c.state.errors, 1)
+		c.invalidate(id)
+	}
+}
+
+// Update : update model , the record is read again and cached so frequently refreshed records (ie sessions) stay cached ,
+// the record is invalidated if the update fails (ie on a version conflict the cached record is stale)
+func (c *CachedCrud[t]) Update(mdl *t) error {
+	err := c.Repo.Update(mdl)
+	if err != nil {
+		c.invalidate((*mdl).GetID())
+		return err
+	}
+	c.refresh((*mdl).GetID())
+	return nil
+}
+
+// DeleteById : perma delete model by id
+func (c *CachedCrud[t]) DeleteById(id string) error {
+	err := c.Repo.DeleteById(id)
+	if err != nil {
+		return err
+	}
+	c.invalidate(id)
+	return nil
+}
+
+// DeleteByIds : perma delet by many ids
+func (c *CachedCrud[t]) DeleteByIds(id []string) error {
+	err := c.Repo.DeleteByIds(id)
+	if err != nil {
+		return err
+	}
+	c.invalidate(id...)
+	return nil
+}
+
+// softDelete : the cached repository as a soft delete repository
+func (c *CachedCrud[t]) softDelete() (ISoftDelete[t], error) {
+	repo, ok := c.Repo.(ISoftDelete[t])
+	if !ok {
+		return nil, ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	return repo, nil
+}
+
+// SoftDeleteById : mark the record as deleted (the cached repository must be an ISoftDelete)
+func (c *CachedCrud[t]) SoftDeleteById(id string) error {
+	return c.SoftDeleteByIds([]string{id})
+}
+
+// SoftDeleteByIds : mark many records as deleted (the cached repository must be an ISoftDelete)
+func (c *CachedCrud[t]) SoftDeleteByIds(ids []string) error {
+	repo, err := c.softDelete()
+	if err != nil {
+		return err
+	}
+	err = repo.SoftDeleteByIds(ids)
+	if err != nil {
+		return err
+	}
+	c.invalidate(ids...)
+	return nil
+}
+
+// Restore : unmark a soft deleted record (the cached repository must be an ISoftDelete)
+func (c *CachedCrud[t]) Restore(id string) error {
+	repo, err := c.softDelete()
+	if err != nil {
+		return err
+	}
+	err = repo.Restore(id)
+	if err != nil {
+		return err
+	}
+	c.invalidate(id)
+	return nil
+}
+
+// PurgeDeletedOlderThan : perma delete the records soft deleted more than age ago (they were invalidated when soft deleted)
+func (c *CachedCrud[t]) PurgeDeletedOlderThan(age time.Duration) (int64, error) {
+	repo, err := c.softDelete()
+	if err != nil {
+		return 0, err
+	}
+	return repo.PurgeDeletedOlderThan(age)
+}
+
+// CountWhere : number of records matching the filter , not cached (the cached repository must be an IWhere)
+func (c *CachedCrud[t]) CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	repo, err := WhereOf(c.Repo)
+	if err != nil {
+		return 0, err
+	}
+	return repo.CountWhere(f, baseExpression...)
+}
+
+// ExistsWhere : at least 1 record matches the filter , not cached (the cached repository must be an IWhere)
+func (c *CachedCrud[t]) ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error) {
+	repo, err := WhereOf(c.Repo)
+	if err != nil {
+		return false, err
+	}
+	return repo.ExistsWhere(f, baseExpression...)
+}
+
+// matchingIds : ids of the records matching the filter , read before an update / delete by filter to invalidate them
+// (a record matching only once they are read stays cached until the ttl expires)
+func (c *CachedCrud[t]) matchingIds(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) ([]string, error) {
+	records, err := c.Repo.GetWithFilterExpression(f, nil, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	return RecordIds(records), nil
+}
+
+// UpdateWhere : sets the columns of the patch on the records matching the filter , the matching records are invalidated even if the update fails
+// (the cached repository must be an IWhere)
+func (c *CachedCrud[t]) UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error) {
+	repo, err := WhereOf(c.Repo)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := c.matchingIds(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := repo.UpdateWhere(f, patch, baseExpression...)
+	c.invalidate(ids...)
+	return updated, err
+}
+
+// DeleteWhere : perma deletes the records matching the filter (the cached repository must be an IWhere)
+func (c *CachedCrud[t]) DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	repo, err := WhereOf(c.Repo)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := c.matchingIds(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := repo.DeleteWhere(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	c.invalidate(ids...)
+	return deleted, nil
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted records , its reads are not cached
+func (c *CachedCrud[t]) IncludeDeleted() ICrud[t] {
+	repo, err := c.softDelete()
+	if err != nil {
+		return c
+	}
+	v := c.view(repo.IncludeDeleted())
+	v.bypass = true
+	return v
+}
+
+// WithTransaction : transactional pointer , its reads are not cached (see CachedCrud)
+func (c *CachedCrud[t]) WithTransaction(tx ITransaction) ICrud[t] {
+	v := c.view(c.Repo.WithTransaction(tx))
+	v.bypass = true
+	return v
+}
+
+// WithContext : view of the repository whose calls (cache included) use the context
+func (c *CachedCrud[t]) WithContext(ctx context.Context) ICrud[t] {
+	v := c.view(c.Repo.WithContext(ctx))
+	v.ctx = ctx
+	return v
+}
@@ -0,0 +1,106 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baderkha/library/pkg/cache"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/store/repository/boltrepo"
+	bolt "go.etcd.io/bbolt"
+)
+
+// loadHookCrud : repository running a hook once after a record was read , simulates a write racing a cache miss
+type loadHookCrud struct {
+	repository.ICrud[savedViewTestEntity]
+	afterLoad func()
+}
+
+func (l *loadHookCrud) GetById(id string) (*savedViewTestEntity, error) {
+	rec, err := l.ICrud.GetById(id)
+	hook := l.afterLoad
+	l.afterLoad = nil
+	if hook != nil {
+		hook()
+	}
+	return rec, err
+}
+
+func newCachedTestRepo(t *testing.T) (*repository.CachedCrud[savedViewTestEntity], *loadHookCrud) {
+	store, err := bolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	repo := &loadHookCrud{ICrud: &partialUpdateCrud{ICrud: &boltrepo.CrudBolt[savedViewTestEntity]{DB: store}}}
+	return repository.NewCachedCrud[savedViewTestEntity](repo, &cache.LRU{}, time.Minute), repo
+}
+
+func TestCachedUpdateCachesTheStoredRecord(t *testing.T) {
+	cached, _ := newCachedTestRepo(t)
+	rec := &savedViewTestEntity{Name: "a"}
+	rec.ID = "rec1"
+	rec.AccountID = "acc1"
+	err := cached.Create(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cached.GetById("rec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partial := &savedViewTestEntity{Name: "b"}
+	partial.ID = "rec1"
+	err = cached.Update(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits := cached.Stats().Hits
+	if !cached.IsForAccountID("rec1", "acc1") {
+		t.Fatal("the cached record lost its owner")
+	}
+	got, err := cached.GetById("rec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "b" {
+		t.Fatalf("name = %q , want b", got.Name)
+	}
+	if cached.Stats().Hits != hits+2 {
+		t.Fatalf("hits = %d , want the updated record served from the cache", cached.Stats().Hits-hits)
+	}
+}
+
+func TestCachedMissDoesNotCacheAStaleLoad(t *testing.T) {
+	cached, repo := newCachedTestRepo(t)
+	rec := &savedViewTestEntity{Name: "a"}
+	rec.ID = "rec1"
+	err := cached.Create(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the update lands after the miss read the record but before it is cached
+	repo.afterLoad = func() {
+		err := cached.Update(&savedViewTestEntity{BaseOwned: rec.BaseOwned, Name: "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := cached.GetById("rec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "a" {
+		t.Fatalf("name = %q , want the record as loaded", got.Name)
+	}
+	got, err = cached.GetById("rec1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "b" {
+		t.Fatalf("name = %q , the stale load was cached", got.Name)
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import "github.com/baderkha/library/pkg/rql"
+
+// Faceted : paginated result with the value counts of the faceted columns
+type Faceted[t any] struct {
+	Paginated[t]
+	// Facets : in the order of the facet expression's columns
+	Facets []*FacetResult `json:"facets"`
+}
+
+// FacetResult : value counts of 1 column , most frequent values first
+type FacetResult struct {
+	Column string       `json:"column"`
+	Counts []FacetCount `json:"counts"`
+	// Stats : only set for numeric columns (see rql.Schema.IsNumericColumn)
+	Stats *FacetStats `json:"stats,omitempty"`
+}
+
+// FacetCount : number of matching records that have the value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// FacetStats : stats of a numeric column over the matching records
+type FacetStats struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Sum float64 `json:"sum"`
+	Avg float64 `json:"avg"`
+	// TotalValues : number of distinct values
+	TotalValues int64 `json:"total_values"`
+}
+
+// NewFaceted : faceted result with the facets ordered like the expression's columns
+func NewFaceted[t any](page *Paginated[t], facets *rql.FacetExpression, byColumn map[string]*FacetResult) *Faceted[t] {
+	res := &Faceted[t]{
+		Paginated: *page,
+		Facets:    make([]*FacetResult, 0, len(facets.Columns())),
+	}
+	for _, col := range facets.Columns() {
+		facet := byColumn[col]
+		if facet == nil {
+			facet = &FacetResult{Column: col}
+		}
+		if facet.Counts == nil {
+			facet.Counts = []FacetCount{}
+		}
+		res.Facets = append(res.Facets, facet)
+	}
+	return res
+}
@@ -0,0 +1,418 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/baderkha/library/pkg/err"
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+)
+
+const (
+	// FederatedDefaultLimit : number of merged hits returned if FederatedQuery.Limit is not set
+	FederatedDefaultLimit = 20
+	// FederatedDefaultSourceLimit : number of hits fetched per entity if FederatedSearch.SourceLimit is not set
+	FederatedDefaultSourceLimit = 10
+	// FederatedRankConstant : k of the reciprocal rank fusion score 1 / (k + rank)
+	FederatedRankConstant = 60
+)
+
+var (
+	ErrFederatedNoSources       = errors.New("FederatedSearch : at least 1 source is required")
+	ErrFederatedTermEmpty       = errors.New("FederatedSearch : search term cannot be empty")
+	ErrFederatedUnknownEntity   = err.Compose("FederatedSearch : entity `%s` is not a source")
+	ErrFederatedDuplicateEntity = err.Compose("FederatedSearch : entity `%s` has more than 1 source")
+	ErrFederatedNoSearchColumns = err.Compose("FederatedSearch : entity `%s` has no full text columns to search")
+	ErrFederatedNoQueryBy       = err.Compose("FederatedSearch : entity `%s` must set the fields to query by")
+	ErrFederatedSourceFailed    = err.Compose("FederatedSearch : entity `%s` : %w")
+)
+
+// IFederatedSource : an entity searched by a FederatedSearch
+type IFederatedSource interface {
+	// EntityName : name the hits of the entity are tagged with (ie `accounts`)
+	EntityName() string
+	// Search : the best matches of the term within the filter , most relevant first
+	Search(ctx context.Context, term string, f *rql.FilterExpression, limit int) (*FederatedSourceResult, error)
+}
+
+// iFederatedMultiSearch : source that can be batched with others in 1 typesense multi search request
+type iFederatedMultiSearch interface {
+	IFederatedSource
+	multiSearch(term string, f *rql.FilterExpression, limit int) (*federatedMultiSearch, error)
+}
+
+// federatedMultiSearch : 1 search of a typesense multi search request
+type federatedMultiSearch struct {
+	client typesenseRawClient
+	// params : search parameters including the collection
+	params map[string]string
+	decode func(raw json.RawMessage) (*FederatedSourceResult, error)
+}
+
+// FederatedSourceResult : hits of 1 entity
+type FederatedSourceResult struct {
+	Hits []*FederatedHit
+	// Found : number of matching records of the entity
+	Found int64
+}
+
+// FederatedHit : a record of any of the searched entities , use FederatedRecord to get the typed record
+type FederatedHit struct {
+	Entity string      `json:"entity"`
+	Record interface{} `json:"record"`
+	// Rank : 1 based rank of the hit within its entity
+	Rank int `json:"rank"`
+	// Score : reciprocal rank fusion score the entities are merged by (see FederatedRankConstant)
+	Score float64 `json:"score"`
+	// TextMatch : typesense text match score (only comparable within the same entity)
+	TextMatch  uint64            `json:"text_match,omitempty"`
+	Highlights []SearchHighlight `json:"highlights,omitempty"`
+}
+
+// FederatedRecord : the typed record of a hit , false if the hit is of another entity type
+//
+// Example :
+//			for _, hit := range res.Hits {
+//				if account, ok := repository.FederatedRecord[entity.AccountPublic](hit); ok {
+//					...
+//				}
+//			}
+func FederatedRecord[t any](hit *FederatedHit) (*t, bool) {
+	if hit == nil {
+		return nil, false
+	}
+	record, ok := hit.Record.(*t)
+	return record, ok
+}
+
+// FederatedResults : merged hits of all the searched entities , most relevant first
+type FederatedResults struct {
+	Hits []*FederatedHit `json:"hits"`
+	// Found : entity name => number of matching records
+	Found map[string]int64 `json:"found"`
+}
+
+// FederatedQuery : 1 search term over many entities
+type FederatedQuery struct {
+	Term string
+	// Filters : entity name => filter applied to the records of that entity only (ie account scoping)
+	Filters map[string]*rql.FilterExpression
+	// Entities : entities to search , all the sources if empty
+	Entities []string
+	// Limit : number of merged hits , defaults to FederatedDefaultLimit
+	Limit int
+}
+
+// FederatedSearch : global search over many repositories merged into 1 ranked list
+//
+// typesense sources are sent in 1 multi search request (they must be on the same typesense server) ,
+// sql sources are searched concurrently using their full text columns.
+// each entity ranks its own hits , the lists are then merged using reciprocal rank fusion
+// since the relevance scores of different engines / collections are not comparable
+//
+// Example :
+//			search := &repository.FederatedSearch{
+//				Sources: []repository.IFederatedSource{
+//					&repository.FederatedTypesense[entity.Project]{Entity: "projects", Repo: projects, Options: repository.SearchOptions{QueryBy: []string{"name"}}},
+//					&repository.FederatedGorm[entity.Document]{Entity: "documents", Repo: documents},
+//				},
+//			}
+//			res, err := search.Search(&repository.FederatedQuery{
+//				Term:    "roadmap",
+//				Filters: map[string]*rql.FilterExpression{"documents": rql.Where("account_id").Eq(accountID).MustBuild()},
+//			})
+type FederatedSearch struct {
+	Sources []IFederatedSource
+	// SourceLimit : hits fetched per entity , defaults to FederatedDefaultSourceLimit
+	SourceLimit int
+}
+
+func (s *FederatedSearch) sourceLimit() int {
+	if s.SourceLimit > 0 {
+		return s.SourceLimit
+	}
+	return FederatedDefaultSourceLimit
+}
+
+// sources : the sources of the query's entities in the order they were registered
+func (s *FederatedSearch) sources(q *FederatedQuery) ([]IFederatedSource, error) {
+	if len(s.Sources) == 0 {
+		return nil, ErrFederatedNoSources
+	}
+	byEntity := make(map[string]IFederatedSource, len(s.Sources))
+	for _, source := range s.Sources {
+		if _, exists := byEntity[source.EntityName()]; exists {
+			return nil, ErrFederatedDuplicateEntity(source.EntityName())
+		}
+		byEntity[source.EntityName()] = source
+	}
+	for name := range q.Filters {
+		if byEntity[name] == nil {
+			return nil, ErrFederatedUnknownEntity(name)
+		}
+	}
+	if len(q.Entities) == 0 {
+		return s.Sources, nil
+	}
+	selected := make(map[string]bool, len(q.Entities))
+	for _, name := range q.Entities {
+		if byEntity[name] == nil {
+			return nil, ErrFederatedUnknownEntity(name)
+		}
+		selected[name] = true
+	}
+	sources := make([]IFederatedSource, 0, len(selected))
+	for _, source := range s.Sources {
+		if selected[source.EntityName()] {
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+// Search : searches every entity of the query and merges the hits
+func (s *FederatedSearch) Search(q *FederatedQuery) (*FederatedResults, error) {
+	return s.SearchWithContext(context.Background(), q)
+}
+
+// SearchWithContext : same as Search , the searches are cancelled with the context
+func (s *FederatedSearch) SearchWithContext(ctx context.Context, q *FederatedQuery) (*FederatedResults, error) {
+	if q == nil || strings.TrimSpace(q.Term) == "" {
+		return nil, ErrFederatedTermEmpty
+	}
+	sources, err := s.sources(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		results = make([]*FederatedSourceResult, len(sources))
+		errs    = make([]error, len(sources))
+		wg      sync.WaitGroup
+
+		multi        []*federatedMultiSearch
+		multiIndexes []int
+	)
+	for i, source := range sources {
+		f := q.Filters[source.EntityName()]
+		if batched, ok := source.(iFederatedMultiSearch); ok {
+			search, err := batched.multiSearch(q.Term, f, s.sourceLimit())
+			if err != nil {
+				return nil, ErrFederatedSourceFailed(source.EntityName(), err)
+			}
+			multi = append(multi, search)
+			multiIndexes = append(multiIndexes, i)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, source IFederatedSource, f *rql.FilterExpression) {
+			defer wg.Done()
+			results[i], errs[i] = source.Search(ctx, q.Term, f, s.sourceLimit())
+		}(i, source, f)
+	}
+	if len(multi) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			multiResults, multiErrs := runFederatedMultiSearch(ctx, multi)
+			for j, i := range multiIndexes {
+				results[i], errs[i] = multiResults[j], multiErrs[j]
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, ErrFederatedSourceFailed(sources[i].EntityName(), err)
+		}
+	}
+	return mergeFederatedResults(sources, results, q.Limit), nil
+}
+
+// runFederatedMultiSearch : sends all the searches in 1 typesense multi search request ,
+// each search has its own result / error
+func runFederatedMultiSearch(ctx context.Context, searches []*federatedMultiSearch) ([]*FederatedSourceResult, []error) {
+	var (
+		results = make([]*FederatedSourceResult, len(searches))
+		errs    = make([]error, len(searches))
+		body    = struct {
+			Searches []map[string]string `json:"searches"`
+		}{}
+		res struct {
+			Results []json.RawMessage `json:"results"`
+		}
+	)
+	fail := func(err error) ([]*FederatedSourceResult, []error) {
+		for i := range errs {
+			errs[i] = err
+		}
+		return results, errs
+	}
+	for _, search := range searches {
+		body.Searches = append(body.Searches, search.params)
+	}
+	resp, err := searches[0].client.Req().
+		SetContext(ctx).
+		SetBody(body).
+		SetResult(&res).
+		Post("/multi_search")
+	if err != nil {
+		return fail(err)
+	}
+	if resp.IsError() {
+		return fail(fmt.Errorf("typesense : multi search failed with status %d : %s", resp.StatusCode(), resp.String()))
+	}
+	if len(res.Results) != len(searches) {
+		return fail(fmt.Errorf("typesense : multi search returned %d results for %d searches", len(res.Results), len(searches)))
+	}
+	for i, search := range searches {
+		var searchErr struct {
+			Code  int    `json:"code"`
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(res.Results[i], &searchErr) == nil && searchErr.Error != "" {
+			errs[i] = fmt.Errorf("typesense : search failed with status %d : %s", searchErr.Code, searchErr.Error)
+			continue
+		}
+		results[i], errs[i] = search.decode(res.Results[i])
+	}
+	return results, errs
+}
+
+// mergeFederatedResults : merges the ranked hits of each entity by their reciprocal rank fusion score ,
+// ties keep the order of the sources
+func mergeFederatedResults(sources []IFederatedSource, results []*FederatedSourceResult, limit int) *FederatedResults {
+	merged := &FederatedResults{
+		Hits:  []*FederatedHit{},
+		Found: make(map[string]int64, len(sources)),
+	}
+	if limit <= 0 {
+		limit = FederatedDefaultLimit
+	}
+	for i, source := range sources {
+		if results[i] == nil {
+			merged.Found[source.EntityName()] = 0
+			continue
+		}
+		merged.Found[source.EntityName()] = results[i].Found
+		for rank, hit := range results[i].Hits {
+			hit.Entity = source.EntityName()
+			hit.Rank = rank + 1
+			hit.Score = 1 / float64(FederatedRankConstant+hit.Rank)
+			merged.Hits = append(merged.Hits, hit)
+		}
+	}
+	sort.SliceStable(merged.Hits, func(i, j int) bool {
+		return merged.Hits[i].Score > merged.Hits[j].Score
+	})
+	if len(merged.Hits) > limit {
+		merged.Hits = merged.Hits[:limit]
+	}
+	return merged
+}
+
+// federatedFirstPage : the first page of the source limit
+func federatedFirstPage(limit int) *rql.PaginationExpression {
+	return rql.PaginationOptions{DefaultSize: limit, Count: rql.CountExact}.First()
+}
+
+// federatedSearchHits : search hits as federated hits
+func federatedSearchHits[t any](hits []*SearchHit[t]) []*FederatedHit {
+	res := make([]*FederatedHit, 0, len(hits))
+	for _, hit := range hits {
+		res = append(res, &FederatedHit{
+			Record:     hit.Record,
+			TextMatch:  hit.TextMatch,
+			Highlights: hit.Highlights,
+		})
+	}
+	return res
+}
+
+var _ iFederatedMultiSearch = &FederatedTypesense[entity.SavedView]{}
+
+// FederatedTypesense : a typesense collection searched by a FederatedSearch
+type FederatedTypesense[t entity.Model] struct {
+	Entity string
+	Repo   *CrudTypeSense[t]
+	// Options : QueryBy is required , the Query is replaced by the federated search term
+	Options SearchOptions
+}
+
+// EntityName : name the hits of the entity are tagged with
+func (s *FederatedTypesense[t]) EntityName() string {
+	return s.Entity
+}
+
+func (s *FederatedTypesense[t]) options(term string) (*SearchOptions, error) {
+	if len(s.Options.QueryBy) == 0 {
+		return nil, ErrFederatedNoQueryBy(s.Entity)
+	}
+	opts := s.Options
+	opts.Query = term
+	return &opts, nil
+}
+
+// Search : the best matches of the term within the filter using its own typesense search request
+func (s *FederatedTypesense[t]) Search(ctx context.Context, term string, f *rql.FilterExpression, limit int) (*FederatedSourceResult, error) {
+	opts, err := s.options(term)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.Repo.withContext(ctx).SearchWithFilterExpression(f, federatedFirstPage(limit), nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &FederatedSourceResult{
+		Hits:  federatedSearchHits(res.Records),
+		Found: res.TotalRecords,
+	}, nil
+}
+
+func (s *FederatedTypesense[t]) multiSearch(term string, f *rql.FilterExpression, limit int) (*federatedMultiSearch, error) {
+	opts, err := s.options(term)
+	if err != nil {
+		return nil, err
+	}
+	err = opts.Validate(rql.GetSchemaFromTaggedEntity(s.Repo.Model(), "db"))
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.Repo.paginatedParams(f, federatedFirstPage(limit), nil)
+	if err != nil {
+		return nil, err
+	}
+	out.AddSearchTerm(opts.Query).AddQueryBy(strings.Join(opts.QueryBy, ","))
+
+	client, colName, err := s.Repo.rawClient()
+	if err != nil {
+		return nil, err
+	}
+	params, err := rawSearchParams(out, opts.typesenseParams())
+	if err != nil {
+		return nil, err
+	}
+	params["collection"] = colName
+	return &federatedMultiSearch{
+		client: client,
+		params: params,
+		decode: func(raw json.RawMessage) (*FederatedSourceResult, error) {
+			var res typesenseSearchResult[t]
+			err := json.Unmarshal(raw, &res)
+			if err != nil {
+				return nil, err
+			}
+			return &FederatedSourceResult{
+				Hits:  federatedSearchHits(res.searchHits()),
+				Found: int64(res.Found),
+			}, nil
+		},
+	}, nil
+}
@@ -1,15 +1,74 @@
 package repository
 
-import "github.com/baderkha/library/pkg/rql"
+import (
+	"context"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/repository/internal/dbtag"
+)
+
+const (
+	// AutoIncrementTag : `auto_increment:"1"` on a signed integer db tagged field lets the persistence layer assign it on create ,
+	// sqlrepo.CrudSQL leaves the column out of its inserts / updates (the database must auto increment it) and boltrepo.CrudBolt sets it
+	// to the next sequence of the bucket (gorm models use the gorm `autoIncrement` tag)
+	AutoIncrementTag = dbtag.AutoIncrementTag
+)
 
 // Paginated : paginated result
 type Paginated[t any] struct {
-	CurrentPage  int64 `json:"current_page"`
-	CurrentSize  int64 `json:"current_size"`
-	Records      []*t  `json:"records"`
+	// CurrentPage : page number in the base of the pagination expression
+	CurrentPage int64 `json:"current_page"`
+	CurrentSize int64 `json:"current_size"`
+	Records     []*t  `json:"records"`
+	// TotalRecords : -1 if the count mode is rql.CountNone
 	TotalRecords int64 `json:"total_records"`
-	TotalPages   int64 `json:"total_pages"`
-	IsFinalPage  bool  `json:"is_final_page"`
+	// TotalPages : -1 if the count mode is rql.CountNone
+	TotalPages  int64 `json:"total_pages"`
+	IsFinalPage bool  `json:"is_final_page"`
+	HasNextPage bool  `json:"has_next_page"`
+	// CountMode : how the totals were computed (an estimate can fall back to an exact count)
+	CountMode rql.CountMode        `json:"count_mode"`
+	Links     *rql.PaginationLinks `json:"links,omitempty"`
+
+	pagination *rql.PaginationExpression
+}
+
+// NewPaginated : paginated result for a page of records , count < 0 means the total is unknown ,
+// hasNext is only used if the count is not exact
+func NewPaginated[t any](p *rql.PaginationExpression, records []*t, count int64, mode rql.CountMode, hasNext bool) *Paginated[t] {
+	res := &Paginated[t]{
+		CurrentPage:  int64(p.Page()),
+		CurrentSize:  int64(p.Size()),
+		Records:      records,
+		TotalRecords: -1,
+		TotalPages:   -1,
+		HasNextPage:  hasNext,
+		CountMode:    mode,
+		pagination:   p,
+	}
+	if res.Records == nil {
+		res.Records = []*t{}
+	}
+	if count >= 0 {
+		size := int64(p.Size())
+		res.TotalRecords = count
+		if size <= 0 {
+			// no page can be computed without a size , the total pages stay unknown
+			return res
+		}
+		res.TotalPages = (count + size - 1) / size
+		if mode == rql.CountExact {
+			res.HasNextPage = int64(p.Index()+1) < res.TotalPages
+		}
+	}
+	res.IsFinalPage = !res.HasNextPage
+	return res
+}
+
+// AddLinks : sets the first / prev / next / last navigation links using the builder (nil builder is a no op)
+func (p *Paginated[t]) AddLinks(b *rql.PaginationLinkBuilder) *Paginated[t] {
+	p.Links = b.Build(p.pagination, p.TotalPages, p.HasNextPage)
+	return p
 }
 
 // ICrud : crud interface if your repo is read / write
@@ -18,6 +77,8 @@ type ICrud[t any] interface {
 	IWriteOnly[t]
 	// WithTransaction : transactional pointer (make sure all your repos use the same persistence layer)
 	WithTransaction(tx ITransaction) ICrud[t]
+	// WithContext : view of the repository whose calls use the context (cancellation , deadlines , tracing)
+	WithContext(ctx context.Context) ICrud[t]
 }
 
 // IReadOnly : repo that only does read operations
@@ -34,6 +95,8 @@ type IReadOnly[t any] interface {
 	GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error)
 	// GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package
 	GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *Paginated[t], err error)
+	// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns over all the matching records
+	GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *Faceted[t], err error)
 }
 
 // IWriteOnly : repo that only does write operations
@@ -49,3 +112,8 @@ type IWriteOnly[t any] interface {
 	// DeleteByIds : perma delet by many ids
 	DeleteByIds(id []string) error
 }
+
+// IsEmptyFilter : the expression does not filter anything
+func IsEmptyFilter(f *rql.FilterExpression) bool {
+	return f == nil || (f.Column == "" && len(f.Properties) == 0)
+}
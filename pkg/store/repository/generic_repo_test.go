@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/baderkha/library/pkg/rql"
+)
+
+func TestNewPaginated(t *testing.T) {
+	p, err := rql.DefaultPaginationOptions.New(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := NewPaginated[int](p, nil, 25, rql.CountExact, false)
+	if res.TotalPages != 3 || !res.HasNextPage || res.IsFinalPage || res.Records == nil {
+		t.Fatalf("res = %+v", res)
+	}
+
+	// a zero pagination expression has no size , the total pages cannot be computed
+	res = NewPaginated[int](&rql.PaginationExpression{}, nil, 25, rql.CountExact, false)
+	if res.TotalRecords != 25 || res.TotalPages != -1 {
+		t.Fatalf("res = %+v", res)
+	}
+}
@@ -2,22 +2,36 @@ package repository
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"math"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/baderkha/library/pkg/conditional"
 	"github.com/baderkha/library/pkg/ptr"
 	"github.com/baderkha/library/pkg/rql"
 	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository/internal/dbtag"
 	"github.com/baderkha/typesense"
 	"github.com/wlredeye/jsonlines"
 )
 
+var _ ISearch[entity.SavedView] = &CrudTypeSense[entity.SavedView]{}
+var _ ISoftDelete[entity.SavedView] = &CrudTypeSense[entity.SavedView]{}
+var _ IWhere[entity.SavedView] = &CrudTypeSense[entity.SavedView]{}
+var _ ISchemaView[entity.SavedView] = &CrudTypeSense[entity.SavedView]{}
+
 type CrudTypeSense[t entity.Model] struct {
 	client typesense.IClient[t]
 	parser rql.ITypeSenseFilterParser
 	sorter rql.ITypeSenseSortParser
+	// ctx : context of the calls (see WithContext)
+	ctx context.Context
+	// includeDeleted : reads return soft deleted documents (see IncludeDeleted)
+	includeDeleted bool
+	// schemaView : schema of the filter / sort / facet / search expressions (see WithSchema)
+	schemaView *rql.Schema
 }
 
 func (c *CrudTypeSense[t]) Model() t {
@@ -25,8 +39,17 @@ func (c *CrudTypeSense[t]) Model() t {
 	return m
 }
 
+// filterSchema : schema of the filter / sort / facet / search expressions , the full schema unless restricted (see WithSchema)
+func (c *CrudTypeSense[t]) filterSchema() *rql.Schema {
+	if c.schemaView != nil {
+		return c.schemaView
+	}
+	return rql.GetSchemaFromTaggedEntity(ptr.EmptyNonPtr[t](), "db")
+}
+
 func (c *CrudTypeSense[t]) Document() typesense.IDocumentClient[t] {
 	return c.
+		client.
 		Document().
 		WithCollectionName(
 			c.Model().TableName(),
@@ -35,6 +58,7 @@ func (c *CrudTypeSense[t]) Document() typesense.IDocumentClient[t] {
 
 func (c *CrudTypeSense[t]) Search() typesense.ISearchClient[t] {
 	return c.
+		client.
 		Search().
 		WithCollectionName(
 			c.Model().TableName(),
@@ -46,8 +70,29 @@ func (c *CrudTypeSense[t]) WithTransaction(tx ITransaction) ICrud[t] {
 	panic("transactons are not supported with typesense for now")
 }
 
+// WithContext : view of the repository whose calls use the context ,
+// searches are cancelled with the context , the other calls are only started if the context is not done
+// (the typesense document client does not take a context)
+func (c *CrudTypeSense[t]) WithContext(ctx context.Context) ICrud[t] {
+	return c.withContext(ctx)
+}
+
+func (c *CrudTypeSense[t]) withContext(ctx context.Context) *CrudTypeSense[t] {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
+
+// ctxErr : error of the context if it is done
+func (c *CrudTypeSense[t]) ctxErr() error {
+	if c.ctx == nil {
+		return nil
+	}
+	return c.ctx.Err()
+}
+
 func (c *CrudTypeSense[t]) IsForAccountID(id string, accountID string) bool {
-	res, err := c.Document().GetById(id)
+	res, err := c.GetById(id)
 	if err != nil {
 		return false
 	}
@@ -55,7 +100,7 @@ func (c *CrudTypeSense[t]) IsForAccountID(id string, accountID string) bool {
 }
 
 func (c *CrudTypeSense[t]) DoesIDExist(id string) bool {
-	res, err := c.Document().GetById(id)
+	res, err := c.GetById(id)
 	if err != nil {
 		return false
 	}
@@ -64,16 +109,35 @@ func (c *CrudTypeSense[t]) DoesIDExist(id string) bool {
 
 // GetById : get 1 record by id if not found should return err
 func (c *CrudTypeSense[t]) GetById(id string) (*t, error) {
+	err := c.ctxErr()
+	if err != nil {
+		return nil, err
+	}
 	res, err := c.Document().GetById(id)
 	if err != nil {
 		return nil, err
 	}
+	if c.isHidden(res) {
+		return nil, ErrSoftDeleteNotFound
+	}
 	return res, nil
 }
 
 // GetAll : get all the records (db dump)
 func (c *CrudTypeSense[t]) GetAll() ([]*t, error) {
-	all, err := c.Document().ExportAll()
+	err := c.ctxErr()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		all      []byte
+		filterBy = c.notDeletedFilter()
+	)
+	if filterBy != "" {
+		all, err = c.Document().ExportAllWithQuery(filterBy)
+	} else {
+		all, err = c.Document().ExportAll()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -92,72 +156,165 @@ func (c *CrudTypeSense[t]) fromJSONLines(all []byte) ([]*t, error) {
 	return res, err
 }
 
+// parseFilters : parses the filter and the base expression and joins them into 1 search parameter ,
+// soft deleted documents are excluded (the filter is parsed against the schema view and the base expression against the full schema)
+func (c *CrudTypeSense[t]) parseFilters(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (*typesense.SearchParameters, error) {
+	b := ptr.Empty[rql.FilterExpression]()
+	if len(baseExpression) > 0 {
+		b = ptr.Default(baseExpression[0])
+	}
+
+	out, err := c.parser.Parse(ptr.Default(f), c.filterSchema())
+	if err != nil {
+		return nil, err
+	}
+	out2, err := c.parser.Parse(b, rql.GetSchemaFromTaggedEntity(ptr.EmptyNonPtr[t](), "db"))
+	if err != nil {
+		return nil, err
+	}
+	if out.QueryBy == "" && out2.QueryBy != "" {
+		out.AddQueryBy(out2.QueryBy).AddSearchTerm(out2.SearchTerm)
+	}
+	return out.AddFilterBy(rql.JoinTypesenseFilters(out.FilterBy, out2.FilterBy, c.notDeletedFilter())), nil
+}
+
 // GetWithFilterExpression : filter + sort a result using the rql package
 func (c *CrudTypeSense[t]) GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error) {
-	var (
-		schema = rql.GetSchemaFromTaggedEntity(ptr.EmptyNonPtr[t](), "db")
-		b      = ptr.Empty[rql.FilterExpression]()
-	)
-
-	f = ptr.Default(f)
-	out, err := c.parser.Parse(f, schema)
+	err = c.ctxErr()
 	if err != nil {
 		return nil, err
 	}
-	out2, err := c.parser.Parse(b, schema)
+	out, err := c.parseFilters(f, baseExpression...)
 	if err != nil {
 		return nil, err
 	}
-	if len(baseExpression) > 0 {
-		b = ptr.Default(baseExpression[0])
+	all, err := c.Document().ExportAllWithQuery(out.FilterBy)
+	if err != nil {
+		return nil, err
 	}
-	all, err := c.Document().ExportAllWithQuery(strings.Join([]string{out.FilterBy, out2.FilterBy}, "&&"))
 	return c.fromJSONLines(all)
 }
 
+// paginatedParams : search parameters for a filtered , sorted page
+func (c *CrudTypeSense[t]) paginatedParams(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (*typesense.SearchParameters, error) {
+	out, err := c.parseFilters(f, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	// typesense pages are 1 based
+	out = out.AddPage(p.Index() + 1).AddPerPage(p.Size())
+	if s != nil {
+		sortBy, err := c.sorter.Parse(s, c.filterSchema())
+		if err != nil {
+			return nil, err
+		}
+		out = out.AddSortBy(*sortBy)
+	}
+	return out, nil
+}
+
 // GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package
+//
+// typesense always returns the number of matching documents so the total is always exact regardless of the count mode ,
+// a nil pagination expression is the first page of rql.DefaultPaginationOptions
 func (c *CrudTypeSense[t]) GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *Paginated[t], err error) {
-	p = ptr.Default(p)
-	f = ptr.Default(f)
+	if p == nil {
+		p = rql.DefaultPaginationOptions.First()
+	}
+	out, err := c.paginatedParams(f, p, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+
+	var res typesenseSearchResult[t]
+	err = c.searchRaw(out, nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPaginated(p, res.documents(), int64(res.Found), rql.CountExact, false), nil
+}
+
+// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns over all the matching records
+//
+// the columns are sent as the typesense `facet_by` , they must be facet fields of the collection (see the `tsense_facet` tag)
+func (c *CrudTypeSense[t]) GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *Faceted[t], err error) {
 	var (
-		schema     = rql.GetSchemaFromTaggedEntity(ptr.EmptyNonPtr[t](), "db")
-		b          = ptr.Empty[rql.FilterExpression]()
-		res        = ptr.Default(data)
-		page   int = conditional.Ternary(p != nil, int(p.Page()), 0)
-		limit  int = conditional.Ternary(p != nil, int(p.Size()), 10)
+		schema   = c.filterSchema()
+		res      typesenseSearchResult[t]
+		byColumn = make(map[string]*FacetResult)
 	)
-
-	out, err := c.parser.Parse(f, schema)
+	if facets == nil {
+		return nil, rql.ErrFacetEmpty
+	}
+	err = facets.Validate(schema)
 	if err != nil {
 		return nil, err
 	}
-	out2, err := c.parser.Parse(b, schema)
+	if p == nil {
+		p = rql.DefaultPaginationOptions.First()
+	}
+	out, err := c.paginatedParams(f, p, s, baseExpression...)
 	if err != nil {
 		return nil, err
 	}
-	if len(baseExpression) > 0 {
-		b = ptr.Default(baseExpression[0])
+	err = c.searchRaw(out, map[string]string{
+		"facet_by":         strings.Join(facets.Columns(), ","),
+		"max_facet_values": strconv.Itoa(facets.GetMaxValues()),
+	}, &res)
+	if err != nil {
+		return nil, err
 	}
-	out = out.AddPage(page).AddPerPage(limit).AddFilterBy(strings.Join([]string{out.FilterBy, out2.FilterBy}, "&&"))
 
-	all, err := c.Search().Search(out)
+	for _, facet := range res.FacetCounts {
+		byColumn[facet.FieldName] = facet.toFacetResult(schema.IsNumericColumn(facet.FieldName))
+	}
+	return NewFaceted(NewPaginated(p, res.documents(), int64(res.Found), rql.CountExact, false), facets, byColumn), nil
+}
 
+// SearchWithFilterExpression : filter + sort + paginate a full text search , each record comes with its highlights and text match score
+func (c *CrudTypeSense[t]) SearchWithFilterExpression(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, opts *SearchOptions, baseExpression ...*rql.FilterExpression) (*SearchResults[t], error) {
+	var (
+		schema = c.filterSchema()
+		res    typesenseSearchResult[t]
+	)
+	err := opts.Validate(schema)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = rql.DefaultPaginationOptions.First()
+	}
+	out, err := c.paginatedParams(f, p, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.Query != "" {
+		out.AddSearchTerm(opts.Query)
+	}
+	if opts != nil && len(opts.QueryBy) > 0 {
+		out.AddQueryBy(strings.Join(opts.QueryBy, ","))
+	}
+	err = c.searchRaw(out, opts.typesenseParams(), &res)
 	if err != nil {
 		return nil, err
 	}
 
-	res.CurrentPage = int64(page)
-	res.CurrentSize = int64(limit)
-	res.TotalRecords = int64(all.OutOf)
-	res.TotalPages = int64(math.Ceil(float64(all.OutOf / limit)))
-	res.Records = all.GetDocuments()
-
-	return res, nil
+	return &SearchResults[t]{
+		Paginated:    *NewPaginated(p, res.searchHits(), int64(res.Found), rql.CountExact, false),
+		OutOf:        int64(res.OutOf),
+		SearchTimeMs: int64(res.SearchTimeMs),
+	}, nil
 }
 
 // Create : create one
 func (c *CrudTypeSense[t]) Create(mdl *t) error {
-	err := c.Document().Index(mdl)
+	err := c.ctxErr()
+	if err != nil {
+		return err
+	}
+	InitVersion(mdl)
+	err = c.Document().Index(mdl)
 	if err != nil {
 		return err
 	}
@@ -166,7 +323,14 @@ func (c *CrudTypeSense[t]) Create(mdl *t) error {
 
 // BulkCreate : create many
 func (c *CrudTypeSense[t]) BulkCreate(mdl []*t) error {
-	err := c.Document().IndexMany(mdl, typesense.DocumentActionUpsert)
+	err := c.ctxErr()
+	if err != nil {
+		return err
+	}
+	for _, m := range mdl {
+		InitVersion(m)
+	}
+	err = c.Document().IndexMany(mdl, typesense.DocumentActionUpsert)
 	if err != nil {
 		return err
 	}
@@ -174,17 +338,43 @@ func (c *CrudTypeSense[t]) BulkCreate(mdl []*t) error {
 }
 
 // Update : update model
+//
+// versioned models (see entity.BaseVersioned) are compared with the stored document before the update ,
+// typesense has no conditional writes so 2 updates racing between the read and the write can still both succeed
 func (c *CrudTypeSense[t]) Update(mdl *t) error {
-	err := c.Document().Update(mdl, (*mdl).GetID())
+	err := c.ctxErr()
 	if err != nil {
 		return err
 	}
+	versioned, _ := VersionOf(mdl)
+	if versioned == nil {
+		return c.Document().Update(mdl, (*mdl).GetID())
+	}
+	version := versioned.GetVersion()
+	stored, err := c.Document().GetById((*mdl).GetID())
+	if err != nil {
+		return err
+	}
+	storedVersion, _ := VersionOf(stored)
+	if storedVersion == nil || storedVersion.GetVersion() != version {
+		return &VersionConflictError{Table: c.Model().TableName(), ID: (*mdl).GetID(), Version: version}
+	}
+	versioned.SetVersion(version + 1)
+	err = c.Document().Update(mdl, (*mdl).GetID())
+	if err != nil {
+		versioned.SetVersion(version)
+		return err
+	}
 	return nil
 }
 
 // DeleteById : perma delete model by id
 func (c *CrudTypeSense[t]) DeleteById(id string) error {
-	err := c.Document().DeleteById(id)
+	err := c.ctxErr()
+	if err != nil {
+		return err
+	}
+	err = c.Document().DeleteById(id)
 	if err != nil {
 		return err
 	}
@@ -193,12 +383,210 @@ func (c *CrudTypeSense[t]) DeleteById(id string) error {
 
 // DeleteByIds : perma delet by many ids
 func (c *CrudTypeSense[t]) DeleteByIds(id []string) error {
+	err := c.ctxErr()
+	if err != nil {
+		return err
+	}
 	if len(id) == 0 {
 		return nil
 	}
-	err := c.Document().DeleteManyWithQuery(fmt.Sprintf("%s:[%s]", c.Model().GetIDKey(), strings.Join(id, ",")))
+	err = c.Document().DeleteManyWithQuery(fmt.Sprintf("%s:[%s]", c.Model().GetIDKey(), strings.Join(id, ",")))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// softDeleteColumns : names of the is_deleted / updated_at fields , empty if the model cannot be soft deleted
+func (c *CrudTypeSense[t]) softDeleteColumns() (isDeleted string, updatedAt string) {
+	return SoftDeleteColumns(rql.GetSchemaFromTaggedEntity(ptr.EmptyNonPtr[t](), "db"))
+}
+
+// notDeletedFilter : filter_by excluding the soft deleted documents , empty for an IncludeDeleted view or a model that cannot be soft deleted
+func (c *CrudTypeSense[t]) notDeletedFilter() string {
+	isDeleted, _ := c.softDeleteColumns()
+	if c.includeDeleted || isDeleted == "" {
+		return ""
+	}
+	return isDeleted + ":=false"
+}
+
+// isHidden : the document is soft deleted and the view excludes soft deleted documents
+func (c *CrudTypeSense[t]) isHidden(doc *t) bool {
+	deletable, ok := any(doc).(entity.SoftDeletable)
+	return ok && !c.includeDeleted && deletable.GetIsDeleted()
+}
+
+// setDeleted : soft deletes / restores the documents one by one (typesense cannot update by query) , returns the number of documents changed ,
+// missing documents and documents already in the state are skipped
+func (c *CrudTypeSense[t]) setDeleted(ids []string, isDeleted bool) (int64, error) {
+	err := c.ctxErr()
+	if err != nil {
+		return 0, err
+	}
+	colDeleted, _ := c.softDeleteColumns()
+	if colDeleted == "" {
+		return 0, ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	var updated int64
+	for _, id := range ids {
+		doc, err := c.Document().GetById(id)
+		if err != nil {
+			if !c.Document().IsExistById(id) {
+				continue
+			}
+			return updated, err
+		}
+		deletable, ok := any(doc).(entity.SoftDeletable)
+		if !ok {
+			return updated, ErrSoftDeleteUnsupported(c.Model().TableName())
+		}
+		if deletable.GetIsDeleted() == isDeleted {
+			continue
+		}
+		deletable.SetDeleted(isDeleted)
+		versioned, _ := VersionOf(doc)
+		if versioned != nil {
+			versioned.SetVersion(versioned.GetVersion() + 1)
+		}
+		err = c.Document().Update(doc, id)
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// SoftDeleteById : mark the document as deleted
+func (c *CrudTypeSense[t]) SoftDeleteById(id string) error {
+	_, err := c.setDeleted([]string{id}, true)
+	return err
+}
+
+// SoftDeleteByIds : mark many documents as deleted
+func (c *CrudTypeSense[t]) SoftDeleteByIds(ids []string) error {
+	_, err := c.setDeleted(ids, true)
+	return err
+}
+
+// Restore : unmark a soft deleted document , ErrSoftDeleteNotFound if there is no soft deleted document with the id
+func (c *CrudTypeSense[t]) Restore(id string) error {
+	restored, err := c.setDeleted([]string{id}, false)
 	if err != nil {
 		return err
 	}
+	if restored == 0 {
+		return ErrSoftDeleteNotFound
+	}
 	return nil
 }
+
+// PurgeDeletedOlderThan : perma delete the documents soft deleted more than age ago , returns the number of documents purged
+func (c *CrudTypeSense[t]) PurgeDeletedOlderThan(age time.Duration) (int64, error) {
+	err := c.ctxErr()
+	if err != nil {
+		return 0, err
+	}
+	colDeleted, colUpdated := c.softDeleteColumns()
+	if colDeleted == "" || colUpdated == "" {
+		return 0, ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	if age < 0 {
+		return 0, ErrSoftDeleteNegativeAge
+	}
+	// timestamps are indexed as unix seconds (see types.Timestamp)
+	return c.deleteRaw(fmt.Sprintf("%s:=true && %s:<%d", colDeleted, colUpdated, time.Now().Add(-age).Unix()))
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted documents
+func (c *CrudTypeSense[t]) IncludeDeleted() ICrud[t] {
+	cp := *c
+	cp.includeDeleted = true
+	return &cp
+}
+
+// WithSchema : view of the repository whose filter / sort / facet / search expressions are parsed and validated against the schema
+// (ie rql.Schema.ForRole) , the base expressions keep using the full schema
+func (c *CrudTypeSense[t]) WithSchema(schema *rql.Schema) ICrud[t] {
+	cp := *c
+	cp.schemaView = schema
+	return &cp
+}
+
+// CountWhere : number of documents matching the filter (a search returning no hits)
+func (c *CrudTypeSense[t]) CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	var res typesenseSearchResult[t]
+	out, err := c.parseFilters(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	err = c.searchRaw(out, map[string]string{"per_page": "0"}, &res)
+	if err != nil {
+		return 0, err
+	}
+	return int64(res.Found), nil
+}
+
+// ExistsWhere : at least 1 document matches the filter
+func (c *CrudTypeSense[t]) ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error) {
+	count, err := c.CountWhere(f, baseExpression...)
+	return count > 0, err
+}
+
+// filterByOnly : the filter_by of the filter + base expression , full text searches cannot select documents to update / delete
+func (c *CrudTypeSense[t]) filterByOnly(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (string, error) {
+	err := RequireFilter(f, baseExpression...)
+	if err != nil {
+		return "", err
+	}
+	out, err := c.parseFilters(f, baseExpression...)
+	if err != nil {
+		return "", err
+	}
+	if out.QueryBy != "" {
+		return "", ErrWhereFullTextSearch
+	}
+	return out.FilterBy, nil
+}
+
+// UpdateWhere : patches the documents matching the filter , returns the number of documents updated
+//
+// time values are indexed as unix seconds (see types.Timestamp) . typesense cannot increment a field by query so versions are left as is
+func (c *CrudTypeSense[t]) UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := c.ctxErr()
+	if err != nil {
+		return 0, err
+	}
+	schema := rql.GetSchemaFromTaggedEntity(ptr.EmptyNonPtr[t](), "db")
+	updates, err := PatchColumns(schema, patch, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	filterBy, err := c.filterByOnly(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	doc := make(map[string]interface{}, len(updates))
+	for col, value := range updates {
+		rv := reflect.ValueOf(value)
+		if value != nil && dbtag.IsTimeType(rv.Type()) {
+			value = rv.Convert(dbtag.TimeType).Interface().(time.Time).Unix()
+		}
+		doc[jsonColumn(schema, col)] = value
+	}
+	return c.updateRaw(filterBy, doc)
+}
+
+// DeleteWhere : deletes the documents matching the filter , returns the number of documents deleted
+func (c *CrudTypeSense[t]) DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := c.ctxErr()
+	if err != nil {
+		return 0, err
+	}
+	filterBy, err := c.filterByOnly(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	return c.deleteRaw(filterBy)
+}
@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/baderkha/typesense"
+	"github.com/go-resty/resty/v2"
+)
+
+var (
+	ErrTypesenseRawSearchUnsupported = errors.New("typesense : the search client does not expose raw requests")
+)
+
+// typesenseRawClient : the parts of the typesense search client needed to send parameters it does not support (facets , highlights ...)
+type typesenseRawClient interface {
+	Req() *resty.Request
+	GetAliasCached(aliasName string) (doesExist bool, alias typesense.Alias)
+}
+
+// typesenseSearchResult : search response , the typesense client does not decode facets and highlights
+type typesenseSearchResult[t any] struct {
+	Found        int                   `json:"found"`
+	OutOf        int                   `json:"out_of"`
+	SearchTimeMs int                   `json:"search_time_ms"`
+	Hits         []typesenseHit[t]     `json:"hits"`
+	FacetCounts  []typesenseFacetCount `json:"facet_counts"`
+}
+
+func (r *typesenseSearchResult[t]) documents() []*t {
+	docs := make([]*t, 0, len(r.Hits))
+	for i := range r.Hits {
+		docs = append(docs, &r.Hits[i].Document)
+	}
+	return docs
+}
+
+func (r *typesenseSearchResult[t]) searchHits() []*SearchHit[t] {
+	hits := make([]*SearchHit[t], 0, len(r.Hits))
+	for i := range r.Hits {
+		hit := &SearchHit[t]{
+			Record:     &r.Hits[i].Document,
+			Highlights: make([]SearchHighlight, 0, len(r.Hits[i].Highlights)),
+			TextMatch:  r.Hits[i].TextMatch,
+		}
+		for _, highlight := range r.Hits[i].Highlights {
+			hit.Highlights = append(hit.Highlights, highlight.toSearchHighlight())
+		}
+		hits = append(hits, hit)
+	}
+	return hits
+}
+
+type typesenseHit[t any] struct {
+	Document   t                    `json:"document"`
+	Highlights []typesenseHighlight `json:"highlights"`
+	TextMatch  uint64               `json:"text_match"`
+}
+
+// typesenseHighlight : snippet / value are set for string fields , snippets / values for string array fields
+// (matched tokens are nested per element for arrays)
+type typesenseHighlight struct {
+	Field         string          `json:"field"`
+	Snippet       string          `json:"snippet"`
+	Snippets      []string        `json:"snippets"`
+	Value         string          `json:"value"`
+	Values        []string        `json:"values"`
+	MatchedTokens json.RawMessage `json:"matched_tokens"`
+}
+
+func (h *typesenseHighlight) toSearchHighlight() SearchHighlight {
+	var (
+		res = SearchHighlight{
+			Field:    h.Field,
+			Snippet:  h.Snippet,
+			Snippets: h.Snippets,
+			Value:    h.Value,
+			Values:   h.Values,
+		}
+		flat   []string
+		nested [][]string
+	)
+	if json.Unmarshal(h.MatchedTokens, &flat) == nil {
+		res.MatchedTokens = flat
+	} else if json.Unmarshal(h.MatchedTokens, &nested) == nil {
+		for _, tokens := range nested {
+			res.MatchedTokens = append(res.MatchedTokens, tokens...)
+		}
+	}
+	return res
+}
+
+type typesenseFacetCount struct {
+	FieldName string `json:"field_name"`
+	Counts    []struct {
+		Count int64  `json:"count"`
+		Value string `json:"value"`
+	} `json:"counts"`
+	Stats struct {
+		Min         *float64 `json:"min"`
+		Max         *float64 `json:"max"`
+		Sum         *float64 `json:"sum"`
+		Avg         *float64 `json:"avg"`
+		TotalValues int64    `json:"total_values"`
+	} `json:"stats"`
+}
+
+func (f *typesenseFacetCount) toFacetResult(isNumeric bool) *FacetResult {
+	res := &FacetResult{
+		Column: f.FieldName,
+		Counts: make([]FacetCount, 0, len(f.Counts)),
+	}
+	for _, count := range f.Counts {
+		res.Counts = append(res.Counts, FacetCount{Value: count.Value, Count: count.Count})
+	}
+	if isNumeric && f.Stats.Min != nil {
+		res.Stats = &FacetStats{
+			Min:         valueOrZero(f.Stats.Min),
+			Max:         valueOrZero(f.Stats.Max),
+			Sum:         valueOrZero(f.Stats.Sum),
+			Avg:         valueOrZero(f.Stats.Avg),
+			TotalValues: f.Stats.TotalValues,
+		}
+	}
+	return res
+}
+
+func valueOrZero(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// rawClient : search client able to send raw requests and the collection name it resolves the model's alias to
+func (c *CrudTypeSense[t]) rawClient() (typesenseRawClient, string, error) {
+	client, ok := c.Search().(typesenseRawClient)
+	if !ok {
+		return nil, "", ErrTypesenseRawSearchUnsupported
+	}
+	colName := c.Model().TableName()
+	_, alias := client.GetAliasCached(colName)
+	if alias.CollectionName != "" {
+		colName = alias.CollectionName
+	}
+	return client, colName, nil
+}
+
+// rawSearchParams : the parameters + extra parameters the typesense client does not support , encoded the same way the client does
+func rawSearchParams(params *typesense.SearchParameters, extra map[string]string) (map[string]string, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	query := make(map[string]string)
+	err = json.Unmarshal(b, &query)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range extra {
+		query[k] = v
+	}
+	return query, nil
+}
+
+// searchRaw : searches the collection with the parameters + extra parameters the typesense client does not support
+func (c *CrudTypeSense[t]) searchRaw(params *typesense.SearchParameters, extra map[string]string, result interface{}) error {
+	client, colName, err := c.rawClient()
+	if err != nil {
+		return err
+	}
+	query, err := rawSearchParams(params, extra)
+	if err != nil {
+		return err
+	}
+	req := client.Req()
+	if c.ctx != nil {
+		req.SetContext(c.ctx)
+	}
+	res, err := req.
+		SetQueryParams(query).
+		SetResult(result).
+		Get(fmt.Sprintf("/collections/%s/documents/search", url.PathEscape(colName)))
+	if err != nil {
+		return err
+	}
+	if res.IsError() {
+		return fmt.Errorf("typesense : search failed with status %d : %s", res.StatusCode(), res.String())
+	}
+	return nil
+}
+
+// deleteRaw : deletes the documents matching the filter , returns the number of documents deleted
+// (the typesense client does not return it)
+func (c *CrudTypeSense[t]) deleteRaw(filterBy string) (int64, error) {
+	var result struct {
+		NumDeleted int64 `json:"num_deleted"`
+	}
+	client, colName, err := c.rawClient()
+	if err != nil {
+		return 0, err
+	}
+	req := client.Req()
+	if c.ctx != nil {
+		req.SetContext(c.ctx)
+	}
+	res, err := req.
+		SetQueryParam("filter_by", filterBy).
+		SetResult(&result).
+		Delete(fmt.Sprintf("/collections/%s/documents", url.PathEscape(colName)))
+	if err != nil {
+		return 0, err
+	}
+	if res.IsError() {
+		return 0, fmt.Errorf("typesense : delete failed with status %d : %s", res.StatusCode(), res.String())
+	}
+	return result.NumDeleted, nil
+}
+
+// updateRaw : patches the documents matching the filter (typesense >= 0.25) , returns the number of documents updated
+// (the typesense client cannot update by query)
+func (c *CrudTypeSense[t]) updateRaw(filterBy string, patch map[string]interface{}) (int64, error) {
+	var result struct {
+		NumUpdated int64 `json:"num_updated"`
+	}
+	client, colName, err := c.rawClient()
+	if err != nil {
+		return 0, err
+	}
+	req := client.Req()
+	if c.ctx != nil {
+		req.SetContext(c.ctx)
+	}
+	res, err := req.
+		SetQueryParam("filter_by", filterBy).
+		SetBody(patch).
+		SetResult(&result).
+		Patch(fmt.Sprintf("/collections/%s/documents", url.PathEscape(colName)))
+	if err != nil {
+		return 0, err
+	}
+	if res.IsError() {
+		return 0, fmt.Errorf("typesense : update failed with status %d : %s", res.StatusCode(), res.String())
+	}
+	return result.NumUpdated, nil
+}
@@ -0,0 +1,60 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+)
+
+// AccountGorm : gorm account
+type AccountGorm struct {
+	CrudGorm[entity.Account]
+}
+
+// WithTransaction : transactional pointer (the returned repository is a repository.IAccount)
+func (a *AccountGorm) WithTransaction(tx repository.ITransaction) repository.ICrud[entity.Account] {
+	return &AccountGorm{CrudGorm: *a.CrudGorm.withTransaction(tx)}
+}
+
+// WithContext : view of the repository whose calls use the context (the returned repository is a repository.IAccount)
+func (a *AccountGorm) WithContext(ctx context.Context) repository.ICrud[entity.Account] {
+	return &AccountGorm{CrudGorm: *a.CrudGorm.withContext(ctx)}
+}
+
+// WithSchema : view of the repository whose filter / sort / facet expressions are restricted to the schema (the returned repository is a repository.IAccount)
+func (a *AccountGorm) WithSchema(schema *rql.Schema) repository.ICrud[entity.Account] {
+	return &AccountGorm{CrudGorm: *a.CrudGorm.withSchema(schema)}
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted accounts (the returned repository is a repository.IAccount)
+func (a *AccountGorm) IncludeDeleted() repository.ICrud[entity.Account] {
+	cp := a.CrudGorm
+	cp.includeDeleted = true
+	return &AccountGorm{CrudGorm: cp}
+}
+
+// DoesAccountExist : soft deleted accounts are included since they still hold their id / email
+func (a *AccountGorm) DoesAccountExist(accountID string, oremail string) bool {
+	var c int64
+	a.DB.Where("email=?", oremail).Or("account_id=?", accountID).Count(&c)
+	return c > 0
+}
+
+func (a *AccountGorm) DoesAccountExistByEmail(email string) (bool, *entity.Account) {
+	var e entity.Account
+	a.DB.Where("email=?", email).First(&e)
+	return e.ID != "", &e
+}
+
+// SessionGorm : session gorm
+type SessionGorm = CrudGorm[entity.Session]
+type HashAccountVerification = CrudGorm[entity.HashVerificationAccount]
+
+var _ repository.IAccount = &AccountGorm{}
+var _ repository.ISoftDelete[entity.Account] = &AccountGorm{}
+var _ repository.IWhere[entity.Account] = &AccountGorm{}
+var _ repository.ISchemaView[entity.Account] = &AccountGorm{}
+var _ repository.ISession = &SessionGorm{}
+var _ repository.IHashVerificationAccount = &HashAccountVerification{}
@@ -0,0 +1,64 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+)
+
+var _ repository.IFederatedSource = &FederatedGorm[entity.SavedView]{}
+
+// FederatedGorm : a sql table searched by a repository.FederatedSearch using its full text columns (see rql.RQLFullTextTag)
+type FederatedGorm[t entity.Model] struct {
+	Entity string
+	Repo   *CrudGorm[t]
+	// SearchColumns : full text columns searched , defaults to every column tagged `rql_fulltext`
+	SearchColumns []string
+}
+
+// EntityName : name the hits of the entity are tagged with
+func (s *FederatedGorm[t]) EntityName() string {
+	return s.Entity
+}
+
+// Search : records matching the term on any of the search columns within the filter ,
+// ranked by their full text relevance (in the order of the search columns)
+func (s *FederatedGorm[t]) Search(ctx context.Context, term string, f *rql.FilterExpression, limit int) (*repository.FederatedSourceResult, error) {
+	cols := s.SearchColumns
+	if len(cols) == 0 {
+		cols = rql.GetSchemaFromTaggedEntity(s.Repo.Model(), "db").FullTextColumns()
+	}
+	if len(cols) == 0 {
+		return nil, repository.ErrFederatedNoSearchColumns(s.Entity)
+	}
+
+	var (
+		matches   = make([]*rql.FilterBuilder, 0, len(cols))
+		relevance = rql.SortByRelevance(cols[0], term).Desc()
+	)
+	for i, col := range cols {
+		matches = append(matches, rql.Where(col).Search(term))
+		if i > 0 {
+			relevance = relevance.ThenByRelevance(col, term).Desc()
+		}
+	}
+	search, err := rql.Or(matches...).Build()
+	if err != nil {
+		return nil, err
+	}
+	// the entity filter is applied as the base expression so it is and-ed with the search
+	res, err := s.Repo.withContext(ctx).GetWithFilterExpressionPaginated(search, rql.PaginationOptions{DefaultSize: limit, Count: rql.CountExact}.First(), relevance, f)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*repository.FederatedHit, 0, len(res.Records))
+	for _, record := range res.Records {
+		hits = append(hits, &repository.FederatedHit{Record: record})
+	}
+	return &repository.FederatedSourceResult{
+		Hits:  hits,
+		Found: res.TotalRecords,
+	}, nil
+}
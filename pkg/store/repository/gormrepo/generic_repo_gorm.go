@@ -0,0 +1,541 @@
+package gormrepo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/baderkha/library/pkg/conditional"
+	"github.com/baderkha/library/pkg/db"
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/rql/gormrql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	GormBatchSize = 3000
+)
+
+var _ repository.ISoftDelete[entity.SavedView] = &CrudGorm[entity.SavedView]{}
+var _ repository.IWhere[entity.SavedView] = &CrudGorm[entity.SavedView]{}
+var _ repository.ISchemaView[entity.SavedView] = &CrudGorm[entity.SavedView]{}
+
+type CrudGorm[t entity.Model] struct {
+	DB *gorm.DB
+	// Parser : sql filter parser , its output is applied as a gorm clause (see gormrql.FilterParserGormSQL) , ignored if GormParser is set
+	Parser rql.ISQLFilterParser
+	// Sorter : sql sort parser , its output is applied as a gorm clause (see gormrql.SortParserGormSQL) , ignored if GormSorter is set
+	Sorter rql.ISQLSortParser
+	// GormParser : filter parser , defaults to Parser or gormrql.NewGormFilterParser(DB) if nil
+	GormParser gormrql.IGormFilterParser
+	// GormSorter : sort parser , defaults to Sorter or gormrql.NewGormSortParser(DB) if nil
+	GormSorter gormrql.IGormSortParser
+
+	// includeDeleted : reads return soft deleted records (see IncludeDeleted)
+	includeDeleted bool
+	// schemaView : schema of the filter / sort / facet expressions (see WithSchema)
+	schemaView *rql.Schema
+}
+
+func (c *CrudGorm[t]) Model() t {
+	var m t
+	return m
+}
+
+func (c *CrudGorm[t]) schema() *rql.Schema {
+	return rql.GetSchemaFromTaggedEntity(c.Model(), "db")
+}
+
+// filterSchema : schema of the filter / sort / facet expressions , the full schema unless restricted (see WithSchema)
+func (c *CrudGorm[t]) filterSchema() *rql.Schema {
+	if c.schemaView != nil {
+		return c.schemaView
+	}
+	return c.schema()
+}
+
+func (c *CrudGorm[t]) DoesIDExist(id string) bool {
+	obj, err := c.GetById(id)
+	return err == nil && obj != nil
+}
+
+// GetById : get 1 record by id if not found should return err
+func (c *CrudGorm[t]) GetById(id string) (*t, error) {
+	var res t
+	err := c.DB.Table(c.Model().TableName()).Scopes(c.notDeleted).Where(c.Model().GetIDKey()+"=?", id).First(&res).Error
+	return &res, err
+}
+
+// GetAll : get all the records (db dump)
+func (c *CrudGorm[t]) GetAll() ([]*t, error) {
+	var res []*t
+	err := c.DB.Table(c.Model().TableName()).Scopes(c.notDeleted).Find(&res).Error
+	return res, err
+}
+
+func (c *CrudGorm[t]) IsForAccountID(id string, accountID string) bool {
+	var count int64
+	c.DB.Table(c.Model().TableName()).Scopes(c.notDeleted).Where(c.Model().GetIDKey()+"=?", id).Where("account_id=?", accountID).Count(&count)
+	return count > 0
+}
+
+func (c *CrudGorm[t]) parser() gormrql.IGormFilterParser {
+	if c.GormParser != nil {
+		return c.GormParser
+	}
+	if c.Parser != nil {
+		return &gormrql.FilterParserGormSQL{Parser: c.Parser}
+	}
+	return gormrql.NewGormFilterParser(c.DB)
+}
+
+func (c *CrudGorm[t]) sorter() gormrql.IGormSortParser {
+	if c.GormSorter != nil {
+		return c.GormSorter
+	}
+	if c.Sorter != nil {
+		return &gormrql.SortParserGormSQL{Sorter: c.Sorter}
+	}
+	return gormrql.NewGormSortParser(c.DB)
+}
+
+// filterScopes : compiles the filter + base expression and the sort expression into gorm scopes ,
+// the filter scope excludes the soft deleted records
+func (c *CrudGorm[t]) filterScopes(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (filter func(*gorm.DB) *gorm.DB, sort func(*gorm.DB) *gorm.DB, err error) {
+	var base *rql.FilterExpression
+	if len(baseExpression) > 0 {
+		base = baseExpression[0]
+	}
+	where, err := gormrql.GormFilterScope(c.parser(), c.filterSchema(), f)
+	if err != nil {
+		return nil, nil, err
+	}
+	baseWhere, err := gormrql.GormFilterScope(c.parser(), c.schema(), base)
+	if err != nil {
+		return nil, nil, err
+	}
+	filter = func(tx *gorm.DB) *gorm.DB {
+		return tx.Scopes(where, baseWhere, c.notDeleted)
+	}
+	sort, err = gormrql.GormSortScope(c.sorter(), c.filterSchema(), s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filter, sort, nil
+}
+
+// GetWithFilterExpression : filter + sort a result using the rql package
+func (c *CrudGorm[t]) GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error) {
+	filter, sort, err := c.filterScopes(f, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	err = c.DB.Table(c.Model().TableName()).Scopes(filter, sort).Find(&data).Error
+	return data, err
+}
+
+// GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package
+//
+// the total is computed using the count mode of the pagination expression (see rql.CountMode) ,
+// a nil pagination expression is the first page of rql.DefaultPaginationOptions
+func (c *CrudGorm[t]) GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *repository.Paginated[t], err error) {
+	var (
+		records []*t
+		mode    rql.CountMode
+		count   int64 = -1
+	)
+	if p == nil {
+		p = rql.DefaultPaginationOptions.First()
+	}
+	filter, sort, err := c.filterScopes(f, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	mode = p.CountMode()
+	if mode == rql.CountEstimated && !(repository.IsEmptyFilter(f) && (len(baseExpression) == 0 || repository.IsEmptyFilter(baseExpression[0]))) {
+		mode = rql.CountExact
+	}
+	limit := conditional.Ternary(mode == rql.CountExact, p.Size(), p.Size()+1)
+
+	// the find and the count run one after the other , a gorm session (ie a transaction) cannot be used concurrently
+	err = c.DB.Table(c.Model().TableName()).Scopes(filter, sort).Limit(limit).Offset(p.Offset()).Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	if mode == rql.CountEstimated {
+		estimate, ok := c.estimateCount()
+		if ok {
+			count = estimate
+		} else {
+			mode = rql.CountExact
+		}
+	}
+	if mode == rql.CountExact {
+		err = c.DB.Table(c.Model().TableName()).Scopes(filter).Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	hasNext := len(records) > p.Size()
+	if hasNext {
+		records = records[:p.Size()]
+	}
+	return repository.NewPaginated(p, records, count, mode, hasNext), nil
+}
+
+// estimateCount : row count of the table from the database statistics , false if not available
+func (c *CrudGorm[t]) estimateCount() (int64, bool) {
+	var (
+		estimate sql.NullInt64
+		err      error
+	)
+	switch gormrql.DialectFromGorm(c.DB) {
+	case db.DialectMYSQL:
+		err = c.DB.Raw(
+			"SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+			c.Model().TableName(),
+		).Scan(&estimate).Error
+	case db.DialectPostgres:
+		// reltuples is -1 for tables that were never vacuumed / analyzed
+		err = c.DB.Raw(
+			"SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass(?)",
+			c.Model().TableName(),
+		).Scan(&estimate).Error
+	default:
+		return 0, false
+	}
+	if err != nil || !estimate.Valid || estimate.Int64 < 0 {
+		return 0, false
+	}
+	return estimate.Int64, true
+}
+
+// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns over all the matching records
+//
+// each facet is a grouped count over a subquery of the filter + base expression (null values are not counted)
+func (c *CrudGorm[t]) GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *repository.Faceted[t], err error) {
+	var (
+		schema   = c.filterSchema()
+		byColumn = make(map[string]*repository.FacetResult)
+	)
+	if facets == nil {
+		return nil, rql.ErrFacetEmpty
+	}
+	err = facets.ValidateForSQL(schema)
+	if err != nil {
+		return nil, err
+	}
+	filter, _, err := c.filterScopes(f, nil, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	page, err := c.GetWithFilterExpressionPaginated(f, p, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+
+	// one facet at a time , a gorm session (ie a transaction) cannot be used concurrently
+	for _, col := range facets.Columns() {
+		facet, err := c.facet(filter, schema, col, facets.GetMaxValues())
+		if err != nil {
+			return nil, err
+		}
+		byColumn[col] = facet
+	}
+	return repository.NewFaceted(page, facets, byColumn), nil
+}
+
+// facetSource : the filtered records as a subquery
+func (c *CrudGorm[t]) facetSource(filter func(*gorm.DB) *gorm.DB) *gorm.DB {
+	return c.DB.Table("(?) AS facet_source", c.DB.Table(c.Model().TableName()).Scopes(filter))
+}
+
+// facet : most frequent values of the column (and its stats if numeric) over the filtered records
+func (c *CrudGorm[t]) facet(filter func(*gorm.DB) *gorm.DB, schema *rql.Schema, col string, maxValues int) (*repository.FacetResult, error) {
+	var (
+		column = clause.Column{Name: schema.GetColumnInternalName(col)}
+		rows   []struct {
+			FacetValue sql.NullString
+			FacetCount int64
+		}
+		res = repository.FacetResult{Column: col}
+	)
+	err := c.facetSource(filter).
+		Select("? AS facet_value, COUNT(*) AS facet_count", column).
+		Where("? IS NOT NULL", column).
+		Clauses(clause.GroupBy{Columns: []clause.Column{column}}).
+		Order("facet_count DESC, facet_value ASC").
+		Limit(maxValues).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	res.Counts = make([]repository.FacetCount, 0, len(rows))
+	for _, row := range rows {
+		res.Counts = append(res.Counts, repository.FacetCount{Value: row.FacetValue.String, Count: row.FacetCount})
+	}
+	if !schema.IsNumericColumn(col) {
+		return &res, nil
+	}
+
+	var stats struct {
+		FacetMin   sql.NullFloat64
+		FacetMax   sql.NullFloat64
+		FacetSum   sql.NullFloat64
+		FacetAvg   sql.NullFloat64
+		FacetTotal int64
+	}
+	err = c.facetSource(filter).
+		Select(
+			"MIN(?) AS facet_min, MAX(?) AS facet_max, SUM(?) AS facet_sum, AVG(?) AS facet_avg, COUNT(DISTINCT ?) AS facet_total",
+			column, column, column, column, column,
+		).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	res.Stats = &repository.FacetStats{
+		Min:         stats.FacetMin.Float64,
+		Max:         stats.FacetMax.Float64,
+		Sum:         stats.FacetSum.Float64,
+		Avg:         stats.FacetAvg.Float64,
+		TotalValues: stats.FacetTotal,
+	}
+	return &res, nil
+}
+
+// Create : create one
+func (c *CrudGorm[t]) Create(mdl *t) error {
+	repository.InitVersion(mdl)
+	return c.DB.Table(c.Model().TableName()).Create(mdl).Error
+}
+
+// BulkCreate : create many
+func (c *CrudGorm[t]) BulkCreate(mdl []*t) error {
+	for _, m := range mdl {
+		repository.InitVersion(m)
+	}
+	return c.DB.Table(c.Model().TableName()).CreateInBatches(mdl, GormBatchSize).Error
+}
+
+// Update : update model
+//
+// versioned models (see entity.BaseVersioned) are only updated if the stored version is the version of the model ,
+// the version is then incremented , a *repository.VersionConflictError is returned otherwise
+func (c *CrudGorm[t]) Update(mdl *t) error {
+	versioned, col := repository.VersionOf(mdl)
+	if versioned == nil {
+		return c.DB.Table(c.Model().TableName()).Updates(mdl).Error
+	}
+	version := versioned.GetVersion()
+	versioned.SetVersion(version + 1)
+	res := c.DB.Table(c.Model().TableName()).
+		Where(clause.Eq{Column: clause.Column{Name: col}, Value: version}).
+		Updates(mdl)
+	if res.Error != nil {
+		versioned.SetVersion(version)
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		versioned.SetVersion(version)
+		return &repository.VersionConflictError{Table: c.Model().TableName(), ID: (*mdl).GetID(), Version: version}
+	}
+	return nil
+}
+
+// DeleteById : perma delete model by id
+func (c *CrudGorm[t]) DeleteById(id string) error {
+	var res t
+
+	err := c.DB.Table(c.Model().TableName()).Where(c.Model().GetIDKey()+"=?", id).Delete(&res).Error
+	return err
+}
+
+func (c *CrudGorm[t]) DeleteByIds(id []string) error {
+	var res t
+	err := c.DB.Table(c.Model().TableName()).Where(c.Model().GetIDKey()+" in (?)", id).Delete(&res).Error
+	return err
+}
+
+func (c *CrudGorm[t]) WithTransaction(tx repository.ITransaction) repository.ICrud[t] {
+	return c.withTransaction(tx)
+}
+
+func (c *CrudGorm[t]) withTransaction(tx repository.ITransaction) *CrudGorm[t] {
+	cp := *c
+	cp.DB = tx.(*GormTransaction).DB
+	return &cp
+}
+
+// WithContext : view of the repository whose calls use the context (cancellation , deadlines , tracing)
+func (c *CrudGorm[t]) WithContext(ctx context.Context) repository.ICrud[t] {
+	return c.withContext(ctx)
+}
+
+func (c *CrudGorm[t]) withContext(ctx context.Context) *CrudGorm[t] {
+	cp := *c
+	cp.DB = c.DB.WithContext(ctx)
+	return &cp
+}
+
+// WithSchema : view of the repository whose filter / sort / facet expressions are parsed and validated against the schema
+// (ie rql.Schema.ForRole) , the base expressions keep using the full schema
+func (c *CrudGorm[t]) WithSchema(schema *rql.Schema) repository.ICrud[t] {
+	return c.withSchema(schema)
+}
+
+func (c *CrudGorm[t]) withSchema(schema *rql.Schema) *CrudGorm[t] {
+	cp := *c
+	cp.schemaView = schema
+	return &cp
+}
+
+// softDeleteColumns : internal names of the is_deleted / updated_at columns , empty if the model cannot be soft deleted
+func (c *CrudGorm[t]) softDeleteColumns() (isDeleted string, updatedAt string) {
+	var mdl t
+	return repository.SoftDeleteColumns(rql.GetSchemaFromTaggedEntity(mdl, "db"))
+}
+
+// notDeleted : scope excluding the soft deleted records (no op for an IncludeDeleted view or a model that cannot be soft deleted)
+func (c *CrudGorm[t]) notDeleted(tx *gorm.DB) *gorm.DB {
+	isDeleted, _ := c.softDeleteColumns()
+	if c.includeDeleted || isDeleted == "" {
+		return tx
+	}
+	return tx.Where(clause.Eq{Column: clause.Column{Name: isDeleted}, Value: false})
+}
+
+// setDeleted : soft deletes / restores the records that are not in the state yet , the update time is set to now (and the version incremented)
+func (c *CrudGorm[t]) setDeleted(ids []string, isDeleted bool) (int64, error) {
+	colDeleted, colUpdated := c.softDeleteColumns()
+	if colDeleted == "" {
+		return 0, repository.ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	updates := map[string]interface{}{colDeleted: isDeleted}
+	if colUpdated != "" {
+		updates[colUpdated] = time.Now()
+	}
+	colVersion := rql.GetSchemaFromTaggedEntity(c.Model(), "db").GetColumnInternalName(repository.ColumnVersion)
+	if colVersion != "" {
+		updates[colVersion] = gorm.Expr("? + 1", clause.Column{Name: colVersion})
+	}
+	res := c.DB.Table(c.Model().TableName()).
+		Where(c.Model().GetIDKey()+" in (?)", ids).
+		Where(clause.Eq{Column: clause.Column{Name: colDeleted}, Value: !isDeleted}).
+		Updates(updates)
+	return res.RowsAffected, res.Error
+}
+
+// SoftDeleteById : mark the record as deleted
+func (c *CrudGorm[t]) SoftDeleteById(id string) error {
+	_, err := c.setDeleted([]string{id}, true)
+	return err
+}
+
+// SoftDeleteByIds : mark many records as deleted
+func (c *CrudGorm[t]) SoftDeleteByIds(ids []string) error {
+	_, err := c.setDeleted(ids, true)
+	return err
+}
+
+// Restore : unmark a soft deleted record , repository.ErrSoftDeleteNotFound if there is no soft deleted record with the id
+func (c *CrudGorm[t]) Restore(id string) error {
+	restored, err := c.setDeleted([]string{id}, false)
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return repository.ErrSoftDeleteNotFound
+	}
+	return nil
+}
+
+// PurgeDeletedOlderThan : perma delete the records soft deleted more than age ago , returns the number of records purged
+func (c *CrudGorm[t]) PurgeDeletedOlderThan(age time.Duration) (int64, error) {
+	var res t
+	colDeleted, colUpdated := c.softDeleteColumns()
+	if colDeleted == "" || colUpdated == "" {
+		return 0, repository.ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	if age < 0 {
+		return 0, repository.ErrSoftDeleteNegativeAge
+	}
+	purge := c.DB.Table(c.Model().TableName()).
+		Where(clause.Eq{Column: clause.Column{Name: colDeleted}, Value: true}).
+		Where(clause.Lt{Column: clause.Column{Name: colUpdated}, Value: time.Now().Add(-age)}).
+		Delete(&res)
+	return purge.RowsAffected, purge.Error
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted records
+func (c *CrudGorm[t]) IncludeDeleted() repository.ICrud[t] {
+	cp := *c
+	cp.includeDeleted = true
+	return &cp
+}
+
+// CountWhere : number of records matching the filter
+func (c *CrudGorm[t]) CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	var count int64
+	filter, _, err := c.filterScopes(f, nil, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	err = c.DB.Table(c.Model().TableName()).Scopes(filter).Count(&count).Error
+	return count, err
+}
+
+// ExistsWhere : at least 1 record matches the filter (counts a subquery limited to 1 record)
+func (c *CrudGorm[t]) ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error) {
+	var count int64
+	filter, _, err := c.filterScopes(f, nil, baseExpression...)
+	if err != nil {
+		return false, err
+	}
+	err = c.DB.Table("(?) AS exists_source", c.DB.Table(c.Model().TableName()).Scopes(filter).Limit(1)).Count(&count).Error
+	return count > 0, err
+}
+
+// UpdateWhere : sets the columns of the patch on the records matching the filter in 1 statement , returns the number of records updated
+func (c *CrudGorm[t]) UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error) {
+	schema := rql.GetSchemaFromTaggedEntity(c.Model(), "db")
+	err := repository.RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	updates, err := repository.PatchColumns(schema, patch, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	colVersion := schema.GetColumnInternalName(repository.ColumnVersion)
+	if colVersion != "" {
+		updates[colVersion] = gorm.Expr("? + 1", clause.Column{Name: colVersion})
+	}
+	filter, _, err := c.filterScopes(f, nil, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	res := c.DB.Table(c.Model().TableName()).Scopes(filter).Updates(updates)
+	return res.RowsAffected, res.Error
+}
+
+// DeleteWhere : perma deletes the records matching the filter in 1 statement , returns the number of records deleted
+func (c *CrudGorm[t]) DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	var res t
+	err := repository.RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	filter, _, err := c.filterScopes(f, nil, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	del := c.DB.Table(c.Model().TableName()).Scopes(filter).Delete(&res)
+	return del.RowsAffected, del.Error
+}
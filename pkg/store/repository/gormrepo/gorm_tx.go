@@ -0,0 +1,28 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/baderkha/library/pkg/store/repository"
+	"gorm.io/gorm"
+)
+
+var _ repository.ITransaction = &GormTransaction{}
+
+type GormTransaction struct {
+	DB *gorm.DB
+}
+
+func (g *GormTransaction) Begin() repository.ITransaction {
+	return &GormTransaction{DB: g.DB.Begin()}
+}
+func (g *GormTransaction) Commit() error {
+	return g.DB.Commit().Error
+}
+func (g *GormTransaction) RollBack() {
+	g.DB.Rollback()
+}
+
+func (g *GormTransaction) WithContext(ctx context.Context) repository.ITransaction {
+	return &GormTransaction{DB: g.DB.WithContext(ctx)}
+}
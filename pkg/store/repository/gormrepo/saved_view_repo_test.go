@@ -0,0 +1,78 @@
+package gormrepo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+type savedViewTestEntity struct {
+	entity.BaseOwned
+	Name string `json:"name" db:"name"`
+}
+
+func (savedViewTestEntity) TableName() string { return "test_entities" }
+
+// savedViewDryRunDialector : gorm dialector that only builds the statements (gorm.Config.DryRun)
+type savedViewDryRunDialector struct{}
+
+func (savedViewDryRunDialector) Name() string { return "mysql" }
+func (savedViewDryRunDialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	return nil
+}
+func (savedViewDryRunDialector) Migrator(db *gorm.DB) gorm.Migrator             { return nil }
+func (savedViewDryRunDialector) DataTypeOf(*schema.Field) string                { return "" }
+func (savedViewDryRunDialector) DefaultValueOf(*schema.Field) clause.Expression { return nil }
+func (savedViewDryRunDialector) BindVarTo(w clause.Writer, stmt *gorm.Statement, v interface{}) {
+	w.WriteByte('?')
+}
+func (savedViewDryRunDialector) QuoteTo(w clause.Writer, str string) {
+	w.WriteString("`" + str + "`")
+}
+func (savedViewDryRunDialector) Explain(sql string, vars ...interface{}) string {
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
+
+func TestSavedViewUpdateWritesZeroValuesWithGorm(t *testing.T) {
+	db, err := gorm.Open(savedViewDryRunDialector{}, &gorm.Config{DryRun: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statement string
+	err = db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+		statement = tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &repository.SavedViewRepo{
+		Views:  &CrudGorm[entity.SavedView]{DB: db},
+		Shares: &CrudGorm[entity.SavedViewShare]{DB: db},
+		Targets: map[string]repository.SavedViewTarget{
+			"test_entities": repository.NewSavedViewTarget[savedViewTestEntity](nil),
+		},
+	}
+
+	view := &entity.SavedView{Name: "mine", EntityName: "test_entities", Filter: `{"operation":"AND","properties":[]}`}
+	view.ID = "view1"
+	err = r.Update(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"`sort`=''", "`page_size`=0", "`id` = 'view1'"} {
+		if !strings.Contains(statement, want) {
+			t.Fatalf("statement %q does not contain %q", statement, want)
+		}
+	}
+	if strings.Contains(statement, "`account_id`") {
+		t.Fatalf("statement %q changes the owner", statement)
+	}
+}
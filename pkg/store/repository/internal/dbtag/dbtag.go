@@ -0,0 +1,86 @@
+// Package dbtag : the `db` tagged fields of the entities , shared by the repository backends mapping them without gorm
+package dbtag
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AutoIncrementTag : see repository.AutoIncrementTag
+	AutoIncrementTag = "auto_increment"
+)
+
+var (
+	TimeType = reflect.TypeOf(time.Time{})
+
+	// columnsCache : reflect.Type => []Column
+	columnsCache sync.Map
+)
+
+// Column : a `db` tagged field of an entity
+type Column struct {
+	Name  string
+	Index []int
+	// AutoIncrement : the column is assigned on create (see AutoIncrementTag)
+	AutoIncrement bool
+}
+
+// ColumnsOf : the `db` tagged fields of the struct (embedded structs included , `db:"-"` is skipped)
+func ColumnsOf(tpe reflect.Type) []Column {
+	cached, ok := columnsCache.Load(tpe)
+	if ok {
+		return cached.([]Column)
+	}
+	cols := collectColumns(tpe, nil)
+	columnsCache.Store(tpe, cols)
+	return cols
+}
+
+func collectColumns(tpe reflect.Type, index []int) []Column {
+	var cols []Column
+	for i := 0; i < tpe.NumField(); i++ {
+		field := tpe.Field(i)
+		fieldIndex := append(append(make([]int, 0, len(index)+1), index...), i)
+		name := strings.Split(field.Tag.Get("db"), ",")[0]
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && name == "" {
+			cols = append(cols, collectColumns(field.Type, fieldIndex)...)
+			continue
+		}
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		cols = append(cols, Column{Name: name, Index: fieldIndex, AutoIncrement: field.Tag.Get(AutoIncrementTag) != ""})
+	}
+	return cols
+}
+
+// FindColumn : the column by name , nil if the entity does not have it
+func FindColumn(cols []Column, name string) *Column {
+	for i := range cols {
+		if cols[i].Name == name {
+			return &cols[i]
+		}
+	}
+	return nil
+}
+
+// IsTimeType : time.Time or a type based on it (ie types.Timestamp)
+func IsTimeType(tpe reflect.Type) bool {
+	return tpe.Kind() == reflect.Struct && tpe.ConvertibleTo(TimeType)
+}
+
+// SetTimeColumn : sets the time column of the model (no op if the model does not have it)
+func SetTimeColumn(v reflect.Value, cols []Column, name string, now time.Time, onlyIfZero bool) {
+	col := FindColumn(cols, name)
+	if col == nil {
+		return
+	}
+	field := v.FieldByIndex(col.Index)
+	if !IsTimeType(field.Type()) || (onlyIfZero && !field.IsZero()) {
+		return
+	}
+	field.Set(reflect.ValueOf(now).Convert(field.Type()))
+}
@@ -0,0 +1,358 @@
+package otelrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var _ repository.ICrud[entity.SavedView] = &TracedCrud[entity.SavedView]{}
+var _ repository.ISoftDelete[entity.SavedView] = &TracedCrud[entity.SavedView]{}
+var _ repository.IWhere[entity.SavedView] = &TracedCrud[entity.SavedView]{}
+var _ repository.IAccount = &TracedAccount{}
+
+// TracedCrud : decorator tracing every call of the repository (span + latency / error metrics) ,
+// the span is passed to the repository through WithContext so the queries it runs are its children (see gormtelemetry.GormPlugin)
+//
+// Example :
+//			repo := &otelrepo.TracedCrud[entity.SavedView]{
+//				Repo:      &gormrepo.CrudGorm[entity.SavedView]{DB: db},
+//				Telemetry: t,
+//			}
+//			views, err := repo.WithContext(ctx.Request.Context()).GetWithFilterExpression(f, s)
+type TracedCrud[t entity.Model] struct {
+	Repo      repository.ICrud[t]
+	Telemetry *telemetry.Telemetry
+
+	// ctx : parent context of the spans (see WithContext)
+	ctx context.Context
+}
+
+func (c *TracedCrud[t]) Model() t {
+	var m t
+	return m
+}
+
+// start : starts the span of the operation , returns the repository bound to it
+func (c *TracedCrud[t]) start(operation string, attrs ...attribute.KeyValue) (repository.ICrud[t], *telemetry.Operation) {
+	ctx, op := c.Telemetry.Start(c.ctx, "repository", operation, c.Model().TableName(), attrs...)
+	return c.Repo.WithContext(ctx), op
+}
+
+func filterAttrs(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	hash := telemetry.FilterHash(f)
+	if hash != "" {
+		attrs = append(attrs, telemetry.AttrFilterHash.String(hash))
+	}
+	if len(baseExpression) > 0 {
+		base := telemetry.FilterHash(baseExpression[0])
+		if base != "" {
+			attrs = append(attrs, attribute.String("library.rql.base_filter_hash", base))
+		}
+	}
+	return attrs
+}
+
+func paginationAttrs(p *rql.PaginationExpression) []attribute.KeyValue {
+	if p == nil {
+		return nil
+	}
+	return []attribute.KeyValue{
+		attribute.Int("library.rql.page", p.Page()),
+		attribute.Int("library.rql.size", p.Size()),
+		attribute.String("library.rql.count_mode", string(p.CountMode())),
+	}
+}
+
+func (c *TracedCrud[t]) IsForAccountID(id string, accountID string) bool {
+	repo, op := c.start("is_for_account_id")
+	res := repo.IsForAccountID(id, accountID)
+	op.End(nil)
+	return res
+}
+
+func (c *TracedCrud[t]) DoesIDExist(id string) bool {
+	repo, op := c.start("does_id_exist")
+	res := repo.DoesIDExist(id)
+	op.End(nil)
+	return res
+}
+
+// GetById : get 1 record by id if not found should return err
+func (c *TracedCrud[t]) GetById(id string) (*t, error) {
+	repo, op := c.start("get_by_id")
+	res, err := repo.GetById(id)
+	if err == nil {
+		op.SetAttributes(telemetry.AttrRows.Int(1))
+	}
+	op.End(err)
+	return res, err
+}
+
+// GetAll : get all the records (db dump)
+func (c *TracedCrud[t]) GetAll() ([]*t, error) {
+	repo, op := c.start("get_all")
+	res, err := repo.GetAll()
+	op.SetAttributes(telemetry.AttrRows.Int(len(res)))
+	op.End(err)
+	return res, err
+}
+
+// GetWithFilterExpression : filter + sort a result using the rql package
+func (c *TracedCrud[t]) GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error) {
+	repo, op := c.start("get_with_filter_expression", filterAttrs(f, baseExpression...)...)
+	data, err = repo.GetWithFilterExpression(f, s, baseExpression...)
+	op.SetAttributes(telemetry.AttrRows.Int(len(data)))
+	op.End(err)
+	return data, err
+}
+
+// GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package
+func (c *TracedCrud[t]) GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *repository.Paginated[t], err error) {
+	repo, op := c.start("get_with_filter_expression_paginated", append(filterAttrs(f, baseExpression...), paginationAttrs(p)...)...)
+	data, err = repo.GetWithFilterExpressionPaginated(f, p, s, baseExpression...)
+	if data != nil {
+		op.SetAttributes(telemetry.AttrRows.Int(len(data.Records)), telemetry.AttrTotalRows.Int64(data.TotalRecords))
+	}
+	op.End(err)
+	return data, err
+}
+
+// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns over all the matching records
+func (c *TracedCrud[t]) GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *repository.Faceted[t], err error) {
+	attrs := append(filterAttrs(f, baseExpression...), paginationAttrs(p)...)
+	if facets != nil {
+		attrs = append(attrs, attribute.StringSlice("library.rql.facets", facets.Columns()))
+	}
+	repo, op := c.start("get_with_filter_expression_faceted", attrs...)
+	data, err = repo.GetWithFilterExpressionFaceted(f, p, s, facets, baseExpression...)
+	if data != nil {
+		op.SetAttributes(telemetry.AttrRows.Int(len(data.Records)), telemetry.AttrTotalRows.Int64(data.TotalRecords))
+	}
+	op.End(err)
+	return data, err
+}
+
+// Create : create one
+func (c *TracedCrud[t]) Create(mdl *t) error {
+	repo, op := c.start("create", telemetry.AttrRows.Int(1))
+	err := repo.Create(mdl)
+	op.End(err)
+	return err
+}
+
+// BulkCreate : create many
+func (c *TracedCrud[t]) BulkCreate(mdl []*t) error {
+	repo, op := c.start("bulk_create", telemetry.AttrRows.Int(len(mdl)))
+	err := repo.BulkCreate(mdl)
+	op.End(err)
+	return err
+}
+
+// Update : update model
+func (c *TracedCrud[t]) Update(mdl *t) error {
+	repo, op := c.start("update", telemetry.AttrRows.Int(1))
+	err := repo.Update(mdl)
+	op.End(err)
+	return err
+}
+
+// DeleteById : perma delete model by id
+func (c *TracedCrud[t]) DeleteById(id string) error {
+	repo, op := c.start("delete_by_id", telemetry.AttrRows.Int(1))
+	err := repo.DeleteById(id)
+	op.End(err)
+	return err
+}
+
+// DeleteByIds : perma delet by many ids
+func (c *TracedCrud[t]) DeleteByIds(id []string) error {
+	repo, op := c.start("delete_by_ids", telemetry.AttrRows.Int(len(id)))
+	err := repo.DeleteByIds(id)
+	op.End(err)
+	return err
+}
+
+// softDelete : the traced repository bound to the span of the operation as a soft delete repository
+func (c *TracedCrud[t]) softDelete(operation string, attrs ...attribute.KeyValue) (repository.ISoftDelete[t], *telemetry.Operation, error) {
+	repo, op := c.start(operation, attrs...)
+	softDelete, ok := repo.(repository.ISoftDelete[t])
+	if !ok {
+		err := repository.ErrSoftDeleteUnsupported(c.Model().TableName())
+		op.End(err)
+		return nil, nil, err
+	}
+	return softDelete, op, nil
+}
+
+// SoftDeleteById : mark the record as deleted (the traced repository must be a repository.ISoftDelete)
+func (c *TracedCrud[t]) SoftDeleteById(id string) error {
+	repo, op, err := c.softDelete("soft_delete_by_id", telemetry.AttrRows.Int(1))
+	if err != nil {
+		return err
+	}
+	err = repo.SoftDeleteById(id)
+	op.End(err)
+	return err
+}
+
+// SoftDeleteByIds : mark many records as deleted (the traced repository must be a repository.ISoftDelete)
+func (c *TracedCrud[t]) SoftDeleteByIds(ids []string) error {
+	repo, op, err := c.softDelete("soft_delete_by_ids", telemetry.AttrRows.Int(len(ids)))
+	if err != nil {
+		return err
+	}
+	err = repo.SoftDeleteByIds(ids)
+	op.End(err)
+	return err
+}
+
+// Restore : unmark a soft deleted record (the traced repository must be a repository.ISoftDelete)
+func (c *TracedCrud[t]) Restore(id string) error {
+	repo, op, err := c.softDelete("restore", telemetry.AttrRows.Int(1))
+	if err != nil {
+		return err
+	}
+	err = repo.Restore(id)
+	op.End(err)
+	return err
+}
+
+// PurgeDeletedOlderThan : perma delete the records soft deleted more than age ago (the traced repository must be a repository.ISoftDelete)
+func (c *TracedCrud[t]) PurgeDeletedOlderThan(age time.Duration) (int64, error) {
+	repo, op, err := c.softDelete("purge_deleted_older_than")
+	if err != nil {
+		return 0, err
+	}
+	purged, err := repo.PurgeDeletedOlderThan(age)
+	op.SetAttributes(telemetry.AttrRows.Int64(purged))
+	op.End(err)
+	return purged, err
+}
+
+// where : the traced repository bound to the span of the operation as a repository.IWhere
+func (c *TracedCrud[t]) where(operation string, attrs ...attribute.KeyValue) (repository.IWhere[t], *telemetry.Operation, error) {
+	repo, op := c.start(operation, attrs...)
+	where, err := repository.WhereOf(repo)
+	if err != nil {
+		op.End(err)
+		return nil, nil, err
+	}
+	return where, op, nil
+}
+
+// CountWhere : number of records matching the filter (the traced repository must be a repository.IWhere)
+func (c *TracedCrud[t]) CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	repo, op, err := c.where("count_where", filterAttrs(f, baseExpression...)...)
+	if err != nil {
+		return 0, err
+	}
+	count, err := repo.CountWhere(f, baseExpression...)
+	op.SetAttributes(telemetry.AttrTotalRows.Int64(count))
+	op.End(err)
+	return count, err
+}
+
+// ExistsWhere : at least 1 record matches the filter (the traced repository must be a repository.IWhere)
+func (c *TracedCrud[t]) ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error) {
+	repo, op, err := c.where("exists_where", filterAttrs(f, baseExpression...)...)
+	if err != nil {
+		return false, err
+	}
+	exists, err := repo.ExistsWhere(f, baseExpression...)
+	op.End(err)
+	return exists, err
+}
+
+// UpdateWhere : sets the columns of the patch on the records matching the filter (the traced repository must be a repository.IWhere)
+func (c *TracedCrud[t]) UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error) {
+	repo, op, err := c.where("update_where", filterAttrs(f, baseExpression...)...)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := repo.UpdateWhere(f, patch, baseExpression...)
+	op.SetAttributes(telemetry.AttrRows.Int64(updated))
+	op.End(err)
+	return updated, err
+}
+
+// DeleteWhere : perma deletes the records matching the filter (the traced repository must be a repository.IWhere)
+func (c *TracedCrud[t]) DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	repo, op, err := c.where("delete_where", filterAttrs(f, baseExpression...)...)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := repo.DeleteWhere(f, baseExpression...)
+	op.SetAttributes(telemetry.AttrRows.Int64(deleted))
+	op.End(err)
+	return deleted, err
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted records
+func (c *TracedCrud[t]) IncludeDeleted() repository.ICrud[t] {
+	repo, ok := c.Repo.(repository.ISoftDelete[t])
+	if !ok {
+		return c
+	}
+	return &TracedCrud[t]{Repo: repo.IncludeDeleted(), Telemetry: c.Telemetry, ctx: c.ctx}
+}
+
+// WithTransaction : transactional pointer (make sure all your repos use the same persistence layer)
+func (c *TracedCrud[t]) WithTransaction(tx repository.ITransaction) repository.ICrud[t] {
+	return &TracedCrud[t]{Repo: c.Repo.WithTransaction(tx), Telemetry: c.Telemetry, ctx: c.ctx}
+}
+
+// WithContext : view of the repository whose spans are children of the span in the context
+func (c *TracedCrud[t]) WithContext(ctx context.Context) repository.ICrud[t] {
+	return &TracedCrud[t]{Repo: c.Repo.WithContext(ctx), Telemetry: c.Telemetry, ctx: ctx}
+}
+
+// TracedAccount : TracedCrud of an account repository , the repository must be a repository.IAccount
+type TracedAccount struct {
+	TracedCrud[entity.Account]
+}
+
+// accounts : the traced repository bound to the span of the operation as an account repository
+func (a *TracedAccount) accounts(operation string) (repository.IAccount, *telemetry.Operation) {
+	repo, op := a.start(operation)
+	acc, ok := repo.(repository.IAccount)
+	if !ok {
+		acc = a.Repo.(repository.IAccount)
+	}
+	return acc, op
+}
+
+func (a *TracedAccount) DoesAccountExist(accountID string, oremail string) bool {
+	acc, op := a.accounts("does_account_exist")
+	res := acc.DoesAccountExist(accountID, oremail)
+	op.End(nil)
+	return res
+}
+
+func (a *TracedAccount) DoesAccountExistByEmail(email string) (bool, *entity.Account) {
+	acc, op := a.accounts("does_account_exist_by_email")
+	isExist, res := acc.DoesAccountExistByEmail(email)
+	op.End(nil)
+	return isExist, res
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted accounts (the returned repository is a repository.IAccount)
+func (a *TracedAccount) IncludeDeleted() repository.ICrud[entity.Account] {
+	return &TracedAccount{TracedCrud: *a.TracedCrud.IncludeDeleted().(*TracedCrud[entity.Account])}
+}
+
+// WithTransaction : transactional pointer (the returned repository is a repository.IAccount)
+func (a *TracedAccount) WithTransaction(tx repository.ITransaction) repository.ICrud[entity.Account] {
+	return &TracedAccount{TracedCrud: *a.TracedCrud.WithTransaction(tx).(*TracedCrud[entity.Account])}
+}
+
+// WithContext : view of the repository whose spans are children of the span in the context (the returned repository is a repository.IAccount)
+func (a *TracedAccount) WithContext(ctx context.Context) repository.ICrud[entity.Account] {
+	return &TracedAccount{TracedCrud: *a.TracedCrud.WithContext(ctx).(*TracedCrud[entity.Account])}
+}
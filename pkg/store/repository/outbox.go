@@ -0,0 +1,415 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+)
+
+var _ ICrud[entity.SavedView] = &OutboxCrud[entity.SavedView]{}
+var _ ISoftDelete[entity.SavedView] = &OutboxCrud[entity.SavedView]{}
+var _ IWhere[entity.SavedView] = &OutboxCrud[entity.SavedView]{}
+
+// OutboxCrud : decorator writing every create / update / delete of the repository as an entity.OutboxEvent
+// in the transaction of the change (transactional outbox) , an OutboxRelay then applies the events to the search index
+//
+// writes run in a transaction started from Tx , or in the transaction of the caller for a transactional view (see WithTransaction) ,
+// so a change is never committed without its event . reads are not decorated
+//
+// the events are ordered by their sequence , auto incremented by the database (see entity.OutboxEvent.Sequence) ,
+// the outbox table must auto increment the column (gorm migrations do , see AutoIncrementTag for sqlrepo.CrudSQL)
+//
+// Example :
+//			repo := &repository.OutboxCrud[entity.SavedView]{
+//				Repo:   &gormrepo.CrudGorm[entity.SavedView]{DB: db},
+//				Outbox: &gormrepo.CrudGorm[entity.OutboxEvent]{DB: db},
+//				Tx:     &gormrepo.GormTransaction{DB: db},
+//			}
+//			err := repo.Update(view) // view + its event are committed together
+type OutboxCrud[t entity.Model] struct {
+	// Repo : the decorated repository
+	Repo ICrud[t]
+	// Outbox : where the events are written , must use the same persistence layer as Repo
+	Outbox ICrud[entity.OutboxEvent]
+	// Tx : transaction the writes are wrapped in (ie &gormrepo.GormTransaction{DB: db}) ,
+	// if nil the change and its event are written one after the other and are not atomic
+	Tx ITransaction
+
+	// ctx : context of the calls (see WithContext)
+	ctx context.Context
+	// inTx : the repository is a transactional view , writes run in the transaction of the caller
+	inTx bool
+}
+
+func (o *OutboxCrud[t]) Model() t {
+	var m t
+	return m
+}
+
+func (o *OutboxCrud[t]) IsForAccountID(id string, accountID string) bool {
+	return o.Repo.IsForAccountID(id, accountID)
+}
+
+func (o *OutboxCrud[t]) DoesIDExist(id string) bool {
+	return o.Repo.DoesIDExist(id)
+}
+
+// GetById : get 1 record by id if not found should return err
+func (o *OutboxCrud[t]) GetById(id string) (*t, error) {
+	return o.Repo.GetById(id)
+}
+
+// GetAll : get all the records (db dump)
+func (o *OutboxCrud[t]) GetAll() ([]*t, error) {
+	return o.Repo.GetAll()
+}
+
+// GetWithFilterExpression : filter + sort a result using the rql package
+func (o *OutboxCrud[t]) GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error) {
+	return o.Repo.GetWithFilterExpression(f, s, baseExpression...)
+}
+
+// GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package
+func (o *OutboxCrud[t]) GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *Paginated[t], err error) {
+	return o.Repo.GetWithFilterExpressionPaginated(f, p, s, baseExpression...)
+}
+
+// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns over all the matching records
+func (o *OutboxCrud[t]) GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *Faceted[t], err error) {
+	return o.Repo.GetWithFilterExpressionFaceted(f, p, s, facets, baseExpression...)
+}
+
+// atomically : runs fn with views of the repositories sharing 1 transaction (the transaction of the caller for a transactional view)
+func (o *OutboxCrud[t]) atomically(fn func(repo ICrud[t], outbox ICrud[entity.OutboxEvent]) error) error {
+	if o.inTx || o.Tx == nil {
+		return fn(o.Repo, o.Outbox)
+	}
+	tx := o.Tx
+	if o.ctx != nil {
+		tx = tx.WithContext(o.ctx)
+	}
+	tx = tx.Begin()
+	err := fn(o.Repo.WithTransaction(tx), o.Outbox.WithTransaction(tx))
+	if err != nil {
+		tx.RollBack()
+		return err
+	}
+	return tx.Commit()
+}
+
+// newEvent : pending event of an action on the record
+func (o *OutboxCrud[t]) newEvent(action string, recordID string, payload string) *entity.OutboxEvent {
+	event := &entity.OutboxEvent{
+		EntityName: o.Model().TableName(),
+		RecordID:   recordID,
+		Action:     action,
+		Payload:    payload,
+		Status:     entity.OutboxStatusPending,
+	}
+	event.New()
+	event.NextAttemptAt = event.CreatedAt
+	return event
+}
+
+// upsertEvents : events carrying the new state of the records
+func (o *OutboxCrud[t]) upsertEvents(mdl []*t) ([]*entity.OutboxEvent, error) {
+	events := make([]*entity.OutboxEvent, 0, len(mdl))
+	for _, m := range mdl {
+		payload, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, o.newEvent(entity.OutboxActionUpsert, (*m).GetID(), string(payload)))
+	}
+	return events, nil
+}
+
+// storedEvents : upsert events carrying the records as stored (the written models can be partial , ie gorm Updates skips their zero fields) ,
+// missing records are skipped
+func (o *OutboxCrud[t]) storedEvents(repo ICrud[t], ids []string) ([]*entity.OutboxEvent, error) {
+	stored, err := recordsByIds(repo, ids)
+	if err != nil {
+		return nil, err
+	}
+	return o.upsertEvents(stored)
+}
+
+// deleteEvents : events of perma deleted records
+func (o *OutboxCrud[t]) deleteEvents(ids []string) []*entity.OutboxEvent {
+	events := make([]*entity.OutboxEvent, 0, len(ids))
+	for _, id := range ids {
+		events = append(events, o.newEvent(entity.OutboxActionDelete, id, ""))
+	}
+	return events
+}
+
+// writeOutbox : writes the events , no op without events
+func writeOutbox(outbox ICrud[entity.OutboxEvent], events []*entity.OutboxEvent) error {
+	switch len(events) {
+	case 0:
+		return nil
+	case 1:
+		return outbox.Create(events[0])
+	}
+	return outbox.BulkCreate(events)
+}
+
+// Create : create one
+func (o *OutboxCrud[t]) Create(mdl *t) error {
+	return o.BulkCreate([]*t{mdl})
+}
+
+// BulkCreate : create many
+func (o *OutboxCrud[t]) BulkCreate(mdl []*t) error {
+	return o.atomically(func(repo ICrud[t], outbox ICrud[entity.OutboxEvent]) error {
+		var err error
+		if len(mdl) == 1 {
+			err = repo.Create(mdl[0])
+		} else {
+			err = repo.BulkCreate(mdl)
+		}
+		if err != nil {
+			return err
+		}
+		events, err := o.storedEvents(repo, RecordIds(mdl))
+		if err != nil {
+			return err
+		}
+		return writeOutbox(outbox, events)
+	})
+}
+
+// Update : update model , the event carries the record as stored after the update
+func (o *OutboxCrud[t]) Update(mdl *t) error {
+	return o.atomically(func(repo ICrud[t], outbox ICrud[entity.OutboxEvent]) error {
+		err := repo.Update(mdl)
+		if err != nil {
+			return err
+		}
+		events, err := o.storedEvents(repo, []string{(*mdl).GetID()})
+		if err != nil {
+			return err
+		}
+		return writeOutbox(outbox, events)
+	})
+}
+
+// DeleteById : perma delete model by id
+func (o *OutboxCrud[t]) DeleteById(id string) error {
+	return o.DeleteByIds([]string{id})
+}
+
+// DeleteByIds : perma delet by many ids
+func (o *OutboxCrud[t]) DeleteByIds(id []string) error {
+	return o.atomically(func(repo ICrud[t], outbox ICrud[entity.OutboxEvent]) error {
+		var err error
+		if len(id) == 1 {
+			err = repo.DeleteById(id[0])
+		} else {
+			err = repo.DeleteByIds(id)
+		}
+		if err != nil {
+			return err
+		}
+		return writeOutbox(outbox, o.deleteEvents(id))
+	})
+}
+
+// softDeleteOf : the repository as a soft delete repository
+func softDeleteOf[t entity.Model](repo ICrud[t]) (ISoftDelete[t], error) {
+	sd, ok := repo.(ISoftDelete[t])
+	if !ok {
+		var m t
+		return nil, ErrSoftDeleteUnsupported(m.TableName())
+	}
+	return sd, nil
+}
+
+// setDeleted : soft deletes / restores the records , the events carry the records as stored after the change
+func (o *OutboxCrud[t]) setDeleted(ids []string, change func(sd ISoftDelete[t]) error) error {
+	return o.atomically(func(repo ICrud[t], outbox ICrud[entity.OutboxEvent]) error {
+		sd, err := softDeleteOf(repo)
+		if err != nil {
+			return err
+		}
+		err = change(sd)
+		if err != nil {
+			return err
+		}
+		// missing ids are skipped by the soft delete
+		events, err := o.storedEvents(repo, ids)
+		if err != nil {
+			return err
+		}
+		return writeOutbox(outbox, events)
+	})
+}
+
+// SoftDeleteById : mark the record as deleted (the decorated repository must be an ISoftDelete)
+func (o *OutboxCrud[t]) SoftDeleteById(id string) error {
+	return o.SoftDeleteByIds([]string{id})
+}
+
+// SoftDeleteByIds : mark many records as deleted (the decorated repository must be an ISoftDelete)
+func (o *OutboxCrud[t]) SoftDeleteByIds(ids []string) error {
+	return o.setDeleted(ids, func(sd ISoftDelete[t]) error {
+		return sd.SoftDeleteByIds(ids)
+	})
+}
+
+// Restore : unmark a soft deleted record (the decorated repository must be an ISoftDelete)
+func (o *OutboxCrud[t]) Restore(id string) error {
+	return o.setDeleted([]string{id}, func(sd ISoftDelete[t]) error {
+		return sd.Restore(id)
+	})
+}
+
+// PurgeDeletedOlderThan : perma delete the records soft deleted more than age ago , returns the number of records purged
+//
+// the expired records are selected then deleted by id so every purged record gets its delete event
+func (o *OutboxCrud[t]) PurgeDeletedOlderThan(age time.Duration) (int64, error) {
+	colDeleted, colUpdated := SoftDeleteColumns(rql.GetSchemaFromTaggedEntity(o.Model(), "db"))
+	if colDeleted == "" || colUpdated == "" {
+		return 0, ErrSoftDeleteUnsupported(o.Model().TableName())
+	}
+	if age < 0 {
+		return 0, ErrSoftDeleteNegativeAge
+	}
+	expired, err := rql.And(
+		rql.Where(colDeleted).Eq(true),
+		rql.Where(colUpdated).Lt(time.Now().Add(-age)),
+	).Build()
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	err = o.atomically(func(repo ICrud[t], outbox ICrud[entity.OutboxEvent]) error {
+		sd, err := softDeleteOf(repo)
+		if err != nil {
+			return err
+		}
+		records, err := sd.IncludeDeleted().GetWithFilterExpression(expired, nil)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(records))
+		for _, rec := range records {
+			ids = append(ids, (*rec).GetID())
+		}
+		err = repo.DeleteByIds(ids)
+		if err != nil {
+			return err
+		}
+		purged = int64(len(ids))
+		return writeOutbox(outbox, o.deleteEvents(ids))
+	})
+	return purged, err
+}
+
+// CountWhere : number of records matching the filter (the decorated repository must be an IWhere)
+func (o *OutboxCrud[t]) CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	repo, err := WhereOf(o.Repo)
+	if err != nil {
+		return 0, err
+	}
+	return repo.CountWhere(f, baseExpression...)
+}
+
+// ExistsWhere : at least 1 record matches the filter (the decorated repository must be an IWhere)
+func (o *OutboxCrud[t]) ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error) {
+	repo, err := WhereOf(o.Repo)
+	if err != nil {
+		return false, err
+	}
+	return repo.ExistsWhere(f, baseExpression...)
+}
+
+// UpdateWhere : sets the columns of the patch on the records matching the filter , the events carry the records as stored after the update
+// (the decorated repository must be an IWhere)
+func (o *OutboxCrud[t]) UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	var updated int64
+	err = o.atomically(func(repo ICrud[t], outbox ICrud[entity.OutboxEvent]) error {
+		where, err := WhereOf(repo)
+		if err != nil {
+			return err
+		}
+		matching, err := repo.GetWithFilterExpression(f, nil, baseExpression...)
+		if err != nil {
+			return err
+		}
+		updated, err = where.UpdateWhere(f, patch, baseExpression...)
+		if err != nil {
+			return err
+		}
+		events, err := o.storedEvents(repo, RecordIds(matching))
+		if err != nil {
+			return err
+		}
+		return writeOutbox(outbox, events)
+	})
+	return updated, err
+}
+
+// DeleteWhere : perma deletes the records matching the filter (the decorated repository must be an IWhere)
+func (o *OutboxCrud[t]) DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	err = o.atomically(func(repo ICrud[t], outbox ICrud[entity.OutboxEvent]) error {
+		where, err := WhereOf(repo)
+		if err != nil {
+			return err
+		}
+		matching, err := repo.GetWithFilterExpression(f, nil, baseExpression...)
+		if err != nil {
+			return err
+		}
+		deleted, err = where.DeleteWhere(f, baseExpression...)
+		if err != nil {
+			return err
+		}
+		return writeOutbox(outbox, o.deleteEvents(RecordIds(matching)))
+	})
+	return deleted, err
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted records
+func (o *OutboxCrud[t]) IncludeDeleted() ICrud[t] {
+	sd, err := softDeleteOf(o.Repo)
+	if err != nil {
+		return o
+	}
+	cp := *o
+	cp.Repo = sd.IncludeDeleted()
+	return &cp
+}
+
+// WithTransaction : transactional pointer , the changes and their events are written in the transaction of the caller
+func (o *OutboxCrud[t]) WithTransaction(tx ITransaction) ICrud[t] {
+	cp := *o
+	cp.Repo = o.Repo.WithTransaction(tx)
+	cp.Outbox = o.Outbox.WithTransaction(tx)
+	cp.inTx = true
+	return &cp
+}
+
+// WithContext : view of the repository whose calls (and transactions) use the context
+func (o *OutboxCrud[t]) WithContext(ctx context.Context) ICrud[t] {
+	cp := *o
+	cp.Repo = o.Repo.WithContext(ctx)
+	cp.Outbox = o.Outbox.WithContext(ctx)
+	cp.ctx = ctx
+	return &cp
+}
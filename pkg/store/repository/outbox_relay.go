@@ -0,0 +1,402 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/typesense"
+	"github.com/baderkha/typesense/types"
+)
+
+const (
+	// OutboxBatchSize : default number of events relayed per batch (and records per reindex page)
+	OutboxBatchSize = 100
+	// OutboxMaxAttempts : default number of failed attempts before an event is marked as failed
+	OutboxMaxAttempts = 10
+	// OutboxPollInterval : default wait of OutboxRelay.Run when there is nothing to relay
+	OutboxPollInterval = time.Second
+
+	// outboxMaxBackoff : cap of OutboxBackoff
+	outboxMaxBackoff = 10 * time.Minute
+)
+
+// OutboxBackoff : exponential retry delay , 1s after the first failed attempt doubling up to 10 minutes
+func OutboxBackoff(attempts int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
+
+// OutboxRelay : applies the outbox events of an entity (see OutboxCrud) to its typesense collection ,
+// upserts are imported in batches (IndexMany) and deletes are removed in batches (DeleteManyWithQuery)
+//
+// a batch is made of the records of the oldest due events , the pending events of a record are collapsed into its latest event
+// (the upsert payload is the full record) so changes are applied in order , a record whose latest event is waiting for a retry
+// is skipped until it is due . relayed events are deleted from the outbox (with the older events of the record they supersede) ,
+// events that failed MaxAttempts times are marked as failed (see RetryFailed)
+//
+// run a single relay per entity , 2 relays of the same entity could apply the events of a record out of order
+//
+// Example :
+//			relay := &repository.OutboxRelay[entity.SavedView]{
+//				Outbox: &gormrepo.CrudGorm[entity.OutboxEvent]{DB: db},
+//				Index:  typesense.NewDocumentClient[entity.SavedView](apiKey, host, false),
+//				Source: &gormrepo.CrudGorm[entity.SavedView]{DB: db},
+//			}
+//			go relay.Run(ctx)
+type OutboxRelay[t entity.Model] struct {
+	// Outbox : where the events are read from
+	Outbox ICrud[entity.OutboxEvent]
+	// Index : document client , the collection is named after the table of the model
+	Index typesense.IDocumentClient[t]
+	// Source : repository of the records , only used by Reindex
+	Source ICrud[t]
+	// BatchSize : defaults to OutboxBatchSize
+	BatchSize int
+	// MaxAttempts : defaults to OutboxMaxAttempts
+	MaxAttempts int
+	// Backoff : delay before retrying an event that failed attempts times , defaults to OutboxBackoff
+	Backoff func(attempts int) time.Duration
+	// PollInterval : defaults to OutboxPollInterval
+	PollInterval time.Duration
+	// OnError : called with the errors Run carries on after (nil to ignore them)
+	OnError func(err error)
+}
+
+func (r *OutboxRelay[t]) Model() t {
+	var m t
+	return m
+}
+
+func (r *OutboxRelay[t]) document() typesense.IDocumentClient[t] {
+	return r.Index.WithCollectionName(r.Model().TableName())
+}
+
+func (r *OutboxRelay[t]) batchSize() int {
+	if r.BatchSize > 0 {
+		return r.BatchSize
+	}
+	return OutboxBatchSize
+}
+
+func (r *OutboxRelay[t]) maxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	return OutboxMaxAttempts
+}
+
+func (r *OutboxRelay[t]) backoff(attempts int) time.Duration {
+	if r.Backoff != nil {
+		return r.Backoff(attempts)
+	}
+	return OutboxBackoff(attempts)
+}
+
+// events : events of the entity with the status matching the conditions , in order
+func (r *OutboxRelay[t]) events(outbox ICrud[entity.OutboxEvent], status string, p *rql.PaginationExpression, conds ...*rql.FilterBuilder) ([]*entity.OutboxEvent, error) {
+	base, err := rql.And(append([]*rql.FilterBuilder{
+		rql.Where("entity_name").Eq(r.Model().TableName()),
+		rql.Where("status").Eq(status),
+	}, conds...)...).Build()
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return outbox.GetWithFilterExpression(nil, rql.SortBy("sequence").Asc(), base)
+	}
+	res, err := outbox.GetWithFilterExpressionPaginated(nil, p, rql.SortBy("sequence").Asc(), base)
+	if err != nil {
+		return nil, err
+	}
+	return res.Records, nil
+}
+
+// idsFilter : filter_by matching the documents by id
+func idsFilter(ids []string) string {
+	return fmt.Sprintf("id:[%s]", strings.Join(ids, ","))
+}
+
+// fail : records a failed attempt of the events , they are marked as failed once out of attempts
+func (r *OutboxRelay[t]) fail(outbox ICrud[entity.OutboxEvent], events []*entity.OutboxEvent, cause error) error {
+	now := time.Now()
+	for _, event := range events {
+		event.Attempts++
+		event.LastError = cause.Error()
+		event.NextAttemptAt = types.Timestamp(now.Add(r.backoff(event.Attempts)))
+		if event.Attempts >= r.maxAttempts() {
+			event.Status = entity.OutboxStatusFailed
+		}
+		err := outbox.Update(event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventIDs : ids of the events
+func eventIDs(events []*entity.OutboxEvent) []string {
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+// Relay : relays 1 batch of pending events , returns the number of events relayed
+//
+// a failed import / delete is recorded on its events (see MaxAttempts , Backoff) and returned
+func (r *OutboxRelay[t]) Relay(ctx context.Context) (int, error) {
+	var (
+		outbox = r.Outbox.WithContext(ctx)
+		now    = time.Now()
+		p      = rql.PaginationOptions{Base: 1, DefaultSize: r.batchSize(), Count: rql.CountNone}.First()
+	)
+	// events in backoff are left out so a full page of them cannot starve the due events
+	due, err := r.events(outbox, entity.OutboxStatusPending, p, rql.Where("next_attempt_at").Le(now))
+	if err != nil {
+		return 0, err
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+	// every pending event of the records of the batch , a due event can be older than an event of its record in backoff
+	recordIDs := make([]interface{}, 0, len(due))
+	seen := make(map[string]bool, len(due))
+	for _, event := range due {
+		if !seen[event.RecordID] {
+			seen[event.RecordID] = true
+			recordIDs = append(recordIDs, event.RecordID)
+		}
+	}
+	pending, err := r.events(outbox, entity.OutboxStatusPending, nil, rql.Where("record_id").In(recordIDs...))
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		byRecord = make(map[string][]*entity.OutboxEvent)
+		order    []string
+	)
+	for _, event := range pending {
+		if _, ok := byRecord[event.RecordID]; !ok {
+			order = append(order, event.RecordID)
+		}
+		byRecord[event.RecordID] = append(byRecord[event.RecordID], event)
+	}
+
+	var (
+		upserts      []*t
+		upsertEvents []*entity.OutboxEvent
+		deletes      []string
+		deleteEvents []*entity.OutboxEvent
+		relayed      []*entity.OutboxEvent
+		firstErr     error
+	)
+	for _, id := range order {
+		events := byRecord[id]
+		latest := events[len(events)-1]
+		if time.Time(latest.NextAttemptAt).After(now) {
+			continue
+		}
+		if latest.Action == entity.OutboxActionDelete {
+			deletes = append(deletes, id)
+			deleteEvents = append(deleteEvents, events...)
+			continue
+		}
+		var mdl t
+		err := json.Unmarshal([]byte(latest.Payload), &mdl)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			err = r.fail(outbox, events, err)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		upserts = append(upserts, &mdl)
+		upsertEvents = append(upsertEvents, events...)
+	}
+
+	apply := func(events []*entity.OutboxEvent, fn func() error) error {
+		if len(events) == 0 {
+			return nil
+		}
+		err := ctx.Err()
+		if err == nil {
+			err = fn()
+		}
+		if err == nil {
+			relayed = append(relayed, events...)
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		return r.fail(outbox, events, err)
+	}
+	err = apply(upsertEvents, func() error {
+		return r.document().IndexMany(upserts, typesense.DocumentActionUpsert)
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = apply(deleteEvents, func() error {
+		return r.document().DeleteManyWithQuery(idsFilter(deletes))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(relayed) > 0 {
+		err = outbox.DeleteByIds(eventIDs(relayed))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(relayed), firstErr
+}
+
+// Run : relays the pending events until the context is done , waits PollInterval when there is nothing to relay (or it failed)
+func (r *OutboxRelay[t]) Run(ctx context.Context) error {
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = OutboxPollInterval
+	}
+	for {
+		relayed, err := r.Relay(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+		if relayed > 0 && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RetryFailed : marks the failed events of the entity as pending with their attempts reset , returns the number of events retried
+func (r *OutboxRelay[t]) RetryFailed(ctx context.Context) (int64, error) {
+	outbox := r.Outbox.WithContext(ctx)
+	failed, err := r.events(outbox, entity.OutboxStatusFailed, nil)
+	if err != nil {
+		return 0, err
+	}
+	now := types.Timestamp(time.Now())
+	for _, event := range failed {
+		event.Status = entity.OutboxStatusPending
+		event.Attempts = 0
+		event.NextAttemptAt = now
+		err := outbox.Update(event)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(failed)), nil
+}
+
+// Reindex : full reindex of the collection from the source , every record is upserted (soft deleted records included)
+// and the documents of records that no longer exist are deleted
+//
+// changes made during the reindex are still relayed from the outbox afterwards
+func (r *OutboxRelay[t]) Reindex(ctx context.Context) error {
+	source := r.Source.WithContext(ctx)
+	sd, ok := source.(ISoftDelete[t])
+	if ok {
+		source = sd.IncludeDeleted()
+	}
+	var (
+		opts = rql.PaginationOptions{Base: 1, DefaultSize: r.batchSize(), Count: rql.CountNone}
+		ids  = make(map[string]bool)
+	)
+	for page := 1; ; page++ {
+		p, err := opts.New(page, r.batchSize())
+		if err != nil {
+			return err
+		}
+		res, err := source.GetWithFilterExpressionPaginated(nil, p, rql.SortBy("id").Asc())
+		if err != nil {
+			return err
+		}
+		for _, rec := range res.Records {
+			ids[(*rec).GetID()] = true
+		}
+		if len(res.Records) > 0 {
+			err = ctx.Err()
+			if err != nil {
+				return err
+			}
+			err = r.document().IndexMany(res.Records, typesense.DocumentActionUpsert)
+			if err != nil {
+				return err
+			}
+		}
+		if !res.HasNextPage {
+			break
+		}
+	}
+
+	exported, err := r.document().ExportAll()
+	if err != nil {
+		return err
+	}
+	var stale []string
+	scanner := bufio.NewScanner(bytes.NewReader(exported))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(exported)+1)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var doc struct {
+			ID string `json:"id"`
+		}
+		err := json.Unmarshal(scanner.Bytes(), &doc)
+		if err != nil {
+			return err
+		}
+		if doc.ID != "" && !ids[doc.ID] {
+			stale = append(stale, doc.ID)
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(stale); start += r.batchSize() {
+		end := start + r.batchSize()
+		if end > len(stale) {
+			end = len(stale)
+		}
+		err = ctx.Err()
+		if err != nil {
+			return err
+		}
+		err = r.document().DeleteManyWithQuery(idsFilter(stale[start:end]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/store/repository/boltrepo"
+	"github.com/baderkha/typesense"
+	"github.com/baderkha/typesense/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// outboxTestIndex : in memory document client recording the indexed documents
+type outboxTestIndex struct {
+	typesense.IDocumentClient[savedViewTestEntity]
+	docs map[string]savedViewTestEntity
+}
+
+func (o *outboxTestIndex) WithCollectionName(string) typesense.IDocumentClient[savedViewTestEntity] {
+	return o
+}
+
+func (o *outboxTestIndex) IndexMany(mdl []*savedViewTestEntity, action string) error {
+	for _, m := range mdl {
+		o.docs[m.ID] = *m
+	}
+	return nil
+}
+
+func newOutboxTestRepo(t *testing.T) (*repository.OutboxCrud[savedViewTestEntity], *boltrepo.CrudBolt[entity.OutboxEvent]) {
+	store, err := bolt.Open(filepath.Join(t.TempDir(), "outbox.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	outbox := &boltrepo.CrudBolt[entity.OutboxEvent]{DB: store}
+	return &repository.OutboxCrud[savedViewTestEntity]{
+		Repo:   &partialUpdateCrud{ICrud: &boltrepo.CrudBolt[savedViewTestEntity]{DB: store}},
+		Outbox: outbox,
+		Tx:     &boltrepo.BoltTransaction{DB: store},
+	}, outbox
+}
+
+func TestOutboxUpdatePayloadIsTheStoredRecord(t *testing.T) {
+	repo, outbox := newOutboxTestRepo(t)
+	rec := &savedViewTestEntity{Name: "a"}
+	rec.ID = "rec1"
+	rec.AccountID = "acc1"
+	err := repo.Create(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := &savedViewTestEntity{Name: "b"}
+	partial.ID = "rec1"
+	err = repo.Update(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := outbox.GetWithFilterExpression(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %d , want 2", len(events))
+	}
+	var (
+		sequences = map[int64]bool{}
+		latest    *entity.OutboxEvent
+	)
+	for _, event := range events {
+		if event.Sequence <= 0 || sequences[event.Sequence] {
+			t.Fatalf("sequence %d was not assigned by the store", event.Sequence)
+		}
+		sequences[event.Sequence] = true
+		if latest == nil || event.Sequence > latest.Sequence {
+			latest = event
+		}
+	}
+	var payload savedViewTestEntity
+	err = json.Unmarshal([]byte(latest.Payload), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Name != "b" || payload.AccountID != "acc1" {
+		t.Fatalf("payload = %+v , want the stored record", payload)
+	}
+}
+
+func TestOutboxRelaySkipsEventsInBackoff(t *testing.T) {
+	repo, outbox := newOutboxTestRepo(t)
+	index := &outboxTestIndex{docs: map[string]savedViewTestEntity{}}
+	relay := &repository.OutboxRelay[savedViewTestEntity]{Outbox: outbox, Index: index, BatchSize: 2}
+
+	// a full batch of older events waiting for a retry
+	for i := 0; i < 2; i++ {
+		event := &entity.OutboxEvent{
+			EntityName: savedViewTestEntity{}.TableName(),
+			RecordID:   fmt.Sprint("old", i),
+			Action:     entity.OutboxActionUpsert,
+			Payload:    fmt.Sprintf(`{"id":"old%d","name":"old"}`, i),
+			Status:     entity.OutboxStatusPending,
+		}
+		event.New()
+		event.NextAttemptAt = types.Timestamp(time.Now().Add(time.Hour))
+		err := outbox.Create(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := &savedViewTestEntity{Name: "new"}
+	rec.ID = "rec1"
+	err := repo.Create(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relayed, err := relay.Relay(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relayed != 1 || index.docs["rec1"].Name != "new" {
+		t.Fatalf("relayed = %d , docs = %v , want the due event relayed", relayed, index.docs)
+	}
+	if _, ok := index.docs["old0"]; ok {
+		t.Fatal("an event in backoff was relayed")
+	}
+}
@@ -0,0 +1,340 @@
+package repository
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/baderkha/library/pkg/err"
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/typesense/types"
+)
+
+const (
+	// SavedViewDefaultMaxPageSize : max page size a view can store if SavedViewRepo.MaxPageSize is not set
+	SavedViewDefaultMaxPageSize = 100
+)
+
+var (
+	ErrSavedViewNameEmpty      = errors.New("SavedView : name cannot be empty")
+	ErrSavedViewNotFound       = errors.New("SavedView : view not found")
+	ErrSavedViewShareWithOwner = errors.New("SavedView : a view cannot be shared with its owner")
+	ErrSavedViewUnknownEntity  = err.Compose("SavedView : entity `%s` does not support saved views")
+	ErrSavedViewPageSize       = err.Compose("SavedView : page size must be between 0 and %d")
+	ErrSavedViewBadFilter      = err.Compose("SavedView : filter is invalid for entity `%s` : %w")
+	ErrSavedViewBadSort        = err.Compose("SavedView : sort is invalid for entity `%s` : %w")
+
+	// ErrSavedViewInvalid : matches every SavedViewInvalidError (use errors.Is)
+	ErrSavedViewInvalid = errors.New("SavedView : the view is invalid")
+)
+
+// SavedViewInvalidError : the view failed validation (see SavedViewRepo.Validate) , Err is the reason
+//
+// Example :
+//			err := views.Create(view)
+//			if errors.Is(err, repository.ErrSavedViewInvalid) {
+//				// bad request
+//			}
+type SavedViewInvalidError struct {
+	Err error
+}
+
+func (e *SavedViewInvalidError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SavedViewInvalidError) Unwrap() error {
+	return e.Err
+}
+
+// Is : matches ErrSavedViewInvalid
+func (e *SavedViewInvalidError) Is(target error) bool {
+	return target == ErrSavedViewInvalid
+}
+
+// ISavedView : saved filters / views per account
+type ISavedView interface {
+	// Validate : validates the view and its stored expressions against the target entity's schema , the errors match ErrSavedViewInvalid
+	Validate(view *entity.SavedView) error
+	// Apply : decodes the stored expressions of the view , validating them against the current schema
+	Apply(view *entity.SavedView) (*AppliedView, error)
+
+	// Create : validate and create the view
+	Create(view *entity.SavedView) error
+	// Update : validate and update the view
+	Update(view *entity.SavedView) error
+	// Delete : delete the view and all of its shares
+	Delete(viewID string) error
+
+	// GetById : get 1 view by id if not found should return err
+	GetById(viewID string) (*entity.SavedView, error)
+	// GetVisibleToAccount : views owned by or shared with the account , entityName is optional
+	GetVisibleToAccount(accountID string, entityName string) ([]*entity.SavedView, error)
+	// IsOwnedBy : is the account the owner of the view
+	IsOwnedBy(viewID string, accountID string) bool
+	// CanRead : is the account the owner of the view or has it been shared with it
+	CanRead(viewID string, accountID string) bool
+
+	// Share : give an account read access to the view (sharing twice is a no op)
+	Share(viewID string, accountID string) error
+	// Unshare : remove the account's read access to the view
+	Unshare(viewID string, accountID string) error
+	// GetShares : the accounts the view has been shared with
+	GetShares(viewID string) ([]*entity.SavedViewShare, error)
+}
+
+var _ ISavedView = &SavedViewRepo{}
+
+// SavedViewTarget : an entity saved views can target
+type SavedViewTarget struct {
+	Schema *rql.Schema
+	// Validator : filter validator of the repository the views are applied to , defaults to rql.NewSQLFilterValidator() if nil
+	Validator rql.IFilterValidator
+}
+
+// NewSavedViewTarget : target for an entity using its `db` tags , validated by the validator of the repository that will apply the views
+// (rql.NewSQLFilterValidator() if nil)
+//
+// Example :
+//			targets := map[string]repository.SavedViewTarget{
+//				"accounts": repository.NewSavedViewTarget[entity.AccountPublic](gormrql.NewGormFilterParser(db)),
+//			}
+func NewSavedViewTarget[t any](validator rql.IFilterValidator) SavedViewTarget {
+	var mdl t
+	if validator == nil {
+		validator = rql.NewSQLFilterValidator()
+	}
+	return SavedViewTarget{
+		Schema:    rql.GetSchemaFromTaggedEntity(mdl, "db"),
+		Validator: validator,
+	}
+}
+
+// AppliedView : a saved view decoded and validated against its target entity's schema
+type AppliedView struct {
+	View   *entity.SavedView
+	Filter *rql.FilterExpression
+	Sort   *rql.SortExpression
+	// MaxPageSize : max page size of the repository that applied the view (see SavedViewRepo.MaxPageSize)
+	MaxPageSize int
+}
+
+// Pagination : pagination for a page using the page size of the view (capped at the max page size of the repository)
+func (a *AppliedView) Pagination(page string) (*rql.PaginationExpression, error) {
+	size := ""
+	if a.View.PageSize > 0 {
+		size = strconv.Itoa(a.View.PageSize)
+	}
+	opts := rql.PaginationOptions{
+		Base:        rql.DefaultPaginationOptions.Base,
+		DefaultSize: rql.DefaultPaginationOptions.DefaultSize,
+		MaxSize:     a.MaxPageSize,
+		Count:       rql.DefaultPaginationOptions.Count,
+	}
+	return opts.FromUserInput(page, size)
+}
+
+// SavedViewRepo : saved views on top of any ICrud implementation
+type SavedViewRepo struct {
+	Views  ICrud[entity.SavedView]
+	Shares ICrud[entity.SavedViewShare]
+	// Targets : entity name => target , views can only be saved for these entities
+	Targets map[string]SavedViewTarget
+	// MaxPageSize : defaults to SavedViewDefaultMaxPageSize
+	MaxPageSize int
+}
+
+func (r *SavedViewRepo) maxPageSize() int {
+	if r.MaxPageSize > 0 {
+		return r.MaxPageSize
+	}
+	return SavedViewDefaultMaxPageSize
+}
+
+// Validate : validates the view and its stored expressions against the target entity's schema ,
+// the errors match ErrSavedViewInvalid (see SavedViewInvalidError)
+func (r *SavedViewRepo) Validate(view *entity.SavedView) error {
+	if view.Name == "" {
+		return &SavedViewInvalidError{Err: ErrSavedViewNameEmpty}
+	}
+	if view.PageSize < 0 || view.PageSize > r.maxPageSize() {
+		return &SavedViewInvalidError{Err: ErrSavedViewPageSize(r.maxPageSize())}
+	}
+	_, err := r.Apply(view)
+	if err != nil {
+		return &SavedViewInvalidError{Err: err}
+	}
+	return nil
+}
+
+// Apply : decodes the stored expressions of the view , validating them against the current schema
+// (the entity may have changed since the view was saved)
+func (r *SavedViewRepo) Apply(view *entity.SavedView) (*AppliedView, error) {
+	target, ok := r.Targets[view.EntityName]
+	if !ok || target.Schema == nil {
+		return nil, ErrSavedViewUnknownEntity(view.EntityName)
+	}
+	filter, err := rql.FilterExpressionFromUserInput(view.Filter, false)
+	if err != nil {
+		return nil, ErrSavedViewBadFilter(view.EntityName, err)
+	}
+	validator := target.Validator
+	if validator == nil {
+		validator = rql.NewSQLFilterValidator()
+	}
+	err = validator.Validate(filter, target.Schema)
+	if err != nil {
+		return nil, ErrSavedViewBadFilter(view.EntityName, err)
+	}
+	sort, err := rql.SortExpressionFromUserInput(view.Sort)
+	if err != nil {
+		return nil, ErrSavedViewBadSort(view.EntityName, err)
+	}
+	err = sort.Validate(target.Schema)
+	if err != nil {
+		return nil, ErrSavedViewBadSort(view.EntityName, err)
+	}
+	return &AppliedView{View: view, Filter: filter, Sort: sort, MaxPageSize: r.maxPageSize()}, nil
+}
+
+// Create : validate and create the view
+func (r *SavedViewRepo) Create(view *entity.SavedView) error {
+	err := r.Validate(view)
+	if err != nil {
+		return err
+	}
+	return r.Views.Create(view)
+}
+
+// Update : validate and update the view , every column of the view is written (ie a cleared sort or page size)
+// when Views is an IWhere , the owner of the view is never changed
+func (r *SavedViewRepo) Update(view *entity.SavedView) error {
+	err := r.Validate(view)
+	if err != nil {
+		return err
+	}
+	view.UpdatedAt = types.Timestamp(time.Now())
+	where, ok := r.Views.(IWhere[entity.SavedView])
+	if !ok {
+		return r.Views.Update(view)
+	}
+	// ICrud.Update may skip the zero values (ie gorm's Updates) , a patch writes them
+	_, err = where.UpdateWhere(rql.Where(ColumnID).Eq(view.ID).MustBuild(), map[string]interface{}{
+		"name":          view.Name,
+		"entity_name":   view.EntityName,
+		"filter":        view.Filter,
+		"sort":          view.Sort,
+		"page_size":     view.PageSize,
+		ColumnUpdatedAt: view.UpdatedAt,
+	})
+	return err
+}
+
+// Delete : delete the view and all of its shares
+func (r *SavedViewRepo) Delete(viewID string) error {
+	shares, err := r.GetShares(viewID)
+	if err != nil {
+		return err
+	}
+	if len(shares) > 0 {
+		ids := make([]string, 0, len(shares))
+		for _, share := range shares {
+			ids = append(ids, share.ID)
+		}
+		err = r.Shares.DeleteByIds(ids)
+		if err != nil {
+			return err
+		}
+	}
+	return r.Views.DeleteById(viewID)
+}
+
+// GetById : get 1 view by id if not found should return err
+func (r *SavedViewRepo) GetById(viewID string) (*entity.SavedView, error) {
+	return r.Views.GetById(viewID)
+}
+
+// GetVisibleToAccount : views owned by or shared with the account , entityName is optional
+func (r *SavedViewRepo) GetVisibleToAccount(accountID string, entityName string) ([]*entity.SavedView, error) {
+	visibleTo := rql.Where("account_id").Eq(accountID)
+
+	shares, err := r.Shares.GetWithFilterExpression(rql.Where("account_id").Eq(accountID).MustBuild(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) > 0 {
+		viewIDs := make([]interface{}, 0, len(shares))
+		for _, share := range shares {
+			viewIDs = append(viewIDs, share.ViewID)
+		}
+		visibleTo = rql.Or(visibleTo, rql.Where("id").In(viewIDs...))
+	}
+	if entityName != "" {
+		visibleTo = visibleTo.And(rql.Where("entity_name").Eq(entityName))
+	}
+
+	filter, err := visibleTo.Build()
+	if err != nil {
+		return nil, err
+	}
+	return r.Views.GetWithFilterExpression(filter, rql.SortBy("name").Asc())
+}
+
+// IsOwnedBy : is the account the owner of the view
+func (r *SavedViewRepo) IsOwnedBy(viewID string, accountID string) bool {
+	return r.Views.IsForAccountID(viewID, accountID)
+}
+
+// CanRead : is the account the owner of the view or has it been shared with it
+func (r *SavedViewRepo) CanRead(viewID string, accountID string) bool {
+	if r.IsOwnedBy(viewID, accountID) {
+		return true
+	}
+	share, err := r.getShare(viewID, accountID)
+	return err == nil && share != nil
+}
+
+func (r *SavedViewRepo) getShare(viewID string, accountID string) (*entity.SavedViewShare, error) {
+	shares, err := r.Shares.GetWithFilterExpression(
+		rql.Where("view_id").Eq(viewID).And(rql.Where("account_id").Eq(accountID)).MustBuild(),
+		nil,
+	)
+	if err != nil || len(shares) == 0 {
+		return nil, err
+	}
+	return shares[0], nil
+}
+
+// Share : give an account read access to the view (sharing twice is a no op)
+func (r *SavedViewRepo) Share(viewID string, accountID string) error {
+	if r.IsOwnedBy(viewID, accountID) {
+		return ErrSavedViewShareWithOwner
+	}
+	if !r.Views.DoesIDExist(viewID) {
+		return ErrSavedViewNotFound
+	}
+	existing, err := r.getShare(viewID, accountID)
+	if err != nil || existing != nil {
+		return err
+	}
+	var share entity.SavedViewShare
+	share.New()
+	share.AccountID = accountID
+	share.ViewID = viewID
+	return r.Shares.Create(&share)
+}
+
+// Unshare : remove the account's read access to the view
+func (r *SavedViewRepo) Unshare(viewID string, accountID string) error {
+	share, err := r.getShare(viewID, accountID)
+	if err != nil || share == nil {
+		return err
+	}
+	return r.Shares.DeleteById(share.ID)
+}
+
+// GetShares : the accounts the view has been shared with
+func (r *SavedViewRepo) GetShares(viewID string) ([]*entity.SavedViewShare, error) {
+	return r.Shares.GetWithFilterExpression(rql.Where("view_id").Eq(viewID).MustBuild(), nil)
+}
@@ -0,0 +1,98 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/store/repository/boltrepo"
+	bolt "go.etcd.io/bbolt"
+)
+
+type savedViewTestEntity struct {
+	entity.BaseOwned
+	Name string `json:"name" db:"name"`
+}
+
+func (savedViewTestEntity) TableName() string { return "test_entities" }
+
+func newSavedViewTestRepo(views repository.ICrud[entity.SavedView], shares repository.ICrud[entity.SavedViewShare]) *repository.SavedViewRepo {
+	return &repository.SavedViewRepo{
+		Views:  views,
+		Shares: shares,
+		Targets: map[string]repository.SavedViewTarget{
+			"test_entities": repository.NewSavedViewTarget[savedViewTestEntity](nil),
+		},
+		MaxPageSize: 500,
+	}
+}
+
+func TestSavedViewUpdateClearsFields(t *testing.T) {
+	store, err := bolt.Open(filepath.Join(t.TempDir(), "views.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	r := newSavedViewTestRepo(&boltrepo.CrudBolt[entity.SavedView]{DB: store}, &boltrepo.CrudBolt[entity.SavedViewShare]{DB: store})
+
+	view := &entity.SavedView{
+		Name:       "mine",
+		EntityName: "test_entities",
+		Filter:     `{"operation":"AND","properties":[{"column":"name","op":"eq","value":"a"}]}`,
+		Sort:       "name::ASC",
+		PageSize:   50,
+	}
+	view.ID = "view1"
+	view.AccountID = "acc1"
+	err = r.Create(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view.Sort = ""
+	view.PageSize = 0
+	err = r.Update(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.GetById("view1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Sort != "" || got.PageSize != 0 {
+		t.Fatalf("sort = %q , page size = %d , want them cleared", got.Sort, got.PageSize)
+	}
+	if got.Name != "mine" || got.AccountID != "acc1" {
+		t.Fatalf("name = %q , account = %q , want them kept", got.Name, got.AccountID)
+	}
+}
+
+func TestAppliedViewPaginationUsesMaxPageSize(t *testing.T) {
+	r := newSavedViewTestRepo(nil, nil)
+	view := &entity.SavedView{Name: "mine", EntityName: "test_entities", Filter: `{"operation":"AND","properties":[]}`, PageSize: 200}
+	applied, err := r.Apply(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := applied.Pagination("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Size() != 200 {
+		t.Fatalf("size = %d , want 200", p.Size())
+	}
+	_, err = applied.Pagination("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	view.PageSize = 0
+	p, err = applied.Pagination("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Size() != rql.DefaultPaginationOptions.DefaultSize || p.Index() != 1 {
+		t.Fatalf("size = %d , index = %d", p.Size(), p.Index())
+	}
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"github.com/baderkha/library/pkg/err"
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+)
+
+var (
+	ErrSchemaViewUnsupported = err.Compose("SchemaView : repository of `%s` cannot be restricted to a schema view")
+)
+
+// ISchemaView : repo whose filter / sort / facet expressions can be restricted to a view of the schema of its model (see rql.Schema.ForRole) ,
+// the hidden columns are rejected by the parsers and validators the same way unknown columns are
+//
+// base expressions are written by the application (ie the account of the caller) , they keep using the full schema
+//
+// Example :
+//			schema := rql.GetSchemaFromTaggedEntityForRole(entity.Account{}, "db", role)
+//			res, err := accounts.WithSchema(schema).GetWithFilterExpressionPaginated(userFilter, p, userSort, accountBase)
+type ISchemaView[t any] interface {
+	// WithSchema : view of the repository whose filter / sort / facet expressions are parsed and validated against the schema
+	// (nil for the full schema of the model)
+	WithSchema(schema *rql.Schema) ICrud[t]
+}
+
+// WithSchema : the view of the repository restricted to the schema , fails if the repository is not an ISchemaView
+// (ie a decorator) instead of silently reading with the full schema
+func WithSchema[t entity.Model](repo ICrud[t], schema *rql.Schema) (ICrud[t], error) {
+	sv, ok := repo.(ISchemaView[t])
+	if !ok {
+		var m t
+		return nil, ErrSchemaViewUnsupported(m.TableName())
+	}
+	return sv.WithSchema(schema), nil
+}
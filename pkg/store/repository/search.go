@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/baderkha/library/pkg/conditional"
+	"github.com/baderkha/library/pkg/err"
+	"github.com/baderkha/library/pkg/rql"
+)
+
+var (
+	ErrSearchFieldDoesntExist = err.Compose("Search : field `%s` does not exist")
+	ErrSearchNumTypos         = err.Compose("Search : num typos must be between 0 and 2 got %d")
+	ErrSearchNegativeOption   = err.Compose("Search : %s cannot be negative got %d")
+)
+
+// ISearch : full text search returning the highlights and relevance of each record
+type ISearch[t any] interface {
+	// SearchWithFilterExpression : filter + sort + paginate a full text search
+	SearchWithFilterExpression(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, opts *SearchOptions, baseExpression ...*rql.FilterExpression) (*SearchResults[t], error)
+}
+
+// SearchOptions : query options of a full text search , zero values use the search engine's defaults
+//
+// Example :
+//			res, err := repo.SearchWithFilterExpression(f, p, nil, &repository.SearchOptions{
+//				Query:           "blue shirt",
+//				QueryBy:         []string{"name", "description"},
+//				HighlightFields: []string{"name"},
+//				SnippetLength:   8,
+//				NumTypos:        ptr.Get(1),
+//				Prefix:          ptr.Get(false),
+//			})
+type SearchOptions struct {
+	// Query : search term , overrides the term of a search operation in the filter (`*` matches everything)
+	Query string
+	// QueryBy : fields searched , overrides the fields of a search operation in the filter
+	QueryBy []string
+	// HighlightFields : fields to highlight , defaults to the QueryBy fields
+	HighlightFields []string
+	// HighlightFullFields : fields highlighted in full instead of a snippet
+	HighlightFullFields []string
+	// SnippetLength : number of tokens around the highlighted tokens of a snippet
+	SnippetLength int
+	// HighlightStartTag : defaults to `<mark>`
+	HighlightStartTag string
+	// HighlightEndTag : defaults to `</mark>`
+	HighlightEndTag string
+	// NumTypos : typo tolerance (0 to 2)
+	NumTypos *int
+	// Prefix : match the last token of the query as a prefix
+	Prefix *bool
+}
+
+// Validate : validates the fields of the options against the schema
+func (o *SearchOptions) Validate(schema *rql.Schema) error {
+	if o == nil {
+		return nil
+	}
+	for _, fields := range [][]string{o.QueryBy, o.HighlightFields, o.HighlightFullFields} {
+		for _, field := range fields {
+			if !schema.DoesColOrJSONPathExist(field) {
+				return ErrSearchFieldDoesntExist(field)
+			}
+		}
+	}
+	if o.NumTypos != nil && (*o.NumTypos < 0 || *o.NumTypos > 2) {
+		return ErrSearchNumTypos(*o.NumTypos)
+	}
+	if o.SnippetLength < 0 {
+		return ErrSearchNegativeOption("snippet length", o.SnippetLength)
+	}
+	return nil
+}
+
+// typesenseParams : the options as typesense search parameters
+func (o *SearchOptions) typesenseParams() map[string]string {
+	params := make(map[string]string)
+	if o == nil {
+		return params
+	}
+	if len(o.HighlightFields) > 0 {
+		params["highlight_fields"] = strings.Join(o.HighlightFields, ",")
+	}
+	if len(o.HighlightFullFields) > 0 {
+		params["highlight_full_fields"] = strings.Join(o.HighlightFullFields, ",")
+	}
+	if o.SnippetLength > 0 {
+		params["highlight_affix_num_tokens"] = strconv.Itoa(o.SnippetLength)
+	}
+	if o.HighlightStartTag != "" {
+		params["highlight_start_tag"] = o.HighlightStartTag
+	}
+	if o.HighlightEndTag != "" {
+		params["highlight_end_tag"] = o.HighlightEndTag
+	}
+	if o.NumTypos != nil {
+		params["num_typos"] = strconv.Itoa(*o.NumTypos)
+	}
+	if o.Prefix != nil {
+		params["prefix"] = conditional.Ternary(*o.Prefix, "true", "false")
+	}
+	return params
+}
+
+// SearchResults : paginated search hits with the search timing
+type SearchResults[t any] struct {
+	Paginated[SearchHit[t]]
+	// OutOf : number of records searched
+	OutOf int64 `json:"out_of"`
+	// SearchTimeMs : time the search engine took
+	SearchTimeMs int64 `json:"search_time_ms"`
+}
+
+// SearchHit : a matching record with its highlights and relevance
+type SearchHit[t any] struct {
+	Record     *t                `json:"record"`
+	Highlights []SearchHighlight `json:"highlights"`
+	// TextMatch : text match score , higher is more relevant
+	TextMatch uint64 `json:"text_match"`
+}
+
+// SearchHighlight : highlighted matches of 1 field ,
+// Snippet / Value are set for string fields , Snippets / Values for string array fields
+type SearchHighlight struct {
+	Field    string   `json:"field"`
+	Snippet  string   `json:"snippet,omitempty"`
+	Snippets []string `json:"snippets,omitempty"`
+	// Value : full field highlight (see SearchOptions.HighlightFullFields)
+	Value         string   `json:"value,omitempty"`
+	Values        []string `json:"values,omitempty"`
+	MatchedTokens []string `json:"matched_tokens"`
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/baderkha/library/pkg/err"
+	"github.com/baderkha/library/pkg/rql"
+)
+
+const (
+	// ColumnIsDeleted : soft delete flag column (see entity.Base)
+	ColumnIsDeleted = "is_deleted"
+	// ColumnUpdatedAt : update time column , for a soft deleted record it is the deletion time
+	ColumnUpdatedAt = "updated_at"
+)
+
+var (
+	ErrSoftDeleteNotFound    = errors.New("SoftDelete : record not found")
+	ErrSoftDeleteNegativeAge = errors.New("SoftDelete : purge age cannot be negative")
+
+	ErrSoftDeleteUnsupported = err.Compose("SoftDelete : model `%s` does not have an is_deleted column")
+)
+
+// ISoftDelete : repo that can soft delete records , soft deleted records are excluded from every read
+// (GetById , GetAll , the rql filtered queries ...) unless read through the IncludeDeleted view
+//
+// Example :
+//			err := repo.SoftDeleteById(id)
+//			_, err = repo.GetById(id) // not found
+//			rec, err := repo.IncludeDeleted().GetById(id) // rec.IsDeleted == true
+//			err = repo.Restore(id)
+//			purged, err := repo.PurgeDeletedOlderThan(30 * 24 * time.Hour)
+type ISoftDelete[t any] interface {
+	// SoftDeleteById : mark the record as deleted
+	SoftDeleteById(id string) error
+	// SoftDeleteByIds : mark many records as deleted
+	SoftDeleteByIds(ids []string) error
+	// Restore : unmark a soft deleted record
+	Restore(id string) error
+	// PurgeDeletedOlderThan : perma delete the records soft deleted more than age ago , returns the number of records purged
+	PurgeDeletedOlderThan(age time.Duration) (int64, error)
+	// IncludeDeleted : view of the repository whose reads also return soft deleted records
+	IncludeDeleted() ICrud[t]
+}
+
+// SoftDeleteColumns : internal names of the soft delete columns , empty if the model cannot be soft deleted
+func SoftDeleteColumns(schema *rql.Schema) (isDeleted string, updatedAt string) {
+	return schema.GetColumnInternalName(ColumnIsDeleted), schema.GetColumnInternalName(ColumnUpdatedAt)
+}
@@ -0,0 +1,824 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baderkha/library/pkg/conditional"
+	"github.com/baderkha/library/pkg/db"
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+	"github.com/baderkha/library/pkg/store/repository"
+	"github.com/baderkha/library/pkg/store/repository/internal/dbtag"
+)
+
+const (
+	// SQLBatchSize : records per insert of BulkCreate (same as gormrepo.GormBatchSize)
+	SQLBatchSize = 3000
+)
+
+var _ repository.ICrud[entity.SavedView] = &CrudSQL[entity.SavedView]{}
+var _ repository.ISoftDelete[entity.SavedView] = &CrudSQL[entity.SavedView]{}
+var _ repository.IWhere[entity.SavedView] = &CrudSQL[entity.SavedView]{}
+var _ repository.ISchemaView[entity.SavedView] = &CrudSQL[entity.SavedView]{}
+
+// sqlExecutor : *sql.DB or *sql.Tx
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// CrudSQL : repository.ICrud built on database/sql (no gorm) , the records are mapped to the `db` tags of the model
+// (embedded structs included , see entity.Base) and the filtered reads use the rql sql parsers
+//
+// time columns are scanned as time.Time (use parseTime=true in mysql dsns) , struct / map / slice fields are stored as json ,
+// records not found return sql.ErrNoRows
+//
+// Example :
+//			conn, _ := sql.Open("pgx", dsn)
+//			repo := &sqlrepo.CrudSQL[entity.SavedView]{DB: conn, Dialect: db.DialectPostgres}
+//			tx := (&sqlrepo.SQLTransaction{DB: conn}).Begin()
+//			err := repo.WithTransaction(tx).Create(view)
+//			err = tx.Commit()
+type CrudSQL[t entity.Model] struct {
+	DB *sql.DB
+	// Dialect : db.DialectMYSQL / db.DialectPostgres , sets the placeholders ($1 for postgres) , the identifier quotes
+	// and the dialect specific rql operators , leave empty for generic sql with `?` placeholders
+	Dialect string
+	// Parser : filter parser , defaults to rql.NewSQLFilterParser(Dialect) if nil
+	Parser rql.IFilterParser[rql.SQLOutput]
+	// Sorter : sort parser , defaults to rql.SortParserSQL{Dialect: Dialect} if nil
+	Sorter rql.ISortParser[rql.SQLSortOutput]
+
+	// tx : transaction of the repository (see WithTransaction)
+	tx *SQLTransaction
+	// ctx : context of the statements (see WithContext)
+	ctx context.Context
+	// includeDeleted : reads return soft deleted records (see IncludeDeleted)
+	includeDeleted bool
+	// schemaView : schema of the filter / sort / facet expressions (see WithSchema)
+	schemaView *rql.Schema
+}
+
+func (c *CrudSQL[t]) Model() t {
+	var m t
+	return m
+}
+
+func (c *CrudSQL[t]) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// executor : the transaction of the repository if it has one , the db otherwise
+func (c *CrudSQL[t]) executor() (sqlExecutor, error) {
+	if c.tx == nil {
+		return c.DB, nil
+	}
+	if c.tx.err != nil {
+		return nil, c.tx.err
+	}
+	if c.tx.tx == nil {
+		return c.DB, nil
+	}
+	return c.tx.tx, nil
+}
+
+func (c *CrudSQL[t]) exec(query string, args ...interface{}) (sql.Result, error) {
+	ex, err := c.executor()
+	if err != nil {
+		return nil, err
+	}
+	query, args = bindSQL(c.Dialect, query, args)
+	return ex.ExecContext(c.context(), query, args...)
+}
+
+func (c *CrudSQL[t]) query(query string, args ...interface{}) (*sql.Rows, error) {
+	ex, err := c.executor()
+	if err != nil {
+		return nil, err
+	}
+	query, args = bindSQL(c.Dialect, query, args)
+	return ex.QueryContext(c.context(), query, args...)
+}
+
+// queryRow : scans the only row of the query into dest
+func (c *CrudSQL[t]) queryRow(dest []interface{}, query string, args ...interface{}) error {
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		err = rows.Err()
+		return conditional.Ternary(err != nil, err, sql.ErrNoRows)
+	}
+	err = rows.Scan(dest...)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+func (c *CrudSQL[t]) columns() []dbtag.Column {
+	return dbtag.ColumnsOf(reflect.TypeOf(c.Model()))
+}
+
+// writableColumns : the columns written by inserts / updates , auto incremented columns are assigned by the database
+func (c *CrudSQL[t]) writableColumns() []dbtag.Column {
+	var cols []dbtag.Column
+	for _, col := range c.columns() {
+		if !col.AutoIncrement {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+func (c *CrudSQL[t]) schema() *rql.Schema {
+	return rql.GetSchemaFromTaggedEntity(c.Model(), "db")
+}
+
+// filterSchema : schema of the filter / sort / facet expressions , the full schema unless restricted (see WithSchema)
+func (c *CrudSQL[t]) filterSchema() *rql.Schema {
+	if c.schemaView != nil {
+		return c.schemaView
+	}
+	return c.schema()
+}
+
+func (c *CrudSQL[t]) quote(name string) string {
+	return quoteSQL(c.Dialect, name)
+}
+
+func (c *CrudSQL[t]) table() string {
+	return c.quote(c.Model().TableName())
+}
+
+// idColumn : the id column of the model , `id` when GetIDKey is not a column of the model
+func (c *CrudSQL[t]) idColumn() string {
+	key := c.Model().GetIDKey()
+	if key == "" || dbtag.FindColumn(c.columns(), key) == nil {
+		return c.quote(repository.ColumnID)
+	}
+	return c.quote(key)
+}
+
+// selectColumns : the quoted columns of the model in the order they are scanned
+func (c *CrudSQL[t]) selectColumns() string {
+	return c.quoteColumns(c.columns())
+}
+
+// quoteColumns : the quoted columns separated by commas
+func (c *CrudSQL[t]) quoteColumns(cols []dbtag.Column) string {
+	quoted := make([]string, 0, len(cols))
+	for _, col := range cols {
+		quoted = append(quoted, c.quote(col.Name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// where : joins the conditions , excluding the soft deleted records
+func (c *CrudSQL[t]) where(conditions ...string) string {
+	isDeleted, _ := repository.SoftDeleteColumns(c.schema())
+	if !c.includeDeleted && isDeleted != "" {
+		conditions = append(conditions, c.quote(isDeleted)+" = false")
+	}
+	var nonEmpty []string
+	for _, cond := range conditions {
+		if strings.TrimSpace(cond) != "" {
+			nonEmpty = append(nonEmpty, cond)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(nonEmpty, " AND ")
+}
+
+func (c *CrudSQL[t]) find(query string, args ...interface{}) ([]*t, error) {
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanSQLRows[t](rows, c.columns())
+}
+
+func (c *CrudSQL[t]) DoesIDExist(id string) bool {
+	obj, err := c.GetById(id)
+	return err == nil && obj != nil
+}
+
+// GetById : get 1 record by id if not found should return err
+func (c *CrudSQL[t]) GetById(id string) (*t, error) {
+	res, err := c.find(
+		"SELECT "+c.selectColumns()+" FROM "+c.table()+c.where(c.idColumn()+" = ?")+" LIMIT 1",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return res[0], nil
+}
+
+// GetAll : get all the records (db dump)
+func (c *CrudSQL[t]) GetAll() ([]*t, error) {
+	return c.find("SELECT " + c.selectColumns() + " FROM " + c.table() + c.where())
+}
+
+func (c *CrudSQL[t]) IsForAccountID(id string, accountID string) bool {
+	var count int64
+	err := c.queryRow(
+		[]interface{}{&count},
+		"SELECT COUNT(*) FROM "+c.table()+c.where(c.idColumn()+" = ?", c.quote("account_id")+" = ?"),
+		id, accountID,
+	)
+	return err == nil && count > 0
+}
+
+func (c *CrudSQL[t]) parser() rql.IFilterParser[rql.SQLOutput] {
+	if c.Parser != nil {
+		return c.Parser
+	}
+	return rql.NewSQLFilterParser(c.Dialect)
+}
+
+func (c *CrudSQL[t]) sorter() rql.ISortParser[rql.SQLSortOutput] {
+	if c.Sorter != nil {
+		return c.Sorter
+	}
+	return rql.SortParserSQL{Dialect: c.Dialect}
+}
+
+// filterSQL : compiles the filter + base expression into a where clause excluding the soft deleted records ,
+// the filter is parsed against the schema view and the base expressions against the full schema
+func (c *CrudSQL[t]) filterSQL(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (where string, args []interface{}, err error) {
+	var conditions []string
+	for i, expression := range append([]*rql.FilterExpression{f}, baseExpression...) {
+		if repository.IsEmptyFilter(expression) {
+			continue
+		}
+		schema := c.schema()
+		if i == 0 {
+			schema = c.filterSchema()
+		}
+		validator, ok := c.parser().(rql.IFilterValidator)
+		if ok {
+			err := validator.Validate(expression, schema)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		out, err := c.parser().Parse(expression, schema)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, out.Query)
+		args = append(args, out.Args...)
+	}
+	return c.where(conditions...), args, nil
+}
+
+// sortSQL : compiles the sort expression into an order by clause
+func (c *CrudSQL[t]) sortSQL(s *rql.SortExpression) (orderBy string, args []interface{}, err error) {
+	if s == nil {
+		return "", nil, nil
+	}
+	out, err := c.sorter().Parse(s, c.filterSchema())
+	if err != nil {
+		return "", nil, err
+	}
+	return conditional.Ternary(out.RawQuery != "", " "+out.RawQuery, ""), out.Args, nil
+}
+
+// GetWithFilterExpression : filter + sort a result using the rql package
+func (c *CrudSQL[t]) GetWithFilterExpression(f *rql.FilterExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data []*t, err error) {
+	where, whereArgs, err := c.filterSQL(f, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, orderArgs, err := c.sortSQL(s)
+	if err != nil {
+		return nil, err
+	}
+	return c.find(
+		"SELECT "+c.selectColumns()+" FROM "+c.table()+where+orderBy,
+		append(whereArgs, orderArgs...)...,
+	)
+}
+
+// GetWithFilterExpressionPaginated : filter + sort a result query with pagination using the rql package
+//
+// the total is computed using the count mode of the pagination expression (see rql.CountMode) ,
+// a nil pagination expression is the first page of rql.DefaultPaginationOptions .
+// the page and the count run one after the other so they can share a transaction
+func (c *CrudSQL[t]) GetWithFilterExpressionPaginated(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, baseExpression ...*rql.FilterExpression) (data *repository.Paginated[t], err error) {
+	var (
+		mode  rql.CountMode
+		count int64 = -1
+	)
+	if p == nil {
+		p = rql.DefaultPaginationOptions.First()
+	}
+	where, whereArgs, err := c.filterSQL(f, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, orderArgs, err := c.sortSQL(s)
+	if err != nil {
+		return nil, err
+	}
+	mode = p.CountMode()
+	if mode == rql.CountEstimated && !(repository.IsEmptyFilter(f) && (len(baseExpression) == 0 || repository.IsEmptyFilter(baseExpression[0]))) {
+		mode = rql.CountExact
+	}
+	limit := conditional.Ternary(mode == rql.CountExact, p.Size(), p.Size()+1)
+
+	records, err := c.find(
+		"SELECT "+c.selectColumns()+" FROM "+c.table()+where+orderBy+
+			" LIMIT "+strconv.Itoa(limit)+" OFFSET "+strconv.Itoa(p.Offset()),
+		append(append([]interface{}{}, whereArgs...), orderArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if mode == rql.CountEstimated {
+		estimate, ok := c.estimateCount()
+		if ok {
+			count = estimate
+		} else {
+			mode = rql.CountExact
+		}
+	}
+	if mode == rql.CountExact {
+		err = c.queryRow([]interface{}{&count}, "SELECT COUNT(*) FROM "+c.table()+where, whereArgs...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hasNext := len(records) > p.Size()
+	if hasNext {
+		records = records[:p.Size()]
+	}
+	return repository.NewPaginated(p, records, count, mode, hasNext), nil
+}
+
+// estimateCount : row count of the table from the database statistics , false if not available
+func (c *CrudSQL[t]) estimateCount() (int64, bool) {
+	var (
+		estimate sql.NullInt64
+		err      error
+	)
+	switch c.Dialect {
+	case db.DialectMYSQL:
+		err = c.queryRow(
+			[]interface{}{&estimate},
+			"SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+			c.Model().TableName(),
+		)
+	case db.DialectPostgres:
+		// reltuples is -1 for tables that were never vacuumed / analyzed
+		err = c.queryRow(
+			[]interface{}{&estimate},
+			"SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass(?)",
+			c.Model().TableName(),
+		)
+	default:
+		return 0, false
+	}
+	if err != nil || !estimate.Valid || estimate.Int64 < 0 {
+		return 0, false
+	}
+	return estimate.Int64, true
+}
+
+// GetWithFilterExpressionFaceted : same as GetWithFilterExpressionPaginated with the value counts of the faceted columns over all the matching records
+//
+// each facet is a grouped count over a subquery of the filter + base expression (null values are not counted)
+func (c *CrudSQL[t]) GetWithFilterExpressionFaceted(f *rql.FilterExpression, p *rql.PaginationExpression, s *rql.SortExpression, facets *rql.FacetExpression, baseExpression ...*rql.FilterExpression) (data *repository.Faceted[t], err error) {
+	var (
+		schema   = c.filterSchema()
+		byColumn = make(map[string]*repository.FacetResult)
+	)
+	if facets == nil {
+		return nil, rql.ErrFacetEmpty
+	}
+	err = facets.ValidateForSQL(schema)
+	if err != nil {
+		return nil, err
+	}
+	where, whereArgs, err := c.filterSQL(f, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	page, err := c.GetWithFilterExpressionPaginated(f, p, s, baseExpression...)
+	if err != nil {
+		return nil, err
+	}
+	source := "(SELECT * FROM " + c.table() + where + ") AS facet_source"
+	for _, col := range facets.Columns() {
+		facet, err := c.facet(source, whereArgs, schema, col, facets.GetMaxValues())
+		if err != nil {
+			return nil, err
+		}
+		byColumn[col] = facet
+	}
+	return repository.NewFaceted(page, facets, byColumn), nil
+}
+
+// facet : most frequent values of the column (and its stats if numeric) over the filtered records
+func (c *CrudSQL[t]) facet(source string, args []interface{}, schema *rql.Schema, col string, maxValues int) (*repository.FacetResult, error) {
+	var (
+		column = c.quote(schema.GetColumnInternalName(col))
+		res    = repository.FacetResult{Column: col}
+	)
+	rows, err := c.query(
+		"SELECT "+column+" AS facet_value, COUNT(*) AS facet_count FROM "+source+
+			" WHERE "+column+" IS NOT NULL GROUP BY "+column+
+			" ORDER BY facet_count DESC, facet_value ASC LIMIT "+strconv.Itoa(maxValues),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res.Counts = make([]repository.FacetCount, 0)
+	for rows.Next() {
+		var (
+			value sql.NullString
+			count int64
+		)
+		err := rows.Scan(&value, &count)
+		if err != nil {
+			return nil, err
+		}
+		res.Counts = append(res.Counts, repository.FacetCount{Value: value.String, Count: count})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if !schema.IsNumericColumn(col) {
+		return &res, nil
+	}
+
+	var (
+		min, max, sum, avg sql.NullFloat64
+		total              int64
+	)
+	err = c.queryRow(
+		[]interface{}{&min, &max, &sum, &avg, &total},
+		"SELECT MIN("+column+"), MAX("+column+"), SUM("+column+"), AVG("+column+"), COUNT(DISTINCT "+column+") FROM "+source,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	res.Stats = &repository.FacetStats{
+		Min:         min.Float64,
+		Max:         max.Float64,
+		Sum:         sum.Float64,
+		Avg:         avg.Float64,
+		TotalValues: total,
+	}
+	return &res, nil
+}
+
+// insert : inserts the records in 1 statement
+func (c *CrudSQL[t]) insert(mdl []*t) error {
+	var (
+		cols         = c.writableColumns()
+		placeholders = "(" + strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + ")"
+		values       = make([]string, 0, len(mdl))
+		args         = make([]interface{}, 0, len(mdl)*len(cols))
+		now          = time.Now()
+	)
+	for _, m := range mdl {
+		repository.InitVersion(m)
+		v := reflect.ValueOf(m).Elem()
+		dbtag.SetTimeColumn(v, cols, "created_at", now, true)
+		dbtag.SetTimeColumn(v, cols, repository.ColumnUpdatedAt, now, true)
+		for _, col := range cols {
+			value, err := sqlValue(v.FieldByIndex(col.Index))
+			if err != nil {
+				return err
+			}
+			args = append(args, value)
+		}
+		values = append(values, placeholders)
+	}
+	_, err := c.exec(
+		"INSERT INTO "+c.table()+" ("+c.quoteColumns(cols)+") VALUES "+strings.Join(values, ", "),
+		args...,
+	)
+	return err
+}
+
+// Create : create one
+func (c *CrudSQL[t]) Create(mdl *t) error {
+	return c.insert([]*t{mdl})
+}
+
+// BulkCreate : create many , inserted in batches of SQLBatchSize records (less for wide models so a batch stays under the bound parameters limit) ,
+// the batches run in a transaction unless the repository already uses one
+func (c *CrudSQL[t]) BulkCreate(mdl []*t) error {
+	if len(mdl) == 0 {
+		return nil
+	}
+	batchSize := SQLBatchSize
+	cols := len(c.writableColumns())
+	if cols > 0 && batchSize*cols > sqlMaxParams {
+		batchSize = sqlMaxParams / cols
+	}
+	if len(mdl) <= batchSize || c.tx != nil {
+		return c.insertBatches(mdl, batchSize)
+	}
+
+	tx := (&SQLTransaction{DB: c.DB, ctx: c.ctx}).Begin()
+	repo := c.WithTransaction(tx).(*CrudSQL[t])
+	err := repo.insertBatches(mdl, batchSize)
+	if err != nil {
+		tx.RollBack()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *CrudSQL[t]) insertBatches(mdl []*t, batchSize int) error {
+	for start := 0; start < len(mdl); start += batchSize {
+		end := start + batchSize
+		if end > len(mdl) {
+			end = len(mdl)
+		}
+		err := c.insert(mdl[start:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update : update model , every column but the id (and the auto incremented ones) is written (zero values included) and the update time is set to now
+//
+// versioned models (see entity.BaseVersioned) are only updated if the stored version is the version of the model ,
+// the version is then incremented , a *repository.VersionConflictError is returned otherwise
+func (c *CrudSQL[t]) Update(mdl *t) error {
+	var (
+		cols   = c.columns()
+		v      = reflect.ValueOf(mdl).Elem()
+		idCol  = c.idColumn()
+		sets   []string
+		args   []interface{}
+		wheres = []string{idCol + " = ?"}
+	)
+	versioned, versionCol := repository.VersionOf(mdl)
+	var version int64
+	if versioned != nil {
+		version = versioned.GetVersion()
+		versioned.SetVersion(version + 1)
+	}
+	dbtag.SetTimeColumn(v, cols, repository.ColumnUpdatedAt, time.Now(), false)
+	for _, col := range c.writableColumns() {
+		if c.quote(col.Name) == idCol {
+			continue
+		}
+		value, err := sqlValue(v.FieldByIndex(col.Index))
+		if err != nil {
+			return err
+		}
+		sets = append(sets, c.quote(col.Name)+" = ?")
+		args = append(args, value)
+	}
+	args = append(args, (*mdl).GetID())
+	if versioned != nil {
+		wheres = append(wheres, c.quote(versionCol)+" = ?")
+		args = append(args, version)
+	}
+
+	res, err := c.exec("UPDATE "+c.table()+" SET "+strings.Join(sets, ", ")+" WHERE "+strings.Join(wheres, " AND "), args...)
+	if versioned == nil {
+		return err
+	}
+	if err != nil {
+		versioned.SetVersion(version)
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		versioned.SetVersion(version)
+		return err
+	}
+	if affected == 0 {
+		versioned.SetVersion(version)
+		return &repository.VersionConflictError{Table: c.Model().TableName(), ID: (*mdl).GetID(), Version: version}
+	}
+	return nil
+}
+
+// DeleteById : perma delete model by id
+func (c *CrudSQL[t]) DeleteById(id string) error {
+	return c.DeleteByIds([]string{id})
+}
+
+// DeleteByIds : perma delet by many ids
+func (c *CrudSQL[t]) DeleteByIds(id []string) error {
+	if len(id) == 0 {
+		return nil
+	}
+	_, err := c.exec("DELETE FROM "+c.table()+" WHERE "+c.idColumn()+" IN (?)", id)
+	return err
+}
+
+// WithTransaction : transactional pointer , the transaction must be an *SQLTransaction
+func (c *CrudSQL[t]) WithTransaction(tx repository.ITransaction) repository.ICrud[t] {
+	cp := *c
+	cp.tx = tx.(*SQLTransaction)
+	return &cp
+}
+
+// WithContext : view of the repository whose statements use the context (cancellation , deadlines , tracing)
+func (c *CrudSQL[t]) WithContext(ctx context.Context) repository.ICrud[t] {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
+
+// WithSchema : view of the repository whose filter / sort / facet expressions are parsed and validated against the schema
+// (ie rql.Schema.ForRole) , the base expressions keep using the full schema
+func (c *CrudSQL[t]) WithSchema(schema *rql.Schema) repository.ICrud[t] {
+	cp := *c
+	cp.schemaView = schema
+	return &cp
+}
+
+// setDeleted : soft deletes / restores the records that are not in the state yet , the update time is set to now (and the version incremented)
+func (c *CrudSQL[t]) setDeleted(ids []string, isDeleted bool) (int64, error) {
+	colDeleted, colUpdated := repository.SoftDeleteColumns(c.schema())
+	if colDeleted == "" {
+		return 0, repository.ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	sets := []string{c.quote(colDeleted) + " = ?"}
+	args := []interface{}{isDeleted}
+	if colUpdated != "" {
+		sets = append(sets, c.quote(colUpdated)+" = ?")
+		args = append(args, time.Now())
+	}
+	colVersion := c.schema().GetColumnInternalName(repository.ColumnVersion)
+	if colVersion != "" {
+		sets = append(sets, c.quote(colVersion)+" = "+c.quote(colVersion)+" + 1")
+	}
+	res, err := c.exec(
+		"UPDATE "+c.table()+" SET "+strings.Join(sets, ", ")+" WHERE "+c.idColumn()+" IN (?) AND "+c.quote(colDeleted)+" = ?",
+		append(args, ids, !isDeleted)...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SoftDeleteById : mark the record as deleted
+func (c *CrudSQL[t]) SoftDeleteById(id string) error {
+	_, err := c.setDeleted([]string{id}, true)
+	return err
+}
+
+// SoftDeleteByIds : mark many records as deleted
+func (c *CrudSQL[t]) SoftDeleteByIds(ids []string) error {
+	_, err := c.setDeleted(ids, true)
+	return err
+}
+
+// Restore : unmark a soft deleted record , repository.ErrSoftDeleteNotFound if there is no soft deleted record with the id
+func (c *CrudSQL[t]) Restore(id string) error {
+	restored, err := c.setDeleted([]string{id}, false)
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return repository.ErrSoftDeleteNotFound
+	}
+	return nil
+}
+
+// PurgeDeletedOlderThan : perma delete the records soft deleted more than age ago , returns the number of records purged
+func (c *CrudSQL[t]) PurgeDeletedOlderThan(age time.Duration) (int64, error) {
+	colDeleted, colUpdated := repository.SoftDeleteColumns(c.schema())
+	if colDeleted == "" || colUpdated == "" {
+		return 0, repository.ErrSoftDeleteUnsupported(c.Model().TableName())
+	}
+	if age < 0 {
+		return 0, repository.ErrSoftDeleteNegativeAge
+	}
+	res, err := c.exec(
+		"DELETE FROM "+c.table()+" WHERE "+c.quote(colDeleted)+" = ? AND "+c.quote(colUpdated)+" < ?",
+		true, time.Now().Add(-age),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// IncludeDeleted : view of the repository whose reads also return soft deleted records
+func (c *CrudSQL[t]) IncludeDeleted() repository.ICrud[t] {
+	cp := *c
+	cp.includeDeleted = true
+	return &cp
+}
+
+// CountWhere : number of records matching the filter
+func (c *CrudSQL[t]) CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	var count int64
+	where, args, err := c.filterSQL(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	err = c.queryRow([]interface{}{&count}, "SELECT COUNT(*) FROM "+c.table()+where, args...)
+	return count, err
+}
+
+// ExistsWhere : at least 1 record matches the filter (counts a subquery limited to 1 record)
+func (c *CrudSQL[t]) ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error) {
+	var count int64
+	where, args, err := c.filterSQL(f, baseExpression...)
+	if err != nil {
+		return false, err
+	}
+	err = c.queryRow([]interface{}{&count}, "SELECT COUNT(*) FROM (SELECT 1 FROM "+c.table()+where+" LIMIT 1) exists_source", args...)
+	return count > 0, err
+}
+
+// UpdateWhere : sets the columns of the patch on the records matching the filter in 1 statement , returns the number of records updated
+func (c *CrudSQL[t]) UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := repository.RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	updates, err := repository.PatchColumns(c.schema(), patch, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	var (
+		sets []string
+		args []interface{}
+	)
+	for _, col := range sortedColumns(updates) {
+		sets = append(sets, c.quote(col)+" = ?")
+		args = append(args, updates[col])
+	}
+	colVersion := c.schema().GetColumnInternalName(repository.ColumnVersion)
+	if colVersion != "" {
+		sets = append(sets, c.quote(colVersion)+" = "+c.quote(colVersion)+" + 1")
+	}
+	where, whereArgs, err := c.filterSQL(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.exec("UPDATE "+c.table()+" SET "+strings.Join(sets, ", ")+where, append(args, whereArgs...)...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteWhere : perma deletes the records matching the filter in 1 statement , returns the number of records deleted
+func (c *CrudSQL[t]) DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error) {
+	err := repository.RequireFilter(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	where, args, err := c.filterSQL(f, baseExpression...)
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.exec("DELETE FROM "+c.table()+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// sortedColumns : the columns of the updates in a stable order
+func sortedColumns(updates map[string]interface{}) []string {
+	cols := make([]string, 0, len(updates))
+	for col := range updates {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
@@ -0,0 +1,188 @@
+package sqlrepo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/baderkha/library/pkg/db"
+	"github.com/baderkha/library/pkg/store/repository/internal/dbtag"
+)
+
+const (
+	// sqlMaxParams : max bound parameters of a statement (postgres limit) , bulk insert batches are capped to it
+	sqlMaxParams = 65535
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isJSONType : structs , maps and slices (except []byte) are stored as json
+func isJSONType(tpe reflect.Type) bool {
+	switch tpe.Kind() {
+	case reflect.Struct, reflect.Map:
+		return true
+	case reflect.Slice:
+		return tpe.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// sqlValue : the value bound for the field
+func sqlValue(field reflect.Value) (interface{}, error) {
+	tpe := field.Type()
+	switch {
+	case tpe.Implements(valuerType):
+		return field.Interface(), nil
+	case reflect.PtrTo(tpe).Implements(valuerType):
+		return field.Addr().Interface(), nil
+	case dbtag.IsTimeType(tpe):
+		return field.Convert(dbtag.TimeType).Interface(), nil
+	case isJSONType(tpe):
+		b, err := json.Marshal(field.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	return field.Interface(), nil
+}
+
+// sqlScanTarget : the destination passed to rows.Scan for the field , assign copies it into the field once scanned
+// (null values leave the field to its zero value)
+func sqlScanTarget(field reflect.Value) (dest interface{}, assign func() error) {
+	tpe := field.Type()
+	switch {
+	case reflect.PtrTo(tpe).Implements(scannerType):
+		return field.Addr().Interface(), func() error { return nil }
+	case dbtag.IsTimeType(tpe):
+		var nt sql.NullTime
+		return &nt, func() error {
+			if nt.Valid {
+				field.Set(reflect.ValueOf(nt.Time).Convert(tpe))
+			}
+			return nil
+		}
+	case isJSONType(tpe):
+		var b []byte
+		return &b, func() error {
+			if len(b) == 0 {
+				return nil
+			}
+			return json.Unmarshal(b, field.Addr().Interface())
+		}
+	}
+	ptr := reflect.New(reflect.PtrTo(tpe))
+	return ptr.Interface(), func() error {
+		if !ptr.Elem().IsNil() {
+			field.Set(ptr.Elem().Elem())
+		}
+		return nil
+	}
+}
+
+// scanSQLRows : maps the rows (selected in the order of the columns) to entities
+func scanSQLRows[t any](rows *sql.Rows, cols []dbtag.Column) ([]*t, error) {
+	defer rows.Close()
+	var (
+		res     []*t
+		dests   = make([]interface{}, len(cols))
+		assigns = make([]func() error, len(cols))
+	)
+	for rows.Next() {
+		var mdl t
+		v := reflect.ValueOf(&mdl).Elem()
+		for i, col := range cols {
+			dests[i], assigns[i] = sqlScanTarget(v.FieldByIndex(col.Index))
+		}
+		err := rows.Scan(dests...)
+		if err != nil {
+			return nil, err
+		}
+		for _, assign := range assigns {
+			err := assign()
+			if err != nil {
+				return nil, err
+			}
+		}
+		res = append(res, &mdl)
+	}
+	return res, rows.Err()
+}
+
+// quoteSQL : quotes the identifier for the dialect (left as is for generic sql)
+func quoteSQL(dialect string, name string) string {
+	switch dialect {
+	case db.DialectMYSQL:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	case db.DialectPostgres:
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return name
+}
+
+// bindSQL : expands the slice arguments (ie `IN (?)`) into 1 placeholder per value and numbers the placeholders for postgres ($1 , $2 ...) ,
+// question marks in quoted strings / identifiers are left as is
+func bindSQL(dialect string, query string, args []interface{}) (string, []interface{}) {
+	var (
+		out     strings.Builder
+		bound   = make([]interface{}, 0, len(args))
+		argIdx  int
+		quote   rune
+		numbers = dialect == db.DialectPostgres
+	)
+	placeholder := func(value interface{}) {
+		bound = append(bound, value)
+		if numbers {
+			out.WriteString("$" + strconv.Itoa(len(bound)))
+			return
+		}
+		out.WriteByte('?')
+	}
+	for _, r := range query {
+		if quote != 0 {
+			if r == quote {
+				quote = 0
+			}
+			out.WriteRune(r)
+			continue
+		}
+		switch r {
+		case '\'', '"', '`':
+			quote = r
+			out.WriteRune(r)
+			continue
+		case '?':
+		default:
+			out.WriteRune(r)
+			continue
+		}
+		if argIdx >= len(args) {
+			out.WriteRune(r)
+			continue
+		}
+		arg := args[argIdx]
+		argIdx++
+		value := reflect.ValueOf(arg)
+		if arg == nil || value.Kind() != reflect.Slice || value.Type().Elem().Kind() == reflect.Uint8 {
+			placeholder(arg)
+			continue
+		}
+		if value.Len() == 0 {
+			out.WriteString("NULL")
+			continue
+		}
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			placeholder(value.Index(i).Interface())
+		}
+	}
+	return out.String(), bound
+}
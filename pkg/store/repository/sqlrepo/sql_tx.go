@@ -0,0 +1,49 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/baderkha/library/pkg/store/repository"
+)
+
+var _ repository.ITransaction = &SQLTransaction{}
+
+// SQLTransaction : database/sql transaction (see CrudSQL) , begin errors are returned by Commit
+// and by the calls of the repositories using the transaction
+type SQLTransaction struct {
+	DB *sql.DB
+
+	tx  *sql.Tx
+	err error
+	ctx context.Context
+}
+
+func (s *SQLTransaction) Begin() repository.ITransaction {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	return &SQLTransaction{DB: s.DB, tx: tx, err: err, ctx: s.ctx}
+}
+
+func (s *SQLTransaction) Commit() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.tx == nil {
+		return nil
+	}
+	return s.tx.Commit()
+}
+
+func (s *SQLTransaction) RollBack() {
+	if s.tx != nil {
+		s.tx.Rollback()
+	}
+}
+
+func (s *SQLTransaction) WithContext(ctx context.Context) repository.ITransaction {
+	return &SQLTransaction{DB: s.DB, tx: s.tx, err: s.err, ctx: ctx}
+}
@@ -1,8 +1,12 @@
 package repository
 
+import "context"
+
 // ITransaction : transaction object for multiple dbs
 type ITransaction interface {
 	Begin() ITransaction
 	Commit() error
 	RollBack()
+	// WithContext : transaction whose statements use the context , call it before Begin
+	WithContext(ctx context.Context) ITransaction
 }
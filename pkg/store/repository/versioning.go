@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+)
+
+const (
+	// ColumnVersion : version column of a versioned model (see entity.BaseVersioned)
+	ColumnVersion = "version"
+)
+
+var (
+	// ErrVersionConflict : matches every VersionConflictError (use errors.Is)
+	ErrVersionConflict = errors.New("Version : the record was modified since it was read")
+)
+
+// VersionConflictError : the stored version of the record differs from the version of the update
+// (the record was modified or deleted since it was read)
+//
+// Example :
+//			err := repo.Update(mdl)
+//			if errors.Is(err, repository.ErrVersionConflict) {
+//				// reload the record and retry / ask the user
+//			}
+type VersionConflictError struct {
+	// Table : table / collection of the record
+	Table string
+	// ID : id of the record
+	ID string
+	// Version : version the update was based on
+	Version int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("Version : record `%s` of `%s` was modified since version %d was read", e.ID, e.Table, e.Version)
+}
+
+// Is : matches ErrVersionConflict
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// VersionOf : the record as a versioned model and the internal name of its version column , nil if the model is not versioned
+func VersionOf[t entity.Model](mdl *t) (entity.Versioned, string) {
+	versioned, ok := any(mdl).(entity.Versioned)
+	if !ok {
+		return nil, ""
+	}
+	col := rql.GetSchemaFromTaggedEntity(*mdl, "db").GetColumnInternalName(ColumnVersion)
+	if col == "" {
+		return nil, ""
+	}
+	return versioned, col
+}
+
+// InitVersion : versioned records start at version 1
+func InitVersion[t entity.Model](mdl *t) {
+	versioned, _ := VersionOf(mdl)
+	if versioned != nil && versioned.GetVersion() < 1 {
+		versioned.SetVersion(1)
+	}
+}
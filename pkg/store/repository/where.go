@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/baderkha/library/pkg/err"
+	"github.com/baderkha/library/pkg/rql"
+	"github.com/baderkha/library/pkg/store/entity"
+)
+
+const (
+	// ColumnID : id column of the models embedding entity.Base
+	ColumnID = "id"
+)
+
+var (
+	ErrWhereEmptyFilter = errors.New("Where : a filter is required to update / delete records , an empty filter would match every record")
+	ErrWhereEmptyPatch  = errors.New("Where : the patch does not set any column")
+
+	ErrWherePatchColumn = err.Compose("Where : column `%s` cannot be patched")
+	ErrWherePatchValue  = err.Compose("Where : column `%s` cannot be set to a %T")
+	ErrWhereUnsupported = err.Compose("Where : repository of `%s` cannot count / update / delete by filter")
+
+	ErrWhereFullTextSearch = errors.New("Where : typesense can only update / delete the documents matching a filter , not a full text search")
+)
+
+// IWhere : repo that can count / update / delete the records matching a filter without loading them ,
+// the filter is combined with the base expression (ie the account of the caller) and soft deleted records are excluded like the reads
+//
+// Example :
+//			expired, err := repo.UpdateWhere(
+//				rql.Where("account_id").Eq(accountID).MustBuild(),
+//				map[string]interface{}{"expires_at": time.Now()},
+//			)
+//			purged, err := repo.DeleteWhere(rql.And(
+//				rql.Where("is_verified").Eq(false),
+//				rql.Where("created_at").Lt(time.Now().AddDate(0, 0, -30)),
+//			).MustBuild())
+type IWhere[t any] interface {
+	// CountWhere : number of records matching the filter
+	CountWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error)
+	// ExistsWhere : at least 1 record matches the filter
+	ExistsWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (bool, error)
+	// UpdateWhere : sets the columns of the patch (`db` column => value) on the records matching the filter , returns the number of records updated
+	//
+	// the update time is set to now unless patched and the version of versioned models is incremented
+	UpdateWhere(f *rql.FilterExpression, patch map[string]interface{}, baseExpression ...*rql.FilterExpression) (int64, error)
+	// DeleteWhere : perma deletes the records matching the filter , returns the number of records deleted
+	DeleteWhere(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) (int64, error)
+}
+
+// WhereOf : the repository as an IWhere
+func WhereOf[t entity.Model](repo ICrud[t]) (IWhere[t], error) {
+	where, ok := repo.(IWhere[t])
+	if !ok {
+		var m t
+		return nil, ErrWhereUnsupported(m.TableName())
+	}
+	return where, nil
+}
+
+// RequireFilter : updates / deletes by filter need a filter or a base expression
+func RequireFilter(f *rql.FilterExpression, baseExpression ...*rql.FilterExpression) error {
+	if !IsEmptyFilter(f) {
+		return nil
+	}
+	for _, base := range baseExpression {
+		if !IsEmptyFilter(base) {
+			return nil
+		}
+	}
+	return ErrWhereEmptyFilter
+}
+
+// PatchColumns : the patch keyed by the internal column names , the update time defaults to now
+// (the id and the version cannot be patched)
+func PatchColumns(schema *rql.Schema, patch map[string]interface{}, now time.Time) (map[string]interface{}, error) {
+	if len(patch) == 0 {
+		return nil, ErrWhereEmptyPatch
+	}
+	updates := make(map[string]interface{}, len(patch)+1)
+	for col, value := range patch {
+		internal := schema.GetColumnInternalName(col)
+		if internal == "" || internal == ColumnID || internal == schema.GetColumnInternalName(ColumnVersion) {
+			return nil, ErrWherePatchColumn(col)
+		}
+		updates[internal] = value
+	}
+	colUpdated := schema.GetColumnInternalName(ColumnUpdatedAt)
+	_, isPatched := updates[colUpdated]
+	if colUpdated != "" && !isPatched {
+		updates[colUpdated] = now
+	}
+	return updates, nil
+}
+
+// recordsByIds : the records with the ids , soft deleted records included
+func recordsByIds[t entity.Model](repo ICrud[t], ids []string) ([]*t, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	sd, ok := repo.(ISoftDelete[t])
+	if ok {
+		repo = sd.IncludeDeleted()
+	}
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	f, err := rql.Where(ColumnID).In(values...).Build()
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetWithFilterExpression(f, nil)
+}
+
+// RecordIds : ids of the records
+func RecordIds[t entity.Model](records []*t) []string {
+	ids := make([]string, 0, len(records))
+	for _, rec := range records {
+		ids = append(ids, (*rec).GetID())
+	}
+	return ids
+}
+
+// jsonColumn : json name of the column (typesense documents are keyed by the json names)
+func jsonColumn(schema *rql.Schema, col string) string {
+	name := strings.Split(schema.GetTagValue(col, "json"), ",")[0]
+	if name == "" || name == "-" {
+		return col
+	}
+	return name
+}
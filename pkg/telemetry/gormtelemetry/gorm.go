@@ -0,0 +1,104 @@
+// Package gormtelemetry : traces the gorm statements , kept out of the telemetry package so that it does not depend on gorm
+package gormtelemetry
+
+import (
+	"github.com/baderkha/library/pkg/telemetry"
+	"gorm.io/gorm"
+)
+
+const (
+	gormOperationKey = "telemetry:operation"
+)
+
+var _ gorm.Plugin = &GormPlugin{}
+
+// GormPlugin : gorm plugin tracing every sql statement as a child of the span in the statement's context
+// (ie a otelrepo.TracedCrud span) , so the find and count queries of a paginated read show up separately
+//
+// Example :
+//			err := db.Use(&gormtelemetry.GormPlugin{Telemetry: t})
+type GormPlugin struct {
+	Telemetry *telemetry.Telemetry
+}
+
+func (p *GormPlugin) Name() string {
+	return "library:telemetry"
+}
+
+// Initialize : registers the before / after callbacks of every statement type
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registers := []func() error{
+		func() error {
+			return cb.Create().Before("gorm:create").Register("library:telemetry_before_create", p.before("create"))
+		},
+		func() error {
+			return cb.Create().After("gorm:create").Register("library:telemetry_after_create", p.after)
+		},
+		func() error {
+			return cb.Query().Before("gorm:query").Register("library:telemetry_before_query", p.before("query"))
+		},
+		func() error {
+			return cb.Query().After("gorm:query").Register("library:telemetry_after_query", p.after)
+		},
+		func() error {
+			return cb.Update().Before("gorm:update").Register("library:telemetry_before_update", p.before("update"))
+		},
+		func() error {
+			return cb.Update().After("gorm:update").Register("library:telemetry_after_update", p.after)
+		},
+		func() error {
+			return cb.Delete().Before("gorm:delete").Register("library:telemetry_before_delete", p.before("delete"))
+		},
+		func() error {
+			return cb.Delete().After("gorm:delete").Register("library:telemetry_after_delete", p.after)
+		},
+		func() error {
+			return cb.Row().Before("gorm:row").Register("library:telemetry_before_row", p.before("row"))
+		},
+		func() error {
+			return cb.Row().After("gorm:row").Register("library:telemetry_after_row", p.after)
+		},
+		func() error {
+			return cb.Raw().Before("gorm:raw").Register("library:telemetry_before_raw", p.before("raw"))
+		},
+		func() error {
+			return cb.Raw().After("gorm:raw").Register("library:telemetry_after_raw", p.after)
+		},
+	}
+	for _, register := range registers {
+		err := register()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, op := p.Telemetry.Start(tx.Statement.Context, "gorm", operation, tx.Statement.Table)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(gormOperationKey, op)
+	}
+}
+
+func (p *GormPlugin) after(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(gormOperationKey)
+	if !ok {
+		return
+	}
+	op, ok := v.(*telemetry.Operation)
+	if !ok {
+		return
+	}
+	op.SetAttributes(
+		telemetry.AttrStatement.String(tx.Statement.SQL.String()),
+		telemetry.AttrRows.Int64(tx.Statement.RowsAffected),
+	)
+	err := tx.Error
+	if err == gorm.ErrRecordNotFound {
+		err = nil
+	}
+	op.End(err)
+}
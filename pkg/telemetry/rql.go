@@ -0,0 +1,35 @@
+package telemetry
+
+import (
+	"hash"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/baderkha/library/pkg/rql"
+)
+
+// FilterHash : hash of the shape of the filter (columns , operators , grouping) without its values ,
+// queries of the same shape share a hash so slow query shapes can be grouped without recording user input ,
+// empty for a nil / empty filter
+func FilterHash(f *rql.FilterExpression) string {
+	if f == nil || (f.Column == "" && len(f.Properties) == 0) {
+		return ""
+	}
+	h := fnv.New64a()
+	writeFilterShape(h, f)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func writeFilterShape(h hash.Hash64, f *rql.FilterExpression) {
+	if f == nil {
+		return
+	}
+	h.Write([]byte("(" + f.BinaryOperation + "|" + f.Column + "|" + f.Op))
+	if f.Variable != nil {
+		h.Write([]byte("|$" + *f.Variable))
+	}
+	for _, p := range f.Properties {
+		writeFilterShape(h, p)
+	}
+	h.Write([]byte(")"))
+}
@@ -0,0 +1,135 @@
+// Package telemetry : opentelemetry spans and latency / error metrics for the library's operations (repositories , email , sso , auth)
+//
+// instrumentation is opt in , wrap what you want traced with the decorators that take a *Telemetry
+// (ie otelrepo.TracedCrud , email.TracedSender , sso.TracedHandler) , nothing is recorded otherwise
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// InstrumentationName : name of the tracer / meter
+	InstrumentationName = "github.com/baderkha/library"
+
+	// MetricDuration : histogram of the operations' latency in milliseconds
+	MetricDuration = "library.operation.duration"
+	// MetricErrors : counter of the failed operations
+	MetricErrors = "library.operation.errors"
+
+	// AttrComponent : what ran the operation (ie repository , email , sso , auth , gorm)
+	AttrComponent = attribute.Key("library.component")
+	// AttrOperation : the operation (ie get_by_id , send_html_email , login)
+	AttrOperation = attribute.Key("library.operation")
+	// AttrEntity : table name of the entity of a repository operation
+	AttrEntity = attribute.Key("library.entity")
+	// AttrRows : number of records read / written
+	AttrRows = attribute.Key("library.rows")
+	// AttrTotalRows : total number of records matching a paginated read (-1 if not counted)
+	AttrTotalRows = attribute.Key("library.rows.total")
+	// AttrFilterHash : hash of the shape of the rql filter (see FilterHash)
+	AttrFilterHash = attribute.Key("library.rql.filter_hash")
+	// AttrSSOType : sso provider of a verification (ie GOOGLE)
+	AttrSSOType = attribute.Key("library.sso.type")
+	// AttrStatement : the sql statement with its placeholders (values are not recorded)
+	AttrStatement = attribute.Key("db.statement")
+)
+
+// Telemetry : tracer + instruments used by the decorators
+type Telemetry struct {
+	tracer   trace.Tracer
+	duration syncfloat64.Histogram
+	errors   syncint64.Counter
+}
+
+// New : telemetry using the providers
+func New(tp trace.TracerProvider, mp metric.MeterProvider) (*Telemetry, error) {
+	meter := mp.Meter(InstrumentationName)
+	duration, err := meter.SyncFloat64().Histogram(
+		MetricDuration,
+		instrument.WithUnit(unit.Milliseconds),
+		instrument.WithDescription("latency of the library operations"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	errors, err := meter.SyncInt64().Counter(
+		MetricErrors,
+		instrument.WithDescription("failed library operations"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Telemetry{
+		tracer:   tp.Tracer(InstrumentationName),
+		duration: duration,
+		errors:   errors,
+	}, nil
+}
+
+// NewGlobal : telemetry using the global providers (otel.SetTracerProvider , global.SetMeterProvider)
+func NewGlobal() (*Telemetry, error) {
+	return New(otel.GetTracerProvider(), global.MeterProvider())
+}
+
+// Operation : a running operation , End it once done
+type Operation struct {
+	t     *Telemetry
+	span  trace.Span
+	start time.Time
+	// metricAttrs : low cardinality attributes recorded on the metrics
+	metricAttrs []attribute.KeyValue
+}
+
+// Start : starts the span of the operation , the returned context carries it (pass it to the wrapped calls) ,
+// the attributes are only set on the span (the metrics are recorded per component / operation / entity)
+func (t *Telemetry) Start(ctx context.Context, component string, operation string, entity string, attrs ...attribute.KeyValue) (context.Context, *Operation) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	metricAttrs := []attribute.KeyValue{AttrComponent.String(component), AttrOperation.String(operation)}
+	if entity != "" {
+		metricAttrs = append(metricAttrs, AttrEntity.String(entity))
+	}
+	ctx, span := t.tracer.Start(
+		ctx,
+		component+"."+operation,
+		trace.WithAttributes(metricAttrs...),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, &Operation{
+		t:           t,
+		span:        span,
+		start:       time.Now(),
+		metricAttrs: metricAttrs,
+	}
+}
+
+// SetAttributes : adds attributes to the span (ie row counts once known)
+func (o *Operation) SetAttributes(attrs ...attribute.KeyValue) {
+	o.span.SetAttributes(attrs...)
+}
+
+// End : ends the span and records the latency (+ the error if not nil)
+func (o *Operation) End(err error) {
+	ctx := trace.ContextWithSpan(context.Background(), o.span)
+	o.t.duration.Record(ctx, float64(time.Since(o.start))/float64(time.Millisecond), o.metricAttrs...)
+	if err != nil {
+		o.span.RecordError(err)
+		o.span.SetStatus(codes.Error, err.Error())
+		o.t.errors.Add(ctx, 1, o.metricAttrs...)
+	}
+	o.span.End()
+}